@@ -10,6 +10,7 @@
 :-(op(1105, xfy, '|')).
 :-(op(1100, xfy, ;)).
 :-(op(1050, xfy, ->)).
+:-(op(1050, xfy, *->)).
 :-(op(1000, xfy, ',')).
 :-(op(900, fy, \+)).
 :-(op(700, xfx, =)).
@@ -70,6 +71,9 @@ P; Q :- call((P; Q)).
 :- built_in('->'/2).
 If -> Then :- If, !, Then.
 
+:- built_in('*->'/2).
+If *-> Then :- call((If *-> Then)).
+
 % cut
 
 :- built_in(!/0).
@@ -80,6 +84,12 @@ If -> Then :- If, !, Then.
 :- built_in(once/1).
 once(P) :- P, !.
 
+:- built_in(ignore/1).
+ignore(P) :- (P -> true; true).
+
+:- built_in(forall/2).
+forall(Cond, Action) :- \+ (Cond, \+ Action).
+
 % not unifiable
 
 :- built_in('\\='/2).
@@ -235,8 +245,11 @@ member(X, [X|_]).
 member(X, [_|Xs]) :- member(X, Xs).
 
 :- built_in(length/2).
-length([], 0).
-length([_|Xs], N) :- length(Xs, L), N is L + 1.
+length(List, Length) :- '$length'(List, 0, Length).
+
+:- built_in('$length'/3).
+'$length'([], N, N).
+'$length'([_|Xs], N0, N) :- N1 is N0 + 1, '$length'(Xs, N1, N).
 
 :- built_in(nth0/3).
 nth0(0, [Elem|_], Elem) :- !.
@@ -263,3 +276,6 @@ maplist(Cont, [E|Es]) :-
 
 :- built_in(phrase/2).
 phrase(GRBody, S0) :- phrase(GRBody, S0, []).
+
+:- built_in(run_tests/0).
+run_tests :- run_tests(all).
@@ -2,18 +2,21 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/peterh/liner"
 	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/ichiban/prolog"
@@ -26,26 +29,89 @@ const (
 )
 
 func main() {
-	var verbose bool
-	flag.BoolVar(&verbose, "v", false, `verbose`)
-	flag.Parse()
+	os.Exit(run())
+}
 
-	oldState, err := terminal.MakeRaw(0)
-	if err != nil {
-		log.Panicf("failed to enter raw mode: %v", err)
+// run runs 1pl and returns the exit code.
+func run() int {
+	var (
+		verbose  bool
+		profile  string
+		cover    string
+		goals    goalsFlag
+		toplevel string
+	)
+	flag.BoolVar(&verbose, "v", false, `verbose`)
+	flag.StringVar(&profile, "profile", "", `write an execution profile in pprof format to the file on exit`)
+	flag.StringVar(&cover, "cover", "", `write a coverage report of the loaded files in LCOV format to the file on exit`)
+	flag.Var(&goals, "g", `run the goal after loading the files (can be repeated)`)
+	flag.StringVar(&toplevel, "t", "", `run the goal instead of the interactive toplevel and exit with its status`)
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [file ...] [-- arg ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	args, argv := splitArgs(os.Args[1:])
+	_ = flag.CommandLine.Parse(args)
+	files := flag.Args()
+	if len(files) > 0 && isScript(files[0]) {
+		// The rest of the command line are arguments to the script.
+		files, argv = files[:1], append(files[1:], argv...)
 	}
+
+	var line *liner.State
 	restore := func() {
-		_ = terminal.Restore(0, oldState)
+		if line == nil {
+			return
+		}
+		writeHistory(line, historyFile())
+		_ = line.Close()
+		line = nil
 	}
 	defer restore()
 
-	t := terminal.NewTerminal(os.Stdin, prompt)
-	defer fmt.Printf("\r\n")
+	var profiler *engine.Profiler
+	writeProfile := func() {
+		if profiler == nil {
+			return
+		}
+		f, err := os.Create(profile)
+		if err != nil {
+			log.Printf("failed to create %s: %v", profile, err)
+			return
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		if err := profiler.Profile().WritePprof(f); err != nil {
+			log.Printf("failed to write profile: %v", err)
+		}
+	}
+	defer writeProfile()
+
+	i := prolog.New(os.Stdin, os.Stdout)
+	i.SetArgv(append([]string{os.Args[0]}, argv...))
 
-	log.SetOutput(t)
+	writeCoverage := func() {
+		if cover == "" {
+			return
+		}
+		f, err := os.Create(cover)
+		if err != nil {
+			log.Printf("failed to create %s: %v", cover, err)
+			return
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		if err := i.Coverage().WriteLCOV(f); err != nil {
+			log.Printf("failed to write coverage: %v", err)
+		}
+	}
+	defer writeCoverage()
 
-	i := prolog.New(os.Stdin, t)
 	i.Register1("halt", func(t engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+		writeProfile()
+		writeCoverage()
 		restore()
 		return engine.Halt(t, k, env)
 	})
@@ -60,27 +126,45 @@ func main() {
 			return engine.Error(errors.New("not a dir"))
 		}
 	})
+	var tracers []engine.Tracer
 	if verbose {
-		i.OnCall = func(pi engine.ProcedureIndicator, args []engine.Term, env *engine.Env) {
-			goal, _ := pi.Apply(args...)
-			log.Printf("CALL %s", env.Simplify(goal))
-		}
-		i.OnExit = func(pi engine.ProcedureIndicator, args []engine.Term, env *engine.Env) {
-			goal, _ := pi.Apply(args...)
-			log.Printf("EXIT %s", env.Simplify(goal))
-		}
-		i.OnFail = func(pi engine.ProcedureIndicator, args []engine.Term, env *engine.Env) {
-			goal, _ := pi.Apply(args...)
-			log.Printf("FAIL %s", env.Simplify(goal))
-		}
-		i.OnRedo = func(pi engine.ProcedureIndicator, args []engine.Term, env *engine.Env) {
-			goal, _ := pi.Apply(args...)
-			log.Printf("REDO %s", env.Simplify(goal))
-		}
+		tracers = append(tracers, engine.TracerFunc(func(e *engine.Event) {
+			var sb strings.Builder
+			_ = i.Write(&sb, e.Goal, e.Env, engine.WithQuoted(true))
+			switch e.Port {
+			case engine.PortExit:
+				log.Printf("%s (%d) [%d] %s det=%t", strings.ToUpper(e.Port.String()), e.Depth, e.Invocation, sb.String(), e.Deterministic)
+			case engine.PortException:
+				var ex strings.Builder
+				_ = i.Write(&ex, e.Exception, e.Env, engine.WithQuoted(true))
+				log.Printf("%s (%d) [%d] %s %s", strings.ToUpper(e.Port.String()), e.Depth, e.Invocation, sb.String(), ex.String())
+			default:
+				log.Printf("%s (%d) [%d] %s", strings.ToUpper(e.Port.String()), e.Depth, e.Invocation, sb.String())
+			}
+		}))
+	}
+	if profile != "" {
+		profiler = engine.NewProfiler()
+		tracers = append(tracers, profiler)
+	}
+	switch len(tracers) {
+	case 0:
+		break
+	case 1:
+		i.Tracer = tracers[0]
+	default:
+		i.Tracer = engine.TracerFunc(func(e *engine.Event) {
+			for _, t := range tracers {
+				t.Trace(e)
+			}
+		})
 	}
 	i.OnUnknown = func(pi engine.ProcedureIndicator, args []engine.Term, env *engine.Env) {
 		log.Printf("UNKNOWN %s", pi)
 	}
+	i.OnDebug = func(f *engine.DebugFrame) engine.DebugAction {
+		return debugPrompt(i, f)
+	}
 	i.Register1("version", func(t engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
 		info, ok := debug.ReadBuildInfo()
 		if !ok {
@@ -95,108 +179,473 @@ func main() {
 		return k(env)
 	})
 
-	for _, a := range flag.Args() {
-		b, err := ioutil.ReadFile(a)
-		if err != nil {
-			log.Panicf("failed to read %s: %v", a, err)
-		}
+	if cover != "" {
+		i.StartCoverage()
+	}
 
-		if err := i.Exec(string(b)); err != nil {
-			log.Panicf("failed to execute %s: %v", a, err)
+	for _, f := range files {
+		if err := i.Exec(`:- consult(?).`, engine.Atom(f)); err != nil {
+			log.Printf("failed to load %s: %v", f, err)
+			return exitFailure
 		}
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
+	for _, g := range i.RestoreGoals() {
+		if code := runGoal(ctx, i, g); code != exitSuccess {
+			return code
+		}
+	}
+
+	for _, g := range goals {
+		t, err := parseGoal(i, g)
+		if err != nil {
+			log.Printf("failed to parse %s: %v", g, err)
+			return exitFailure
+		}
+		if code := runGoal(ctx, i, t); code != exitSuccess {
+			return code
+		}
+	}
+
+	if g, ok := i.MainGoal(); ok {
+		return runGoal(ctx, i, g)
+	}
+
+	if toplevel != "" {
+		t, err := parseGoal(i, toplevel)
+		if err != nil {
+			log.Printf("failed to parse %s: %v", toplevel, err)
+			return exitFailure
+		}
+		return runGoal(ctx, i, t)
+	}
+
+	var r lineReader
+	keys := readKey
+	if terminal.IsTerminal(0) {
+		line = liner.NewLiner()
+		line.SetCtrlCAborts(true)
+		line.SetMultiLineMode(true)
+		line.SetTabCompletionStyle(liner.TabPrints)
+		line.SetWordCompleter(complete(i))
+		readHistory(line, historyFile())
+		defer fmt.Println()
+		r = editor{line}
+	} else {
+		r = &pipe{r: bufio.NewReader(os.Stdin)}
+		// There's no one to ask for more answers.
+		keys = func() (rune, error) {
+			return '.', nil
+		}
+	}
+
 	var buf strings.Builder
-	keys := bufio.NewReader(os.Stdin)
 	for {
-		if err := handleLine(ctx, &buf, i, t, keys); err != nil {
-			log.Panic(err)
+		if err := handleLine(ctx, &buf, i, r, keys); err != nil {
+			if err == io.EOF {
+				return exitSuccess
+			}
+			log.Print(err)
+			return exitFailure
+		}
+	}
+}
+
+// Exit codes.
+const (
+	exitSuccess   = 0
+	exitFailure   = 1
+	exitException = 2
+)
+
+// runGoal runs the goal for the first solution and returns the exit code.
+func runGoal(ctx context.Context, i *prolog.Interpreter, goal engine.Term) int {
+	var sb strings.Builder
+	_ = i.Write(&sb, goal, nil, engine.WithQuoted(true))
+
+	ok, err := i.Call(goal, engine.Success, nil).Force(ctx)
+	switch {
+	case err != nil:
+		log.Printf("goal (%s) raised exception: %v", sb.String(), err)
+		return exitException
+	case !ok:
+		log.Printf("goal (%s) failed", sb.String())
+		return exitFailure
+	default:
+		return exitSuccess
+	}
+}
+
+// parseGoal parses the goal given in the command line. The period at the end is optional.
+func parseGoal(i *prolog.Interpreter, goal string) (engine.Term, error) {
+	goal = strings.TrimSpace(goal)
+	if !strings.HasSuffix(goal, ".") {
+		goal += "."
+	}
+	return i.Parser(strings.NewReader(goal), nil).Term()
+}
+
+// goalsFlag is a flag which can be specified multiple times.
+type goalsFlag []string
+
+func (g *goalsFlag) String() string {
+	return strings.Join(*g, ", ")
+}
+
+func (g *goalsFlag) Set(s string) error {
+	*g = append(*g, s)
+	return nil
+}
+
+// splitArgs splits the command line arguments into ones for 1pl and ones after -- for the program.
+func splitArgs(args []string) ([]string, []string) {
+	for i, a := range args {
+		if a == "--" {
+			return args[:i], args[i+1:]
 		}
 	}
+	return args, nil
 }
 
-func handleLine(ctx context.Context, buf *strings.Builder, p *prolog.Interpreter, t *terminal.Terminal, keys *bufio.Reader) (err error) {
-	line, err := t.ReadLine()
+// isScript checks if the file begins with #!.
+func isScript(name string) bool {
+	f, err := os.Open(name)
 	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return false
+	}
+	return string(b) == "#!"
+}
+
+// lineReader reads the lines of queries.
+type lineReader interface {
+	Prompt(prompt string) (string, error)
+	// Continue reads more of the clause which is incomplete so far and returns the whole clause.
+	Continue(prompt, clause string) (string, error)
+	AppendHistory(item string)
+}
+
+// editor is a lineReader for a terminal. It lets the user edit the whole clause as one line.
+type editor struct {
+	*liner.State
+}
+
+// Continue prompts again with the clause so far so that the earlier lines can be edited too.
+func (e editor) Continue(prompt, clause string) (string, error) {
+	return e.PromptWithSuggestion(prompt, historyEntry(clause)+" ", -1)
+}
+
+// pipe is a lineReader for a non-terminal input. It shows no prompts and keeps no history.
+type pipe struct {
+	r *bufio.Reader
+}
+
+func (p *pipe) Prompt(string) (string, error) {
+	l, err := p.r.ReadString('\n')
+	if err == io.EOF && l != "" {
+		err = nil
+	}
+	return strings.TrimSuffix(l, "\n"), err
+}
+
+func (p *pipe) Continue(prompt, clause string) (string, error) {
+	l, err := p.Prompt(prompt)
+	return clause + "\n" + l, err
+}
+
+func (p *pipe) AppendHistory(string) {}
+
+func handleLine(ctx context.Context, buf *strings.Builder, p *prolog.Interpreter, line lineReader, keys func() (rune, error)) (err error) {
+	var l string
+	if buf.Len() == 0 {
+		l, err = line.Prompt(prompt)
+	} else {
+		l, err = line.Continue(contPrompt, buf.String())
+	}
+	switch err {
+	case nil:
+		break
+	case liner.ErrPromptAborted:
+		// Ctrl-C discards the clause being typed.
+		buf.Reset()
+		return nil
+	default:
 		return err
 	}
-	_, _ = buf.WriteString(line)
+	buf.Reset()
+	_, _ = buf.WriteString(l)
 
 	sols, err := p.QueryContext(ctx, buf.String())
 	switch err {
 	case nil:
+		line.AppendHistory(historyEntry(buf.String()))
 		buf.Reset()
-		t.SetPrompt(prompt)
 	case engine.ErrInsufficient:
-		_, _ = buf.WriteRune('\n')
 		// Returns without resetting buf.
-		t.SetPrompt(contPrompt)
 		return nil
 	default:
 		log.Printf("failed to query: %v", err)
+		// Keeps the whole clause in the history so that it can be recalled and fixed.
+		line.AppendHistory(historyEntry(buf.String()))
 		buf.Reset()
-		t.SetPrompt(prompt)
 		return nil
 	}
 	defer func() {
 		_ = sols.Close()
 	}()
 
-	var exists bool
+	// Answers are printed with answer_write_options until the user toggles it with w or p.
+	opts := p.AnswerWriteOptions()
+	var exists, redo bool
+answers:
 	for sols.Next() {
-		exists = true
+		exists, redo = true, false
 
-		m := map[string]engine.Term{}
-		_ = sols.Scan(m)
+		for {
+			var sb strings.Builder
+			if err := sols.WriteAnswer(&sb, opts...); err != nil {
+				return err
+			}
+			if _, err := fmt.Print(sb.String()); err != nil {
+				return err
+			}
 
-		var buf bytes.Buffer
-		vars := sols.Vars()
-		if len(vars) == 0 {
-			_, _ = fmt.Fprintf(&buf, "%t", true)
-		} else {
-			ls := make([]string, len(vars))
-			for i, v := range vars {
-				var sb strings.Builder
-				_, _ = fmt.Fprintf(&sb, "%s = ", v)
-				if err := p.Write(&sb, m[v], nil, engine.WithQuoted(true)); err != nil {
-					return err
-				}
-				ls[i] = sb.String()
+			r, err := keys()
+			if err != nil {
+				return err
+			}
+			switch r {
+			case ';', ' ', 'n', 'r', '\t':
+				fmt.Println(";")
+				redo = true
+				continue answers
+			case '.', 'c', '\r', '\n':
+				fmt.Println(".")
+				break answers
+			case 'a', 3: // Ctrl-C
+				fmt.Println("\n% Execution Aborted")
+				break answers
+			case 'w':
+				fmt.Println(" [write]")
+				opts = append(p.AnswerWriteOptions(), engine.WithMaxDepth(0), p.WithPortray(false))
+			case 'p':
+				fmt.Println(" [print]")
+				opts = p.AnswerWriteOptions()
+			case 'h', '?':
+				fmt.Printf("\n%s", answerHelp)
+			default:
+				fmt.Printf("\nUnknown action: %c (h for help)\n", r)
 			}
-			_, _ = fmt.Fprint(&buf, strings.Join(ls, ",\n"))
 		}
-		if _, err := t.Write(buf.Bytes()); err != nil {
+	}
+
+	if err := sols.Err(); err != nil {
+		log.Print(err)
+		return nil
+	}
+
+	// No answers or no more answers after redo.
+	if !exists || redo {
+		if _, err := fmt.Printf("%t.\n", false); err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+const answerHelp = `Actions:
+;, <space>, n, r: redo
+., c, <enter>: stop
+a: abort
+w: write the answer without depth limit
+p: print the answer with answer_write_options
+h: help
+`
+
+var debugActions = map[rune]engine.DebugAction{
+	'c':  engine.DebugCreep,
+	' ':  engine.DebugCreep,
+	'\r': engine.DebugCreep,
+	'\n': engine.DebugCreep,
+	's':  engine.DebugSkip,
+	'l':  engine.DebugLeap,
+	'f':  engine.DebugFail,
+	'r':  engine.DebugRetry,
+	'a':  engine.DebugAbort,
+}
+
+const debugHelp = `Options:
+c, <space>, <enter>: creep
+s: skip
+l: leap
+f: fail
+r: retry
+a: abort
+`
 
-		r, _, err := keys.ReadRune()
+func debugPrompt(p *prolog.Interpreter, f *engine.DebugFrame) engine.DebugAction {
+	var sb strings.Builder
+	if f.Exception != nil {
+		_ = p.Write(&sb, f.Exception, f.Env, engine.WithQuoted(true))
+		_, _ = fmt.Fprintln(&sb)
+	}
+	port := f.Port.String()
+	_, _ = fmt.Fprintf(&sb, "%10s: (%d) [%d] ", strings.ToUpper(port[:1])+port[1:], f.Depth, f.Invocation)
+	_ = p.Write(&sb, f.Goal, f.Env, engine.WithQuoted(true))
+	if !f.Leashed {
+		fmt.Println(sb.String())
+		return engine.DebugCreep
+	}
+	fmt.Printf("%s ? ", sb.String())
+
+	for {
+		r, err := readKey()
 		if err != nil {
-			return err
+			return engine.DebugAbort
 		}
-		if r != ';' {
-			r = '.'
+		a, ok := debugActions[r]
+		if !ok {
+			fmt.Printf("\n%s%s ? ", debugHelp, sb.String())
+			continue
 		}
-		if _, err := fmt.Fprintf(t, "%s\n", string(r)); err != nil {
-			return err
+		fmt.Println()
+		return a
+	}
+}
+
+// readKey reads a key press from the standard input without waiting for a newline.
+func readKey() (rune, error) {
+	if terminal.IsTerminal(0) {
+		oldState, err := terminal.MakeRaw(0)
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			_ = terminal.Restore(0, oldState)
+		}()
+	}
+
+	// Reads byte by byte so that the rest of the input is left for the following reads.
+	var b [utf8.UTFMax]byte
+	for n := 0; n < len(b); n++ {
+		if _, err := io.ReadFull(os.Stdin, b[n:n+1]); err != nil {
+			return 0, err
 		}
-		if r == '.' {
-			break
+		if utf8.FullRune(b[:n+1]) {
+			r, _ := utf8.DecodeRune(b[:n+1])
+			return r, nil
 		}
 	}
+	return utf8.RuneError, nil
+}
 
-	if err := sols.Err(); err != nil {
-		log.Print(err)
-		return nil
+// historyFile returns the path to the file which keeps the toplevel history across sessions.
+func historyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(dir, "1pl", "history")
+}
 
-	if !exists {
-		if _, err := fmt.Fprintf(t, "%t.\n", false); err != nil {
-			return err
+func readHistory(line *liner.State, name string) {
+	if name == "" {
+		return
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, _ = line.ReadHistory(f)
+}
+
+func writeHistory(line *liner.State, name string) {
+	if name == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		log.Printf("failed to create %s: %v", filepath.Dir(name), err)
+		return
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Printf("failed to create %s: %v", name, err)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := line.WriteHistory(f); err != nil {
+		log.Printf("failed to write history: %v", err)
+	}
+}
+
+// historyEntry joins the lines of a clause so that the whole clause is recalled as one history entry.
+// It leaves the whitespace within a line as it is since it may be a part of a quoted atom or a string.
+func historyEntry(clause string) string {
+	return strings.Join(strings.Split(strings.TrimSpace(clause), "\n"), " ")
+}
+
+// complete completes file names inside quoted atoms and atoms elsewhere.
+func complete(p *prolog.Interpreter) liner.WordCompleter {
+	return func(line string, pos int) (string, []string, string) {
+		head, tail := line[:pos], line[pos:]
+
+		if i := openQuote(head); i >= 0 {
+			prefix := head[i+1:]
+			matches, _ := filepath.Glob(prefix + "*")
+			cs := make([]string, len(matches))
+			for j, m := range matches {
+				if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+					m += string(filepath.Separator)
+				}
+				cs[j] = m
+			}
+			return head[:i+1], cs, tail
 		}
+
+		i := strings.LastIndexFunc(head, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		}) + 1
+		prefix := head[i:]
+		if r, _ := utf8.DecodeRuneInString(prefix); !unicode.IsLower(r) {
+			return head, nil, tail
+		}
+		var cs []string
+		for _, a := range p.Atoms() {
+			if strings.HasPrefix(string(a), prefix) {
+				cs = append(cs, string(a))
+			}
+		}
+		return head[:i], cs, tail
 	}
+}
 
-	return nil
+// openQuote returns the index of the single quote which opens the quoted atom surrounding the end of s, or -1.
+func openQuote(s string) int {
+	i := -1
+	for j, r := range s {
+		if r != '\'' {
+			continue
+		}
+		if i < 0 {
+			i = j
+		} else {
+			i = -1
+		}
+	}
+	return i
 }
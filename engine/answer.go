@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultAnswerWriteOptions is the initial value of the answer_write_options flag.
+var defaultAnswerWriteOptions = List(
+	&Compound{Functor: "quoted", Args: []Term{Atom("true")}},
+	&Compound{Functor: "portray", Args: []Term{Atom("true")}},
+	&Compound{Functor: "max_depth", Args: []Term{Integer(10)}},
+)
+
+func (state *State) answerWriteOptionsTerm() Term {
+	if state.answerWriteOptions == nil {
+		return defaultAnswerWriteOptions
+	}
+	return state.answerWriteOptions
+}
+
+// AnswerWriteOptions returns the write options specified by the answer_write_options flag.
+func (state *State) AnswerWriteOptions() []WriteOption {
+	var opts []WriteOption
+	iter := ListIterator{List: state.answerWriteOptionsTerm()}
+	for iter.Next() {
+		// The options are validated when the flag is set.
+		opt, err := writeTermOption(state, iter.Current(), nil)
+		if err != nil {
+			continue
+		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+// portray writes t by the user-defined portray/1 and returns the output if it succeeds.
+func (state *State) portray(t Term, env *Env) (string, bool) {
+	pi := ProcedureIndicator{Name: "portray", Arity: 1}
+	if _, ok := state.procedures[pi]; !ok {
+		return "", false
+	}
+
+	var sb strings.Builder
+	output := state.output
+	state.output = NewStream(readWriteCloser(&sb), StreamModeWrite)
+	defer func() {
+		state.output = output
+	}()
+
+	// Calls portray/1 with a copy so that it doesn't leave bindings in env.
+	ok, err := state.Call(&Compound{Functor: "portray", Args: []Term{env.Simplify(t)}}, Success, nil).Force(context.Background())
+	if err != nil || !ok {
+		return "", false
+	}
+	return sb.String(), true
+}
+
+// WriteAnswer writes the answer of a query as the toplevel shows it. The bindings of the query variables are written
+// one per line like X = Y, Y = f(_A). Variables sharing the same value are written as equations between them,
+// cyclic terms refer to themselves by names, and the other variables are named _A, _B, and so on.
+// The residual goals follow the bindings. If there's nothing to show, it writes true.
+func (state *State) WriteAnswer(w io.Writer, vars []ParsedVariable, env *Env, opts ...WriteOption) error {
+	a := answer{
+		env:    env,
+		cyclic: map[Variable]bool{},
+		names:  map[Variable]Atom{},
+		used:   map[Atom]bool{},
+	}
+	for _, v := range vars {
+		a.used[v.Name] = true
+	}
+
+	// Query variables with the same value are grouped together.
+	for _, v := range vars {
+		if strings.HasPrefix(string(v.Name), "_") {
+			continue
+		}
+		a.findCycles(v.Variable, map[Variable]bool{})
+		val := env.Resolve(v.Variable)
+		if g := a.group(val); g != nil {
+			g.names = append(g.names, v.Name)
+			continue
+		}
+		a.groups = append(a.groups, &answerGroup{value: val, names: []Atom{v.Name}, standIn: NewVariable()})
+	}
+
+	var bindings [][2]Term
+	for _, g := range a.groups {
+		last := g.names[len(g.names)-1]
+		a.names[g.standIn] = last
+		if v, ok := g.value.(Variable); ok {
+			a.names[v] = last
+		}
+		for i := 1; i < len(g.names); i++ {
+			bindings = append(bindings, [2]Term{a.named(g.names[i-1]), a.named(g.names[i])})
+		}
+	}
+	for _, g := range a.groups {
+		if _, ok := g.value.(Variable); ok {
+			continue
+		}
+		bindings = append(bindings, [2]Term{g.standIn, a.expand(g.value)})
+	}
+	// The cycles which don't belong to any query variables.
+	bindings = append(bindings, a.extra...)
+
+	var goals []Term
+	if state.ResidualGoals != nil {
+		vs := make([]Variable, len(vars))
+		for i, v := range vars {
+			vs[i] = v.Variable
+		}
+		for _, g := range state.ResidualGoals(vs, env) {
+			goals = append(goals, a.expand(g))
+		}
+	}
+
+	if len(bindings) == 0 && len(goals) == 0 {
+		_, err := fmt.Fprint(w, "true")
+		return err
+	}
+
+	ts := make([]Term, 0, 2*len(bindings)+len(goals))
+	for _, b := range bindings {
+		ts = append(ts, b[0], b[1])
+	}
+	ts = append(ts, goals...)
+	letter := 0
+	for _, v := range env.FreeVariables(ts...) {
+		if _, ok := a.names[v]; ok {
+			continue
+		}
+		for {
+			n := Atom("_" + letterName(letter))
+			letter++
+			if !a.used[n] {
+				a.names[v] = n
+				break
+			}
+		}
+	}
+
+	opts = append(opts, WithVariableNames(a.names))
+	var sb strings.Builder
+	for i, b := range bindings {
+		if i > 0 {
+			_, _ = sb.WriteString(",\n")
+		}
+		if err := state.Write(&sb, b[0], env, opts...); err != nil {
+			return err
+		}
+		_, _ = sb.WriteString(" = ")
+		if err := state.Write(&sb, b[1], env, append(opts, WithPriority(699))...); err != nil {
+			return err
+		}
+	}
+	for i, g := range goals {
+		if i > 0 || len(bindings) > 0 {
+			_, _ = sb.WriteString(",\n")
+		}
+		if err := state.Write(&sb, g, env, append(opts, WithPriority(999))...); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, sb.String())
+	return err
+}
+
+type answer struct {
+	env    *Env
+	groups []*answerGroup
+	cyclic map[Variable]bool
+	names  map[Variable]Atom
+	used   map[Atom]bool
+	extra  [][2]Term
+	cycles map[Term]Variable
+}
+
+type answerGroup struct {
+	value   Term
+	names   []Atom
+	standIn Variable
+}
+
+// named returns a variable which is written as the name.
+func (a *answer) named(name Atom) Term {
+	v := NewVariable()
+	a.names[v] = name
+	return v
+}
+
+// group returns the group of query variables whose value is identical to val.
+func (a *answer) group(val Term) *answerGroup {
+	for _, g := range a.groups {
+		if g.value == val {
+			return g
+		}
+	}
+	return nil
+}
+
+// findCycles marks variables which are bound to terms containing themselves.
+func (a *answer) findCycles(t Term, visiting map[Variable]bool) {
+	switch t := t.(type) {
+	case Variable:
+		val, ok := a.env.Lookup(t)
+		if !ok {
+			return
+		}
+		if visiting[t] {
+			a.cyclic[t] = true
+			return
+		}
+		visiting[t] = true
+		a.findCycles(val, visiting)
+		delete(visiting, t)
+	case *Compound:
+		for _, arg := range t.Args {
+			a.findCycles(arg, visiting)
+		}
+	}
+}
+
+// expand returns a copy of t where bound variables are replaced with their values except for cycles.
+func (a *answer) expand(t Term) Term {
+	switch t := t.(type) {
+	case Variable:
+		val, ok := a.env.Lookup(t)
+		if !ok {
+			return t
+		}
+		if a.cyclic[t] {
+			return a.cycle(t)
+		}
+		return a.expand(val)
+	case *Compound:
+		c := Compound{Functor: t.Functor, Args: make([]Term, len(t.Args))}
+		for i, arg := range t.Args {
+			c.Args[i] = a.expand(arg)
+		}
+		return &c
+	default:
+		return t
+	}
+}
+
+// cycle returns a variable which refers to the value of the cyclic variable v.
+func (a *answer) cycle(v Variable) Term {
+	val := a.env.Resolve(v)
+	if g := a.group(val); g != nil {
+		return g.standIn
+	}
+	if s, ok := a.cycles[val]; ok {
+		return s
+	}
+	if a.cycles == nil {
+		a.cycles = map[Term]Variable{}
+	}
+	s := NewVariable()
+	a.cycles[val] = s
+	for n := len(a.cycles); ; n++ {
+		name := Atom(fmt.Sprintf("_S%d", n))
+		if !a.used[name] {
+			a.names[s] = name
+			a.used[name] = true
+			break
+		}
+	}
+	a.extra = append(a.extra, [2]Term{s, a.expand(val)})
+	return s
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_WriteAnswer(t *testing.T) {
+	x, y, z, w := NewVariable(), NewVariable(), NewVariable(), NewVariable()
+	vars := []ParsedVariable{{Name: "X", Variable: x}, {Name: "Y", Variable: y}, {Name: "Z", Variable: z}}
+
+	f := func(args ...Term) Term {
+		return &Compound{Functor: "f", Args: args}
+	}
+
+	for _, tt := range []struct {
+		title  string
+		vars   []ParsedVariable
+		env    *Env
+		opts   []WriteOption
+		answer string
+	}{
+		{title: "no variables", answer: "true"},
+		{title: "unbound", vars: vars, answer: "true"},
+		{title: "bound", vars: vars, env: NewEnv().Bind(x, Atom("a")).Bind(z, Integer(1)), answer: "X = a,\nZ = 1"},
+		{title: "shared", vars: vars, env: NewEnv().Bind(x, y).Bind(y, f(w)), answer: "X = Y,\nY = f(_A)"},
+		{title: "aliased", vars: vars, env: NewEnv().Bind(x, y).Bind(z, f(y)), answer: "X = Y,\nZ = f(Y)"},
+		{title: "fresh", vars: vars, env: NewEnv().Bind(x, f(w, z, w)).Bind(y, f(NewVariable())), answer: "X = f(_A, Z, _A),\nY = f(_B)"},
+		{title: "cyclic", vars: vars, env: NewEnv().Bind(x, f(x)), answer: "X = f(X)"},
+		{title: "anonymous cycle", vars: vars, env: NewEnv().Bind(x, f(w)).Bind(w, f(w)), answer: "X = f(_S1),\n_S1 = f(_S1)"},
+		{title: "hidden", vars: []ParsedVariable{{Name: "_A", Variable: x}, {Name: "Y", Variable: y}}, env: NewEnv().Bind(x, Atom("a")).Bind(y, f(z)), answer: "Y = f(_B)"},
+		{title: "max depth", vars: vars, env: NewEnv().Bind(x, List(Integer(1), Integer(2), Integer(3), Integer(4))).Bind(y, f(f(f(Atom("a"))))), opts: []WriteOption{WithMaxDepth(2)}, answer: "X = [1, 2|...],\nY = f(f(...))"},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			var state State
+			var sb strings.Builder
+			assert.NoError(t, state.WriteAnswer(&sb, tt.vars, tt.env, tt.opts...))
+			assert.Equal(t, tt.answer, sb.String())
+		})
+	}
+
+	t.Run("residual goals", func(t *testing.T) {
+		var state State
+		state.ResidualGoals = func(vs []Variable, env *Env) []Term {
+			assert.Equal(t, []Variable{x, y, z}, vs)
+			return []Term{&Compound{Functor: "freeze", Args: []Term{x, w}}}
+		}
+		var sb strings.Builder
+		assert.NoError(t, state.WriteAnswer(&sb, vars, NewEnv().Bind(y, Atom("a")), WithQuoted(true)))
+		assert.Equal(t, "Y = a,\nfreeze(X, _A)", sb.String())
+	})
+
+	t.Run("portray", func(t *testing.T) {
+		var state State
+		state.Register1("portray", func(t Term, k func(*Env) *Promise, env *Env) *Promise {
+			c, ok := env.Resolve(t).(*Compound)
+			if !ok || c.Functor != "f" {
+				return Bool(false)
+			}
+			if _, err := fmt.Fprint(state.output.file, "<f>"); err != nil {
+				return Error(err)
+			}
+			return k(env)
+		})
+		var sb strings.Builder
+		assert.NoError(t, state.WriteAnswer(&sb, vars, NewEnv().Bind(x, &Compound{Functor: "g", Args: []Term{f(Atom("a"))}}), state.WithPortray(true)))
+		assert.Equal(t, "X = g(<f>)", sb.String())
+	})
+}
+
+func TestState_AnswerWriteOptions(t *testing.T) {
+	var state State
+	state.SetUserOutput(&strings.Builder{})
+	ok, err := state.SetPrologFlag(Atom("answer_write_options"), List(&Compound{Functor: "max_depth", Args: []Term{Integer(1)}}), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	var sb strings.Builder
+	x := NewVariable()
+	assert.NoError(t, state.WriteAnswer(&sb, []ParsedVariable{{Name: "X", Variable: x}}, NewEnv().Bind(x, &Compound{Functor: "f", Args: []Term{&Compound{Functor: "g", Args: []Term{Atom("a")}}}}), state.AnswerWriteOptions()...))
+	assert.Equal(t, "X = f(...)", sb.String())
+}
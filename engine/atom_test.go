@@ -27,14 +27,14 @@ func TestAtom_Unify(t *testing.T) {
 
 	t.Run("variable", func(t *testing.T) {
 		t.Run("free", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 
 			env, ok := unit.Unify(v, false, nil)
 			assert.True(t, ok)
 			assert.Equal(t, unit, env.Resolve(v))
 		})
 		t.Run("bound to the same value", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env := NewEnv().
 				Bind(v, unit)
 			env, ok := unit.Unify(v, false, env)
@@ -42,7 +42,7 @@ func TestAtom_Unify(t *testing.T) {
 			assert.Equal(t, unit, env.Resolve(v))
 		})
 		t.Run("bound to a different value", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env := NewEnv().
 				Bind(v, Atom("bar"))
 			_, ok := unit.Unify(v, false, env)
@@ -144,7 +144,7 @@ func TestAtom_Compare(t *testing.T) {
 	assert.Equal(t, int64(-1), Atom("a").Compare(Atom("b"), nil))
 	assert.Equal(t, int64(0), Atom("a").Compare(Atom("a"), nil))
 	assert.Equal(t, int64(1), Atom("b").Compare(Atom("a"), nil))
-	assert.Equal(t, int64(1), Atom("a").Compare(Variable("X"), nil))
+	assert.Equal(t, int64(1), Atom("a").Compare(testVar("X"), nil))
 	assert.Equal(t, int64(1), Atom("a").Compare(Float(0), nil))
 	assert.Equal(t, int64(1), Atom("a").Compare(Integer(0), nil))
 }
@@ -25,12 +25,25 @@ type State struct {
 	charConvEnabled bool
 	doubleQuotes    doubleQuotes
 
+	// Toplevel
+	answerWriteOptions Term
+	argv               []string
+
 	// I/O
 	streams       map[Term]*Stream
 	input, output *Stream
 
-	// Misc
-	debug bool
+	// Unit tests
+	testUnit Atom
+	tests    []Test
+
+	// Engines
+	engines      map[Atom]*Engine
+	activeEngine *Engine
+
+	// Arithmetic
+	functions           EvaluableFunctors
+	arithmeticFunctions map[ProcedureIndicator]struct{}
 }
 
 var errNotSupported = errors.New("not supported")
@@ -138,7 +151,7 @@ func (state *State) Call(goal Term, k func(*Env) *Promise, env *Env) *Promise {
 				call.Apply(args...),
 				g,
 			},
-		}))
+		}), SourcePos{})
 		if err != nil {
 			return Error(err)
 		}
@@ -149,65 +162,46 @@ func (state *State) Call(goal Term, k func(*Env) *Promise, env *Env) *Promise {
 
 // Call1 succeeds if closure with an additional argument succeeds.
 func (state *State) Call1(closure, arg1 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
-	if err != nil {
-		return Error(err)
-	}
-	return state.Call(pi.Name.Apply(append(args, arg1)...), k, env)
+	return state.CallN(closure, []Term{arg1}, k, env)
 }
 
 // Call2 succeeds if closure with 2 additional arguments succeeds.
 func (state *State) Call2(closure, arg1, arg2 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
-	if err != nil {
-		return Error(err)
-	}
-	return state.Call(pi.Name.Apply(append(args, arg1, arg2)...), k, env)
+	return state.CallN(closure, []Term{arg1, arg2}, k, env)
 }
 
 // Call3 succeeds if closure with 3 additional arguments succeeds.
 func (state *State) Call3(closure, arg1, arg2, arg3 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
-	if err != nil {
-		return Error(err)
-	}
-	return state.Call(pi.Name.Apply(append(args, arg1, arg2, arg3)...), k, env)
+	return state.CallN(closure, []Term{arg1, arg2, arg3}, k, env)
 }
 
 // Call4 succeeds if closure with 4 additional arguments succeeds.
 func (state *State) Call4(closure, arg1, arg2, arg3, arg4 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
-	if err != nil {
-		return Error(err)
-	}
-	return state.Call(pi.Name.Apply(append(args, arg1, arg2, arg3, arg4)...), k, env)
+	return state.CallN(closure, []Term{arg1, arg2, arg3, arg4}, k, env)
 }
 
 // Call5 succeeds if closure with 5 additional arguments succeeds.
 func (state *State) Call5(closure, arg1, arg2, arg3, arg4, arg5 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
-	if err != nil {
-		return Error(err)
-	}
-	return state.Call(pi.Name.Apply(append(args, arg1, arg2, arg3, arg4, arg5)...), k, env)
+	return state.CallN(closure, []Term{arg1, arg2, arg3, arg4, arg5}, k, env)
 }
 
 // Call6 succeeds if closure with 6 additional arguments succeeds.
 func (state *State) Call6(closure, arg1, arg2, arg3, arg4, arg5, arg6 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
-	if err != nil {
-		return Error(err)
-	}
-	return state.Call(pi.Name.Apply(append(args, arg1, arg2, arg3, arg4, arg5, arg6)...), k, env)
+	return state.CallN(closure, []Term{arg1, arg2, arg3, arg4, arg5, arg6}, k, env)
 }
 
 // Call7 succeeds if closure with 7 additional arguments succeeds.
 func (state *State) Call7(closure, arg1, arg2, arg3, arg4, arg5, arg6, arg7 Term, k func(*Env) *Promise, env *Env) *Promise {
-	pi, args, err := piArgs(closure, env)
+	return state.CallN(closure, []Term{arg1, arg2, arg3, arg4, arg5, arg6, arg7}, k, env)
+}
+
+// CallN succeeds if closure with the additional arguments succeeds.
+func (state *State) CallN(closure Term, args []Term, k func(*Env) *Promise, env *Env) *Promise {
+	goal, err := closureGoal(closure, args, env)
 	if err != nil {
 		return Error(err)
 	}
-	return state.Call(pi.Name.Apply(append(args, arg1, arg2, arg3, arg4, arg5, arg6, arg7)...), k, env)
+	return state.Call(goal, k, env)
 }
 
 // Unify unifies t1 and t2 without occurs check (i.e., X = f(X) is allowed).
@@ -605,7 +599,7 @@ func (state *State) CurrentOp(priority, specifier, operator Term, k func(*Env) *
 
 // Assertz appends t to the database.
 func (state *State) Assertz(t Term, k func(*Env) *Promise, env *Env) *Promise {
-	if err := state.assert(t, false, func(existing clauses, new clauses) clauses {
+	if err := state.assert(t, SourcePos{}, false, func(existing clauses, new clauses) clauses {
 		return append(existing, new...)
 	}, env); err != nil {
 		return Error(err)
@@ -615,7 +609,7 @@ func (state *State) Assertz(t Term, k func(*Env) *Promise, env *Env) *Promise {
 
 // Asserta prepends t to the database.
 func (state *State) Asserta(t Term, k func(*Env) *Promise, env *Env) *Promise {
-	if err := state.assert(t, false, func(existing clauses, new clauses) clauses {
+	if err := state.assert(t, SourcePos{}, false, func(existing clauses, new clauses) clauses {
 		return append(new, existing...)
 	}, env); err != nil {
 		return Error(err)
@@ -625,12 +619,24 @@ func (state *State) Asserta(t Term, k func(*Env) *Promise, env *Env) *Promise {
 
 // Assert appends t to the database.
 func (state *State) Assert(t Term, env *Env) error {
-	return state.assert(t, true, func(existing clauses, new clauses) clauses {
+	return state.AssertAt(t, SourcePos{}, env)
+}
+
+// AssertAt appends t to the database as a clause defined at pos.
+// If it's a clause of test/1 or test/2 between begin_tests/1 and end_tests/1, it's added as a test instead.
+func (state *State) AssertAt(t Term, pos SourcePos, env *Env) error {
+	if state.testUnit != "" {
+		if test, ok := state.testClause(t, pos, env); ok {
+			state.tests = append(state.tests, test)
+			return nil
+		}
+	}
+	return state.assert(t, pos, true, func(existing clauses, new clauses) clauses {
 		return append(existing, new...)
 	}, env)
 }
 
-func (state *State) assert(t Term, force bool, merge func(clauses, clauses) clauses, env *Env) error {
+func (state *State) assert(t Term, pos SourcePos, force bool, merge func(clauses, clauses) clauses, env *Env) error {
 	pi, args, err := piArgs(t, env)
 	if err != nil {
 		return err
@@ -655,7 +661,7 @@ func (state *State) assert(t Term, force bool, merge func(clauses, clauses) clau
 		}
 	}
 
-	added, err := compile(env.Simplify(t))
+	added, err := compile(env.Simplify(t), pos)
 	if err != nil {
 		return err
 	}
@@ -713,10 +719,10 @@ func (state *State) collectionOf(agg func(...Term) Term, template, goal, instanc
 
 	return Delay(func(ctx context.Context) *Promise {
 		const (
-			hyphen  = Atom("-")
-			vars    = Atom("vars")
-			answers = Variable("Answers")
+			hyphen = Atom("-")
+			vars   = Atom("vars")
 		)
+		answers := NewVariable()
 
 		type solution struct {
 			vars      Term
@@ -918,6 +924,73 @@ func (state *State) Catch(goal, catcher, recover Term, k func(*Env) *Promise, en
 	})
 }
 
+// SetupCallCleanup calls setup once and then goal. It calls cleanup once when goal exits deterministically, fails,
+// or raises an exception. The failure of cleanup is ignored.
+func (state *State) SetupCallCleanup(setup, goal, cleanup Term, k func(*Env) *Promise, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		var e *Env
+		ok, err := state.Call(setup, func(env *Env) *Promise {
+			e = env
+			return Bool(true)
+		}, env).Force(ctx)
+		if err != nil {
+			return Error(err)
+		}
+		if !ok {
+			return Bool(false)
+		}
+		return state.CallCleanup(goal, cleanup, k, e)
+	})
+}
+
+// CallCleanup calls goal and then calls cleanup once when goal exits deterministically, fails, raises an exception,
+// or its choicepoints are discarded by a cut or the end of the execution. The failure of cleanup is ignored.
+func (state *State) CallCleanup(goal, cleanup Term, k func(*Env) *Promise, env *Env) *Promise {
+	// The cleanup may run after the execution moved on from the environment of the goal, so it runs on a copy of
+	// cleanup with the bindings at the latest exit of the goal in an environment of its own.
+	var done bool
+	c := env.Simplify(cleanup)
+	runCleanup := func(ctx context.Context) error {
+		if done {
+			return nil
+		}
+		done = true
+		_, err := state.Call(c, Success, nil).Force(ctx)
+		return err
+	}
+
+	var p *Promise
+	p = discardable(func(ctx context.Context) {
+		_ = runCleanup(ctx)
+	}, func(context.Context) *Promise {
+		return state.Call(goal, func(env *Env) *Promise {
+			c = env.Simplify(cleanup)
+			return detect(p, func(det bool) *Promise {
+				if !det {
+					return k(env)
+				}
+				return Delay(func(ctx context.Context) *Promise {
+					if err := runCleanup(ctx); err != nil {
+						return Error(err)
+					}
+					// Since the goal has no more solutions, we remove the choicepoint for its failure.
+					return Cut(p, func(context.Context) *Promise {
+						return k(env)
+					})
+				})
+			})
+		}, env)
+	}, func(ctx context.Context) *Promise {
+		// The bindings of the goal are undone.
+		c = env.Simplify(cleanup)
+		if err := runCleanup(ctx); err != nil {
+			return Error(err)
+		}
+		return Bool(false)
+	})
+	return p
+}
+
 // CurrentPredicate matches pi with a predicate indicator of the user-defined procedures in the database.
 func (state *State) CurrentPredicate(pi Term, k func(*Env) *Promise, env *Env) *Promise {
 	switch pi := env.Resolve(pi).(type) {
@@ -1346,6 +1419,11 @@ func writeTermOption(state *State, option Term, env *Env) (WriteOption, error) {
 			return nil, ErrInstantiation
 		case Atom:
 			oi = optionIndicator{functor: option.Functor, arg: v}
+		case Integer:
+			if option.Functor != "max_depth" || v < 0 {
+				return nil, domainErrorWriteOption(option)
+			}
+			return WithMaxDepth(int(v)), nil
 		default:
 			return nil, domainErrorWriteOption(option)
 		}
@@ -1366,6 +1444,10 @@ func writeTermOption(state *State, option Term, env *Env) (WriteOption, error) {
 		return WithNumberVars(true), nil
 	case optionIndicator{functor: "numbervars", arg: "false"}:
 		return WithNumberVars(false), nil
+	case optionIndicator{functor: "portray", arg: "true"}:
+		return state.WithPortray(true), nil
+	case optionIndicator{functor: "portray", arg: "false"}:
+		return state.WithPortray(false), nil
 	default:
 		return nil, domainErrorWriteOption(option)
 	}
@@ -1382,7 +1464,7 @@ func CharCode(char, code Term, k func(*Env) *Promise, env *Env) *Promise {
 			r := rune(cd)
 
 			if !utf8.ValidRune(r) {
-				return Error(representationError("character_code"))
+				return Error(RepresentationError("character_code"))
 			}
 
 			return Delay(func(context.Context) *Promise {
@@ -1469,7 +1551,7 @@ func (state *State) PutCode(streamOrAlias, code Term, k func(*Env) *Promise, env
 		r := rune(c)
 
 		if !utf8.ValidRune(r) {
-			return Error(representationError("character_code"))
+			return Error(RepresentationError("character_code"))
 		}
 
 		if _, err := write(s.file, []byte(string(r))); err != nil {
@@ -1686,7 +1768,7 @@ func (state *State) GetChar(streamOrAlias, char Term, k func(*Env) *Promise, env
 	switch err {
 	case nil:
 		if r == unicode.ReplacementChar {
-			return Error(representationError("character"))
+			return Error(RepresentationError("character"))
 		}
 
 		return Delay(func(context.Context) *Promise {
@@ -1802,7 +1884,7 @@ func (state *State) PeekChar(streamOrAlias, char Term, k func(*Env) *Promise, en
 		}
 
 		if r == unicode.ReplacementChar {
-			return Error(representationError("character"))
+			return Error(RepresentationError("character"))
 		}
 
 		return Delay(func(context.Context) *Promise {
@@ -2070,7 +2152,7 @@ func AtomCodes(atom, codes Term, k func(*Env) *Promise, env *Env) *Promise {
 			case Integer:
 				_, _ = sb.WriteRune(rune(e))
 			default:
-				return Error(representationError("character_code"))
+				return Error(RepresentationError("character_code"))
 			}
 		}
 		if err := iter.Err(); err != nil {
@@ -2185,7 +2267,7 @@ func NumberCodes(num, codes Term, k func(*Env) *Promise, env *Env) *Promise {
 			case Integer:
 				_, _ = sb.WriteRune(rune(e))
 			default:
-				return Error(representationError("character_code"))
+				return Error(RepresentationError("character_code"))
 			}
 		}
 		if err := iter.Err(); err != nil {
@@ -2356,7 +2438,7 @@ func (state *State) CharConversion(inChar, outChar Term, k func(*Env) *Promise,
 	case Atom:
 		i := []rune(in)
 		if len(i) != 1 {
-			return Error(representationError("character"))
+			return Error(RepresentationError("character"))
 		}
 
 		switch out := env.Resolve(outChar).(type) {
@@ -2365,7 +2447,7 @@ func (state *State) CharConversion(inChar, outChar Term, k func(*Env) *Promise,
 		case Atom:
 			o := []rune(out)
 			if len(o) != 1 {
-				return Error(representationError("character"))
+				return Error(RepresentationError("character"))
 			}
 
 			if state.charConversions == nil {
@@ -2378,10 +2460,10 @@ func (state *State) CharConversion(inChar, outChar Term, k func(*Env) *Promise,
 			state.charConversions[i[0]] = o[0]
 			return k(env)
 		default:
-			return Error(representationError("character"))
+			return Error(RepresentationError("character"))
 		}
 	default:
-		return Error(representationError("character"))
+		return Error(RepresentationError("character"))
 	}
 }
 
@@ -2393,10 +2475,10 @@ func (state *State) CurrentCharConversion(inChar, outChar Term, k func(*Env) *Pr
 	case Atom:
 		i := []rune(in)
 		if len(i) != 1 {
-			return Error(representationError("character"))
+			return Error(RepresentationError("character"))
 		}
 	default:
-		return Error(representationError("character"))
+		return Error(RepresentationError("character"))
 	}
 
 	switch out := env.Resolve(outChar).(type) {
@@ -2405,10 +2487,10 @@ func (state *State) CurrentCharConversion(inChar, outChar Term, k func(*Env) *Pr
 	case Atom:
 		o := []rune(out)
 		if len(o) != 1 {
-			return Error(representationError("character"))
+			return Error(RepresentationError("character"))
 		}
 	default:
-		return Error(representationError("character"))
+		return Error(RepresentationError("character"))
 	}
 
 	if c1, ok := env.Resolve(inChar).(Atom); ok {
@@ -2447,7 +2529,7 @@ func (state *State) SetPrologFlag(flag, value Term, k func(*Env) *Promise, env *
 	case Atom:
 		var modify func(value Atom) error
 		switch f {
-		case "bounded", "max_integer", "min_integer", "integer_rounding_function", "max_arity":
+		case "bounded", "max_integer", "min_integer", "integer_rounding_function", "max_arity", "argv":
 			return Error(PermissionError("modify", "flag", f))
 		case "char_conversion":
 			modify = state.modifyCharConversion
@@ -2457,6 +2539,11 @@ func (state *State) SetPrologFlag(flag, value Term, k func(*Env) *Promise, env *
 			modify = state.modifyUnknown
 		case "double_quotes":
 			modify = state.modifyDoubleQuotes
+		case "answer_write_options":
+			if err := state.modifyAnswerWriteOptions(value, env); err != nil {
+				return Error(err)
+			}
+			return k(env)
 		default:
 			return Error(domainErrorPrologFlag(f))
 		}
@@ -2498,9 +2585,9 @@ func (state *State) modifyCharConversion(value Atom) error {
 func (state *State) modifyDebug(value Atom) error {
 	switch value {
 	case "on":
-		state.debug = true
+		state.debugger.enabled = true
 	case "off":
-		state.debug = false
+		state.debugger.enabled = false
 	default:
 		return domainErrorFlagValue(&Compound{
 			Functor: "+",
@@ -2544,6 +2631,39 @@ func (state *State) modifyDoubleQuotes(value Atom) error {
 	return nil
 }
 
+func (state *State) modifyAnswerWriteOptions(value Term, env *Env) error {
+	iter := ListIterator{List: value, Env: env}
+	for iter.Next() {
+		if _, err := writeTermOption(state, iter.Current(), env); err != nil {
+			if err == ErrInstantiation {
+				return err
+			}
+			return domainErrorFlagValue(&Compound{
+				Functor: "+",
+				Args:    []Term{Atom("answer_write_options"), env.Simplify(value)},
+			})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	state.answerWriteOptions = env.Simplify(value)
+	return nil
+}
+
+// SetArgv sets the command line arguments which are visible as the argv flag.
+func (state *State) SetArgv(args []string) {
+	state.argv = args
+}
+
+func (state *State) argvTerm() Term {
+	args := make([]Term, len(state.argv))
+	for i, a := range state.argv {
+		args[i] = Atom(a)
+	}
+	return List(args...)
+}
+
 // CurrentPrologFlag succeeds iff flag is set to value.
 func (state *State) CurrentPrologFlag(flag, value Term, k func(*Env) *Promise, env *Env) *Promise {
 	switch f := env.Resolve(flag).(type) {
@@ -2551,7 +2671,7 @@ func (state *State) CurrentPrologFlag(flag, value Term, k func(*Env) *Promise, e
 		break
 	case Atom:
 		switch f {
-		case "bounded", "max_integer", "min_integer", "integer_rounding_function", "char_conversion", "debug", "max_arity", "unknown", "double_quotes":
+		case "bounded", "max_integer", "min_integer", "integer_rounding_function", "char_conversion", "debug", "max_arity", "unknown", "double_quotes", "answer_write_options", "argv":
 			break
 		default:
 			return Error(domainErrorPrologFlag(f))
@@ -2567,10 +2687,12 @@ func (state *State) CurrentPrologFlag(flag, value Term, k func(*Env) *Promise, e
 		&Compound{Args: []Term{Atom("min_integer"), Integer(math.MinInt64)}},
 		&Compound{Args: []Term{Atom("integer_rounding_function"), Atom("toward_zero")}},
 		&Compound{Args: []Term{Atom("char_conversion"), onOff(state.charConvEnabled)}},
-		&Compound{Args: []Term{Atom("debug"), onOff(state.debug)}},
+		&Compound{Args: []Term{Atom("debug"), onOff(state.debugger.enabled)}},
 		&Compound{Args: []Term{Atom("max_arity"), Atom("unbounded")}},
 		&Compound{Args: []Term{Atom("unknown"), Atom(state.unknown.String())}},
 		&Compound{Args: []Term{Atom("double_quotes"), Atom(state.doubleQuotes.String())}},
+		&Compound{Args: []Term{Atom("answer_write_options"), state.answerWriteOptionsTerm()}},
+		&Compound{Args: []Term{Atom("argv"), state.argvTerm()}},
 	}
 	ks := make([]func(context.Context) *Promise, len(flags))
 	for i := range flags {
@@ -2656,7 +2778,7 @@ func (state *State) BuiltIn(pi Term, k func(*Env) *Promise, env *Env) *Promise {
 	return k(env)
 }
 
-// ExpandTerm transforms term1 according to term_expansion/2 and DCG rules then unifies with term2.
+// ExpandTerm transforms term1 according to term_expansion/2, DCG rules, and goal_expansion/2 then unifies with term2.
 func (state *State) ExpandTerm(term1, term2 Term, k func(*Env) *Promise, env *Env) *Promise {
 	t, err := state.Expand(term1, env)
 	if err != nil {
@@ -2666,8 +2788,37 @@ func (state *State) ExpandTerm(term1, term2 Term, k func(*Env) *Promise, env *En
 	return Unify(t, term2, k, env)
 }
 
-// Expand expands term according to term_expansion/2 and DCG rules.
+// Expand expands term according to term_expansion/2 and DCG rules, then expands the goals in the bodies according to
+// goal_expansion/2. If term_expansion/2 returns a list, the result is the list of the expanded clauses.
 func (state *State) Expand(term Term, env *Env) (Term, error) {
+	t, err := state.expandTerm(term, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if !state.hasGoalExpansion() {
+		return t, nil
+	}
+
+	if c, ok := env.Resolve(t).(*Compound); ok && c.Functor == "." && len(c.Args) == 2 {
+		var ts []Term
+		iter := ListIterator{List: c, Env: env}
+		for iter.Next() {
+			t, err := state.expandBody(iter.Current(), env)
+			if err != nil {
+				return nil, err
+			}
+			ts = append(ts, t)
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+		return List(ts...), nil
+	}
+	return state.expandBody(t, env)
+}
+
+func (state *State) expandTerm(term Term, env *Env) (Term, error) {
 	const termExpansion = Atom("term_expansion")
 
 	if _, ok := state.procedures[ProcedureIndicator{Name: termExpansion, Arity: 2}]; ok {
@@ -2692,6 +2843,188 @@ func (state *State) Expand(term Term, env *Env) (Term, error) {
 	return t, err
 }
 
+func (state *State) hasGoalExpansion() bool {
+	_, ok := state.procedures[ProcedureIndicator{Name: "goal_expansion", Arity: 2}]
+	return ok
+}
+
+// expandBody expands the goals in the body of the clause or the directive.
+func (state *State) expandBody(clause Term, env *Env) (Term, error) {
+	c, ok := env.Resolve(clause).(*Compound)
+	if !ok || c.Functor != ":-" {
+		return clause, nil
+	}
+
+	switch len(c.Args) {
+	case 1:
+		g, env, err := state.expandGoal(c.Args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		return env.Simplify(&Compound{Functor: ":-", Args: []Term{g}}), nil
+	case 2:
+		g, env, err := state.expandGoal(c.Args[1], env)
+		if err != nil {
+			return nil, err
+		}
+		return env.Simplify(&Compound{Functor: ":-", Args: []Term{c.Args[0], g}}), nil
+	default:
+		return clause, nil
+	}
+}
+
+// goalArgs is the positions of the arguments which are goals for control constructs and built-in predicates.
+var goalArgs = map[ProcedureIndicator][]int{
+	{Name: ",", Arity: 2}:       {0, 1},
+	{Name: ";", Arity: 2}:       {0, 1},
+	{Name: "->", Arity: 2}:      {0, 1},
+	{Name: "*->", Arity: 2}:     {0, 1},
+	{Name: "\\+", Arity: 1}:     {0},
+	{Name: "call", Arity: 1}:    {0},
+	{Name: "once", Arity: 1}:    {0},
+	{Name: "ignore", Arity: 1}:  {0},
+	{Name: "forall", Arity: 2}:  {0, 1},
+	{Name: "findall", Arity: 3}: {1},
+	{Name: "findall", Arity: 4}: {1},
+	{Name: "bagof", Arity: 3}:   {1},
+	{Name: "setof", Arity: 3}:   {1},
+	{Name: "^", Arity: 2}:       {1},
+	{Name: "catch", Arity: 3}:   {0, 2},
+}
+
+// expandGoal applies goal_expansion/2 to goal until it fails or returns the same goal, then to the subgoals.
+// It returns the environment with the bindings made by goal_expansion/2.
+func (state *State) expandGoal(goal Term, env *Env) (Term, *Env, error) {
+	return state.expandGoalSeen(goal, nil, env)
+}
+
+// expandGoalSeen is expandGoal which doesn't apply goal_expansion/2 to a variant of the goals in seen, the goals
+// that led to goal by expansion. Thus, an expansion like goal_expansion(a, (a, true)) doesn't loop.
+func (state *State) expandGoalSeen(goal Term, seen []Term, env *Env) (Term, *Env, error) {
+	const goalExpansion = Atom("goal_expansion")
+
+	for {
+		if _, ok := env.Resolve(goal).(Variable); ok {
+			return goal, env, nil
+		}
+		if variantOfAny(goal, seen, env) {
+			break
+		}
+
+		var (
+			ret    Term
+			retEnv *Env
+		)
+		v := NewVariable()
+		ok, err := state.Call(goalExpansion.Apply(goal, v), func(env *Env) *Promise {
+			ret, retEnv = v, env
+			return Bool(true)
+		}, env).Force(context.Background())
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok || retEnv.Resolve(ret).Compare(goal, retEnv) == 0 {
+			break
+		}
+		seen = append(seen[:len(seen):len(seen)], goal)
+		goal, env = ret, retEnv
+	}
+
+	c, ok := env.Resolve(goal).(*Compound)
+	if !ok {
+		return goal, env, nil
+	}
+	if c.Functor == "call" && len(c.Args) > 1 {
+		return state.expandClosure(c, seen, env)
+	}
+	pos, ok := goalArgs[ProcedureIndicator{Name: c.Functor, Arity: Integer(len(c.Args))}]
+	if !ok {
+		return goal, env, nil
+	}
+	args := make([]Term, len(c.Args))
+	copy(args, c.Args)
+	for _, i := range pos {
+		g, e, err := state.expandGoalSeen(args[i], seen, env)
+		if err != nil {
+			return nil, nil, err
+		}
+		args[i], env = g, e
+	}
+	return &Compound{Functor: c.Functor, Args: args}, env, nil
+}
+
+// expandClosure expands call(Closure, Arg1, ...) as the goal of Closure with the extra arguments. If the goal is
+// expanded, it returns call/1 of the expanded goal.
+func (state *State) expandClosure(c *Compound, seen []Term, env *Env) (Term, *Env, error) {
+	goal, err := closureGoal(c.Args[0], c.Args[1:], env)
+	if err != nil {
+		// The closure is unknown until the execution.
+		return c, env, nil
+	}
+	g, env, err := state.expandGoalSeen(goal, seen, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	if env.Resolve(g).Compare(goal, env) == 0 {
+		return c, env, nil
+	}
+	return &Compound{Functor: "call", Args: []Term{g}}, env, nil
+}
+
+// variantOfAny checks if t is a variant of any of ts.
+func variantOfAny(t Term, ts []Term, env *Env) bool {
+	for _, u := range ts {
+		if variant(t, u, map[Variable]Variable{}, map[Variable]Variable{}, env) {
+			return true
+		}
+	}
+	return false
+}
+
+// variant checks if t1 and t2 are equal up to the renaming of variables.
+func variant(t1, t2 Term, m1, m2 map[Variable]Variable, env *Env) bool {
+	switch t1 := env.Resolve(t1).(type) {
+	case Variable:
+		t2, ok := env.Resolve(t2).(Variable)
+		if !ok {
+			return false
+		}
+		v1, ok1 := m1[t1]
+		v2, ok2 := m2[t2]
+		if ok1 || ok2 {
+			return v1 == t2 && v2 == t1
+		}
+		m1[t1], m2[t2] = t2, t1
+		return true
+	case *Compound:
+		t2, ok := env.Resolve(t2).(*Compound)
+		if !ok || t1.Functor != t2.Functor || len(t1.Args) != len(t2.Args) {
+			return false
+		}
+		for i := range t1.Args {
+			if !variant(t1.Args[i], t2.Args[i], m1, m2, env) {
+				return false
+			}
+		}
+		return true
+	default:
+		return t1.Compare(t2, env) == 0
+	}
+}
+
+// ExpandGoal transforms goal1 according to goal_expansion/2 then unifies with goal2.
+func (state *State) ExpandGoal(goal1, goal2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	if !state.hasGoalExpansion() {
+		return Unify(goal1, goal2, k, env)
+	}
+
+	g, env, err := state.expandGoal(goal1, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Unify(g, goal2, k, env)
+}
+
 // Environ succeeds if an environment variable key has value.
 func Environ(key, value Term, k func(*Env) *Promise, env *Env) *Promise {
 	lines := os.Environ()
@@ -198,13 +198,13 @@ func TestState_Call(t *testing.T) {
 
 	t.Run("variable", func(t *testing.T) {
 		t.Run("single predicate", func(t *testing.T) {
-			ok, err := state.Call(Variable("X"), Success, nil).Force(context.Background())
+			ok, err := state.Call(testVar("X"), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
 		})
 
 		t.Run("multiple predicates", func(t *testing.T) {
-			x := Variable("X")
+			x := testVar("X")
 			state.Register0("fail", func(f func(*Env) *Promise, env *Env) *Promise {
 				return Bool(false)
 			})
@@ -282,7 +282,7 @@ func TestState_Call1(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call1(Variable("P"), Atom("a"), Success, nil).Force(context.Background())
+		_, err := state.Call1(testVar("P"), Atom("a"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -308,7 +308,7 @@ func TestState_Call2(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call2(Variable("P"), Atom("a"), Atom("b"), Success, nil).Force(context.Background())
+		_, err := state.Call2(testVar("P"), Atom("a"), Atom("b"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -334,7 +334,7 @@ func TestState_Call3(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call3(Variable("P"), Atom("a"), Atom("b"), Atom("c"), Success, nil).Force(context.Background())
+		_, err := state.Call3(testVar("P"), Atom("a"), Atom("b"), Atom("c"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -360,7 +360,7 @@ func TestState_Call4(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call4(Variable("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Success, nil).Force(context.Background())
+		_, err := state.Call4(testVar("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -386,7 +386,7 @@ func TestState_Call5(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call5(Variable("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Success, nil).Force(context.Background())
+		_, err := state.Call5(testVar("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -412,7 +412,7 @@ func TestState_Call6(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call6(Variable("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Success, nil).Force(context.Background())
+		_, err := state.Call6(testVar("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -438,7 +438,7 @@ func TestState_Call7(t *testing.T) {
 
 	t.Run("closure is a variable", func(t *testing.T) {
 		var state State
-		_, err := state.Call7(Variable("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Success, nil).Force(context.Background())
+		_, err := state.Call7(testVar("P"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -449,9 +449,36 @@ func TestState_Call7(t *testing.T) {
 	})
 }
 
+func TestState_CallN(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		state := State{VM: VM{procedures: map[ProcedureIndicator]procedure{
+			{Name: "p", Arity: 10}: predicateN{arity: 10, p: func(args []Term, k func(*Env) *Promise, env *Env) *Promise {
+				assert.Equal(t, []Term{Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i"), Atom("j")}, args)
+				return k(env)
+			}},
+		}}}
+
+		ok, err := state.CallN(&Compound{Functor: "p", Args: []Term{Atom("a")}}, []Term{Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i"), Atom("j")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("closure is a variable", func(t *testing.T) {
+		var state State
+		_, err := state.CallN(testVar("P"), []Term{Atom("a")}, Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("closure is neither a variable nor a callable term", func(t *testing.T) {
+		var state State
+		_, err := state.CallN(Integer(3), []Term{Atom("a")}, Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorCallable(Integer(3)), err)
+	})
+}
+
 func TestUnify(t *testing.T) {
 	t.Run("unifiable", func(t *testing.T) {
-		x := Variable("X")
+		x := testVar("X")
 		ok, err := Unify(x, &Compound{
 			Functor: "f",
 			Args:    []Term{Atom("a")},
@@ -476,7 +503,7 @@ func TestUnify(t *testing.T) {
 	})
 
 	t.Run("loop", func(t *testing.T) {
-		x := Variable("X")
+		x := testVar("X")
 		ok, err := Unify(x, &Compound{
 			Functor: "f",
 			Args:    []Term{x},
@@ -488,7 +515,7 @@ func TestUnify(t *testing.T) {
 
 func TestUnifyWithOccursCheck(t *testing.T) {
 	t.Run("unifiable", func(t *testing.T) {
-		x := Variable("X")
+		x := testVar("X")
 		ok, err := UnifyWithOccursCheck(x, &Compound{
 			Functor: "f",
 			Args:    []Term{Atom("a")},
@@ -514,7 +541,7 @@ func TestUnifyWithOccursCheck(t *testing.T) {
 	})
 
 	t.Run("loop", func(t *testing.T) {
-		x := Variable("X")
+		x := testVar("X")
 		ok, err := UnifyWithOccursCheck(x, &Compound{
 			Functor: "f",
 			Args:    []Term{x},
@@ -526,7 +553,7 @@ func TestUnifyWithOccursCheck(t *testing.T) {
 
 func TestSubsumesTerm(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		ok, err := SubsumesTerm(Variable("X"), Atom("a"), Success, nil).Force(context.Background())
+		ok, err := SubsumesTerm(testVar("X"), Atom("a"), Success, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.True(t, ok)
 	})
@@ -538,7 +565,7 @@ func TestSubsumesTerm(t *testing.T) {
 	})
 
 	t.Run("specific-general", func(t *testing.T) {
-		ok, err := SubsumesTerm(Atom("a"), Variable("X"), Success, nil).Force(context.Background())
+		ok, err := SubsumesTerm(Atom("a"), testVar("X"), Success, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.False(t, ok)
 	})
@@ -645,7 +672,7 @@ func TestAcyclicTerm(t *testing.T) {
 func TestFunctor(t *testing.T) {
 	t.Run("term is instantiated", func(t *testing.T) {
 		t.Run("float", func(t *testing.T) {
-			name, arity := Variable("Name"), Variable("Arity")
+			name, arity := testVar("Name"), testVar("Arity")
 			ok, err := Functor(Float(2.0), name, arity, func(env *Env) *Promise {
 				assert.Equal(t, Float(2.0), env.Resolve(name))
 				assert.Equal(t, Integer(0), env.Resolve(arity))
@@ -867,7 +894,7 @@ func TestUniv(t *testing.T) {
 		})
 
 		t.Run("list is not fully instantiated", func(t *testing.T) {
-			ok, err := Univ(NewVariable(), ListRest(Variable("Rest"), Atom("f"), Atom("a"), Atom("b")), Success, nil).Force(context.Background())
+			ok, err := Univ(NewVariable(), ListRest(testVar("Rest"), Atom("f"), Atom("a"), Atom("b")), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
 		})
@@ -890,8 +917,8 @@ func TestUniv(t *testing.T) {
 }
 
 func TestCopyTerm(t *testing.T) {
-	in := Variable("In")
-	out := Variable("Out")
+	in := testVar("In")
+	out := testVar("Out")
 	env := NewEnv().
 		Bind(in, Atom("a"))
 	ok, err := CopyTerm(in, out, func(env *Env) *Promise {
@@ -906,7 +933,7 @@ func TestTermVariables(t *testing.T) {
 	ok, err := TermVariables(&Compound{
 		Functor: "+",
 		Args: []Term{
-			Variable("A"),
+			testVar("A"),
 			&Compound{
 				Functor: "-",
 				Args: []Term{
@@ -916,19 +943,19 @@ func TestTermVariables(t *testing.T) {
 							&Compound{
 								Functor: "*",
 								Args: []Term{
-									Variable("B"),
-									Variable("C"),
+									testVar("B"),
+									testVar("C"),
 								},
 							},
-							Variable("B"),
+							testVar("B"),
 						},
 					},
-					Variable("D"),
+					testVar("D"),
 				},
 			},
 		},
-	}, Variable("Vars"), func(env *Env) *Promise {
-		assert.Equal(t, List(Variable("A"), Variable("B"), Variable("C"), Variable("D")), env.Resolve(Variable("Vars")))
+	}, testVar("Vars"), func(env *Env) *Promise {
+		assert.Equal(t, List(testVar("A"), testVar("B"), testVar("C"), testVar("D")), env.Resolve(testVar("Vars")))
 		return Bool(true)
 	}, nil).Force(context.Background())
 	assert.NoError(t, err)
@@ -1084,7 +1111,7 @@ func TestState_CurrentOp(t *testing.T) {
 
 	t.Run("multiple solutions", func(t *testing.T) {
 		var (
-			priority, specifier, operator = Variable("Priority"), Variable("Specifier"), Variable("Operator")
+			priority, specifier, operator = testVar("Priority"), testVar("Specifier"), testVar("Operator")
 			c                             int
 		)
 		ok, err := state.CurrentOp(priority, specifier, operator, func(env *Env) *Promise {
@@ -1153,33 +1180,33 @@ func TestState_BagOf(t *testing.T) {
 				procedures: map[ProcedureIndicator]procedure{
 					{Name: "foo", Arity: 3}: clauses{
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("c")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("d")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("e")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("f")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("c"), Atom("c"), Atom("g")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 					},
@@ -1190,7 +1217,7 @@ func TestState_BagOf(t *testing.T) {
 		t.Run("without qualifier", func(t *testing.T) {
 			var (
 				count       int
-				a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+				a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 			)
 			ok, err := state.BagOf(c, &Compound{
 				Functor: "foo",
@@ -1222,7 +1249,7 @@ func TestState_BagOf(t *testing.T) {
 		t.Run("with qualifier", func(t *testing.T) {
 			var (
 				count       int
-				a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+				a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 			)
 			ok, err := state.BagOf(c, &Compound{
 				Functor: "^",
@@ -1251,7 +1278,7 @@ func TestState_BagOf(t *testing.T) {
 		t.Run("with multiple qualifiers", func(t *testing.T) {
 			var (
 				count       int
-				a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+				a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 			)
 			ok, err := state.BagOf(c, &Compound{
 				Functor: "^",
@@ -1282,7 +1309,7 @@ func TestState_BagOf(t *testing.T) {
 
 	t.Run("goal is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.BagOf(NewVariable(), Variable("Goal"), NewVariable(), Success, nil).Force(context.Background())
+		ok, err := state.BagOf(NewVariable(), testVar("Goal"), NewVariable(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -1310,7 +1337,7 @@ func TestState_BagOf(t *testing.T) {
 		}
 
 		t.Run("variable", func(t *testing.T) {
-			x, xs := Variable("X"), Variable("Xs")
+			x, xs := testVar("X"), testVar("Xs")
 			ok, err := state.BagOf(x, &Compound{
 				Functor: "foo",
 				Args:    []Term{x},
@@ -1324,7 +1351,7 @@ func TestState_BagOf(t *testing.T) {
 
 		t.Run("not variable", func(t *testing.T) {
 			count := 0
-			x, xs := Variable("X"), Variable("Xs")
+			x, xs := testVar("X"), testVar("Xs")
 			ok, err := state.BagOf(Atom("c"), &Compound{
 				Functor: "foo",
 				Args:    []Term{x},
@@ -1349,7 +1376,7 @@ func TestState_BagOf(t *testing.T) {
 		t.Run("complex", func(t *testing.T) {
 			// bagof(X, X = Y; X = Z; Y = a, Xs).
 			count := 0
-			x, y, z, xs := Variable("X"), Variable("Y"), Variable("Z"), Variable("Xs")
+			x, y, z, xs := testVar("X"), testVar("Y"), testVar("Z"), testVar("Xs")
 			var state State
 			state.Register2("=", Unify)
 			ok, err := state.BagOf(x, &Compound{
@@ -1392,51 +1419,51 @@ func TestState_SetOf(t *testing.T) {
 				procedures: map[ProcedureIndicator]procedure{
 					{Name: "foo", Arity: 3}: clauses{
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("c")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("d")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("c")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("e")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("f")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("e")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("c"), Atom("c"), Atom("g")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("c"), Atom("c"), Atom("g")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 					},
@@ -1447,7 +1474,7 @@ func TestState_SetOf(t *testing.T) {
 		t.Run("without qualifier", func(t *testing.T) {
 			var (
 				count       int
-				a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+				a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 			)
 			ok, err := state.SetOf(c, &Compound{
 				Functor: "foo",
@@ -1479,7 +1506,7 @@ func TestState_SetOf(t *testing.T) {
 		t.Run("with qualifier", func(t *testing.T) {
 			var (
 				count       int
-				a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+				a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 			)
 			ok, err := state.SetOf(c, &Compound{
 				Functor: "^",
@@ -1508,7 +1535,7 @@ func TestState_SetOf(t *testing.T) {
 		t.Run("with multiple qualifiers", func(t *testing.T) {
 			var (
 				count       int
-				a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+				a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 			)
 			ok, err := state.SetOf(c, &Compound{
 				Functor: "^",
@@ -1539,7 +1566,7 @@ func TestState_SetOf(t *testing.T) {
 
 	t.Run("goal is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.SetOf(NewVariable(), Variable("Goal"), NewVariable(), Success, nil).Force(context.Background())
+		ok, err := state.SetOf(NewVariable(), testVar("Goal"), NewVariable(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -1559,33 +1586,33 @@ func TestState_FindAll(t *testing.T) {
 				procedures: map[ProcedureIndicator]procedure{
 					{Name: "foo", Arity: 3}: clauses{
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("c")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("a"), Atom("b"), Atom("d")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("e")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("b"), Atom("c"), Atom("f")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 						{xrTable: []Term{Atom("c"), Atom("c"), Atom("g")}, bytecode: bytecode{
-							{opcode: opConst, operand: 0},
-							{opcode: opConst, operand: 1},
-							{opcode: opConst, operand: 2},
+							{opcode: opGetConst, operand: 0},
+							{opcode: opGetConst, operand: 1},
+							{opcode: opGetConst, operand: 2},
 							{opcode: opExit},
 						}},
 					},
@@ -1595,7 +1622,7 @@ func TestState_FindAll(t *testing.T) {
 
 		var (
 			count       int
-			a, b, c, cs = Variable("A"), Variable("B"), Variable("C"), Variable("Cs")
+			a, b, c, cs = testVar("A"), testVar("B"), testVar("C"), testVar("Cs")
 		)
 		ok, err := state.FindAll(c, &Compound{
 			Functor: "foo",
@@ -1616,7 +1643,7 @@ func TestState_FindAll(t *testing.T) {
 
 	t.Run("goal is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.FindAll(NewVariable(), Variable("Goal"), NewVariable(), Success, nil).Force(context.Background())
+		ok, err := state.FindAll(NewVariable(), testVar("Goal"), NewVariable(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -1629,7 +1656,7 @@ func TestState_FindAll(t *testing.T) {
 	})
 
 	t.Run("goal fails", func(t *testing.T) {
-		instances := Variable("instances")
+		instances := testVar("instances")
 
 		state := State{
 			VM: VM{
@@ -1684,7 +1711,7 @@ func TestCompare(t *testing.T) {
 	})
 
 	t.Run("detect order", func(t *testing.T) {
-		order := Variable("Order")
+		order := testVar("Order")
 		ok, err := Compare(order, Atom("a"), Atom("b"), func(env *Env) *Promise {
 			assert.Equal(t, Atom("<"), env.Resolve(order))
 			return Bool(true)
@@ -1709,7 +1736,7 @@ func TestCompare(t *testing.T) {
 func TestSort(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		t.Run("variable", func(t *testing.T) {
-			sorted := Variable("Sorted")
+			sorted := testVar("Sorted")
 			ok, err := Sort(List(Atom("a"), Atom("c"), Atom("b"), Atom("a")), sorted, func(env *Env) *Promise {
 				assert.Equal(t, List(Atom("a"), Atom("b"), Atom("c")), env.Resolve(sorted))
 				return Bool(true)
@@ -1726,12 +1753,12 @@ func TestSort(t *testing.T) {
 	})
 
 	t.Run("list is a partial list", func(t *testing.T) {
-		_, err := Sort(ListRest(Variable("X"), Atom("a"), Atom("b")), Variable("Sorted"), Success, nil).Force(context.Background())
+		_, err := Sort(ListRest(testVar("X"), Atom("a"), Atom("b")), testVar("Sorted"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
 	t.Run("list is neither a partial list nor a list", func(t *testing.T) {
-		_, err := Sort(Atom("a"), Variable("Sorted"), Success, nil).Force(context.Background())
+		_, err := Sort(Atom("a"), testVar("Sorted"), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeError("list", Atom("a")), err)
 	})
 
@@ -1751,7 +1778,7 @@ func TestSort(t *testing.T) {
 func TestKeySort(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		t.Run("variable", func(t *testing.T) {
-			sorted := Variable("Sorted")
+			sorted := testVar("Sorted")
 			ok, err := KeySort(List(
 				Pair(Atom("c"), Atom("4")),
 				Pair(Atom("b"), Atom("3")),
@@ -1771,7 +1798,7 @@ func TestKeySort(t *testing.T) {
 		})
 
 		t.Run("list", func(t *testing.T) {
-			second := Variable("Second")
+			second := testVar("Second")
 			ok, err := KeySort(List(
 				Pair(Atom("c"), Atom("4")),
 				Pair(Atom("b"), Atom("3")),
@@ -1792,7 +1819,7 @@ func TestKeySort(t *testing.T) {
 	})
 
 	t.Run("pairs is a partial list", func(t *testing.T) {
-		_, err := KeySort(ListRest(Variable("Rest"), Pair(Atom("a"), Integer(1))), NewVariable(), Success, nil).Force(context.Background())
+		_, err := KeySort(ListRest(testVar("Rest"), Pair(Atom("a"), Integer(1))), NewVariable(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -1807,7 +1834,7 @@ func TestKeySort(t *testing.T) {
 	})
 
 	t.Run("an element of a list prefix of pairs is a variable", func(t *testing.T) {
-		_, err := KeySort(List(Variable("X")), NewVariable(), Success, nil).Force(context.Background())
+		_, err := KeySort(List(testVar("X")), NewVariable(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 	})
 
@@ -1844,7 +1871,7 @@ func TestThrow(t *testing.T) {
 	})
 
 	t.Run("ball is a variable", func(t *testing.T) {
-		ok, err := Throw(Variable("Ball"), Success, nil).Force(context.Background())
+		ok, err := Throw(testVar("Ball"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -1906,6 +1933,58 @@ func TestState_Catch(t *testing.T) {
 	})
 }
 
+func TestState_SetupCallCleanup(t *testing.T) {
+	var (
+		state State
+		log   []Atom
+	)
+	state.Register1("log", func(t Term, k func(*Env) *Promise, env *Env) *Promise {
+		log = append(log, env.Resolve(t).(Atom))
+		return k(env)
+	})
+	state.Register0("fail", func(func(*Env) *Promise, *Env) *Promise {
+		return Bool(false)
+	})
+	state.Register1("throw", Throw)
+	logGoal := func(a Atom) Term {
+		return &Compound{Functor: "log", Args: []Term{a}}
+	}
+
+	t.Run("deterministic exit", func(t *testing.T) {
+		log = nil
+		ok, err := state.SetupCallCleanup(logGoal("setup"), logGoal("goal"), logGoal("cleanup"), func(env *Env) *Promise {
+			log = append(log, "exit")
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Atom{"setup", "goal", "cleanup", "exit"}, log)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		log = nil
+		ok, err := state.SetupCallCleanup(logGoal("setup"), Atom("fail"), logGoal("cleanup"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Atom{"setup", "cleanup"}, log)
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		log = nil
+		_, err := state.SetupCallCleanup(logGoal("setup"), &Compound{Functor: "throw", Args: []Term{Atom("e")}}, logGoal("cleanup"), Success, nil).Force(context.Background())
+		assert.Equal(t, &Exception{Term: Atom("e")}, err)
+		assert.Equal(t, []Atom{"setup", "cleanup"}, log)
+	})
+
+	t.Run("setup fails", func(t *testing.T) {
+		log = nil
+		ok, err := state.SetupCallCleanup(Atom("fail"), logGoal("goal"), logGoal("cleanup"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Empty(t, log)
+	})
+}
+
 func TestState_CurrentPredicate(t *testing.T) {
 	t.Run("user defined predicate", func(t *testing.T) {
 		state := State{VM: VM{procedures: map[ProcedureIndicator]procedure{
@@ -1925,7 +2004,7 @@ func TestState_CurrentPredicate(t *testing.T) {
 	t.Run("variable", func(t *testing.T) {
 		var foo, bar, baz bool
 
-		v := Variable("V")
+		v := testVar("V")
 
 		state := State{VM: VM{procedures: map[ProcedureIndicator]procedure{
 			{Name: "foo", Arity: 1}: clauses{},
@@ -2054,7 +2133,7 @@ func TestState_Assertz(t *testing.T) {
 				},
 				xrTable: []Term{Atom("a")},
 				bytecode: bytecode{
-					{opcode: opConst, operand: 0},
+					{opcode: opGetConst, operand: 0},
 					{opcode: opExit},
 				},
 			},
@@ -2069,18 +2148,18 @@ func TestState_Assertz(t *testing.T) {
 				},
 				xrTable: []Term{Atom("b")},
 				bytecode: bytecode{
-					{opcode: opConst, operand: 0},
+					{opcode: opGetConst, operand: 0},
 					{opcode: opExit},
 				},
 			},
-		}, state.procedures[ProcedureIndicator{
+		}, withoutClauseIDs(state.procedures[ProcedureIndicator{
 			Name:  "foo",
 			Arity: 1,
-		}])
+		}]))
 	})
 
 	t.Run("clause is a variable", func(t *testing.T) {
-		clause := Variable("Term")
+		clause := testVar("Term")
 
 		var state State
 		ok, err := state.Assertz(clause, Success, nil).Force(context.Background())
@@ -2096,7 +2175,7 @@ func TestState_Assertz(t *testing.T) {
 	})
 
 	t.Run("head is a variable", func(t *testing.T) {
-		head := Variable("Head")
+		head := testVar("Head")
 
 		var state State
 		ok, err := state.Assertz(&Compound{
@@ -2209,7 +2288,7 @@ func TestState_Asserta(t *testing.T) {
 				},
 				xrTable: []Term{Atom("b")},
 				bytecode: bytecode{
-					{opcode: opConst, operand: 0},
+					{opcode: opGetConst, operand: 0},
 					{opcode: opExit},
 				},
 			},
@@ -2221,11 +2300,11 @@ func TestState_Asserta(t *testing.T) {
 				},
 				xrTable: []Term{Atom("a")},
 				bytecode: bytecode{
-					{opcode: opConst, operand: 0},
+					{opcode: opGetConst, operand: 0},
 					{opcode: opExit},
 				},
 			},
-		}, state.procedures[ProcedureIndicator{Name: "foo", Arity: 1}])
+		}, withoutClauseIDs(state.procedures[ProcedureIndicator{Name: "foo", Arity: 1}]))
 	})
 
 	t.Run("rule", func(t *testing.T) {
@@ -2285,7 +2364,7 @@ func TestState_Asserta(t *testing.T) {
 				piTable: []ProcedureIndicator{{Name: "p", Arity: 1}},
 				bytecode: bytecode{
 					{opcode: opEnter},
-					{opcode: opConst, operand: 0},
+					{opcode: opPutConst, operand: 0},
 					{opcode: opCall, operand: 0},
 					{opcode: opCut},
 					{opcode: opExit},
@@ -2307,16 +2386,16 @@ func TestState_Asserta(t *testing.T) {
 				piTable: []ProcedureIndicator{{Name: "p", Arity: 1}},
 				bytecode: bytecode{
 					{opcode: opEnter},
-					{opcode: opConst, operand: 0},
+					{opcode: opPutConst, operand: 0},
 					{opcode: opCall, operand: 0},
 					{opcode: opExit},
 				},
 			},
-		}, state.procedures[ProcedureIndicator{Name: "foo", Arity: 0}])
+		}, withoutClauseIDs(state.procedures[ProcedureIndicator{Name: "foo", Arity: 0}]))
 	})
 
 	t.Run("clause is a variable", func(t *testing.T) {
-		clause := Variable("Term")
+		clause := testVar("Term")
 
 		var state State
 		ok, err := state.Asserta(clause, Success, nil).Force(context.Background())
@@ -2332,7 +2411,7 @@ func TestState_Asserta(t *testing.T) {
 	})
 
 	t.Run("head is a variable", func(t *testing.T) {
-		head := Variable("Head")
+		head := testVar("Head")
 
 		var state State
 		ok, err := state.Asserta(&Compound{
@@ -2466,7 +2545,7 @@ func TestState_Assert(t *testing.T) {
 				},
 				xrTable: []Term{Atom("a")},
 				bytecode: bytecode{
-					{opcode: opConst, operand: 0},
+					{opcode: opGetConst, operand: 0},
 					{opcode: opExit},
 				},
 			},
@@ -2481,18 +2560,18 @@ func TestState_Assert(t *testing.T) {
 				},
 				xrTable: []Term{Atom("b")},
 				bytecode: bytecode{
-					{opcode: opConst, operand: 0},
+					{opcode: opGetConst, operand: 0},
 					{opcode: opExit},
 				},
 			},
-		}}, state.procedures[ProcedureIndicator{
+		}}, withoutClauseIDs(state.procedures[ProcedureIndicator{
 			Name:  "foo",
 			Arity: 1,
-		}])
+		}]))
 	})
 
 	t.Run("clause is a variable", func(t *testing.T) {
-		clause := Variable("Term")
+		clause := testVar("Term")
 
 		var state State
 		assert.Equal(t, ErrInstantiation, state.Assert(clause, nil))
@@ -2504,7 +2583,7 @@ func TestState_Assert(t *testing.T) {
 	})
 
 	t.Run("head is a variable", func(t *testing.T) {
-		head := Variable("Head")
+		head := testVar("Head")
 
 		var state State
 		assert.Equal(t, ErrInstantiation, state.Assert(&Compound{
@@ -2618,7 +2697,7 @@ func TestState_Retract(t *testing.T) {
 
 		ok, err := state.Retract(&Compound{
 			Functor: "foo",
-			Args:    []Term{Variable("X")},
+			Args:    []Term{testVar("X")},
 		}, Success, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.True(t, ok)
@@ -2670,7 +2749,7 @@ func TestState_Retract(t *testing.T) {
 
 		ok, err := state.Retract(&Compound{
 			Functor: "foo",
-			Args:    []Term{Variable("X")},
+			Args:    []Term{testVar("X")},
 		}, Failure, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.False(t, ok)
@@ -2679,7 +2758,7 @@ func TestState_Retract(t *testing.T) {
 
 	t.Run("variable", func(t *testing.T) {
 		var state State
-		ok, err := state.Retract(Variable("X"), Success, nil).Force(context.Background())
+		ok, err := state.Retract(testVar("X"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -2696,7 +2775,7 @@ func TestState_Retract(t *testing.T) {
 
 		ok, err := state.Retract(&Compound{
 			Functor: "foo",
-			Args:    []Term{Variable("X")},
+			Args:    []Term{testVar("X")},
 		}, Success, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.False(t, ok)
@@ -2732,7 +2811,7 @@ func TestState_Retract(t *testing.T) {
 
 		ok, err := state.Retract(&Compound{
 			Functor: "foo",
-			Args:    []Term{Variable("X")},
+			Args:    []Term{testVar("X")},
 		}, func(_ *Env) *Promise {
 			return Error(errors.New("failed"))
 		}, nil).Force(context.Background())
@@ -2771,7 +2850,7 @@ func TestState_Abolish(t *testing.T) {
 
 	t.Run("pi is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.Abolish(Variable("PI"), Success, nil).Force(context.Background())
+		ok, err := state.Abolish(testVar("PI"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -2781,7 +2860,7 @@ func TestState_Abolish(t *testing.T) {
 			var state State
 			ok, err := state.Abolish(&Compound{
 				Functor: "/",
-				Args:    []Term{Variable("Name"), Integer(2)},
+				Args:    []Term{testVar("Name"), Integer(2)},
 			}, Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
@@ -2791,7 +2870,7 @@ func TestState_Abolish(t *testing.T) {
 			var state State
 			ok, err := state.Abolish(&Compound{
 				Functor: "/",
-				Args:    []Term{Atom("foo"), Variable("Arity")},
+				Args:    []Term{Atom("foo"), testVar("Arity")},
 			}, Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
@@ -2897,7 +2976,7 @@ func TestState_CurrentOutput(t *testing.T) {
 
 func TestState_SetInput(t *testing.T) {
 	t.Run("stream", func(t *testing.T) {
-		v := Variable("Stream")
+		v := testVar("Stream")
 		s := NewStream(os.Stdin, StreamModeRead)
 		env := NewEnv().
 			Bind(v, s)
@@ -2909,7 +2988,7 @@ func TestState_SetInput(t *testing.T) {
 	})
 
 	t.Run("alias", func(t *testing.T) {
-		v := Variable("Stream")
+		v := testVar("Stream")
 		s := NewStream(os.Stdin, StreamModeRead)
 		env := NewEnv().
 			Bind(v, s)
@@ -2926,7 +3005,7 @@ func TestState_SetInput(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.SetInput(Variable("Stream"), Success, nil).Force(context.Background())
+		ok, err := state.SetInput(testVar("Stream"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -2946,7 +3025,7 @@ func TestState_SetInput(t *testing.T) {
 	})
 
 	t.Run("streamOrAlias is an output stream", func(t *testing.T) {
-		v := Variable("Stream")
+		v := testVar("Stream")
 		env := NewEnv().
 			Bind(v, NewStream(os.Stdout, StreamModeWrite))
 		var state State
@@ -2958,7 +3037,7 @@ func TestState_SetInput(t *testing.T) {
 
 func TestState_SetOutput(t *testing.T) {
 	t.Run("stream", func(t *testing.T) {
-		v := Variable("Stream")
+		v := testVar("Stream")
 		s := NewStream(os.Stdout, StreamModeWrite)
 		env := NewEnv().
 			Bind(v, s)
@@ -2984,7 +3063,7 @@ func TestState_SetOutput(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.SetOutput(Variable("Stream"), Success, nil).Force(context.Background())
+		ok, err := state.SetOutput(testVar("Stream"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -3004,7 +3083,7 @@ func TestState_SetOutput(t *testing.T) {
 	})
 
 	t.Run("streamOrAlias is an input stream", func(t *testing.T) {
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, NewStream(os.Stdin, StreamModeRead))
 
@@ -3031,7 +3110,7 @@ func TestState_Open(t *testing.T) {
 		assert.NoError(t, f.Close())
 
 		t.Run("alias", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "alias",
 				Args:    []Term{Atom("input")},
@@ -3054,7 +3133,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("type text", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "type",
 				Args:    []Term{Atom("text")},
@@ -3071,7 +3150,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("type binary", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "type",
 				Args:    []Term{Atom("binary")},
@@ -3088,7 +3167,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("reposition true", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "reposition",
 				Args:    []Term{Atom("true")},
@@ -3105,7 +3184,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("reposition true", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "reposition",
 				Args:    []Term{Atom("false")},
@@ -3122,7 +3201,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("eof_action error", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "eof_action",
 				Args:    []Term{Atom("error")},
@@ -3139,7 +3218,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("eof_action eof_code", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "eof_action",
 				Args:    []Term{Atom("eof_code")},
@@ -3156,7 +3235,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("eof_action reset", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "eof_action",
 				Args:    []Term{Atom("reset")},
@@ -3173,7 +3252,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("unknown option", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "unknown",
 				Args:    []Term{Atom("option")},
@@ -3186,7 +3265,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("wrong arity", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "type",
 				Args:    []Term{Atom("a"), Atom("b")},
@@ -3199,7 +3278,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("variable arg", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "type",
 				Args:    []Term{NewVariable()},
@@ -3212,7 +3291,7 @@ func TestState_Open(t *testing.T) {
 		})
 
 		t.Run("non-atom arg", func(t *testing.T) {
-			v := Variable("Stream")
+			v := testVar("Stream")
 			ok, err := state.Open(Atom(f.Name()), Atom("read"), v, List(&Compound{
 				Functor: "type",
 				Args:    []Term{Integer(0)},
@@ -3231,7 +3310,7 @@ func TestState_Open(t *testing.T) {
 			assert.NoError(t, os.Remove(n))
 		}()
 
-		v := Variable("Stream")
+		v := testVar("Stream")
 
 		ok, err := state.Open(Atom(n), Atom("write"), v, List(&Compound{
 			Functor: "alias",
@@ -3275,7 +3354,7 @@ func TestState_Open(t *testing.T) {
 
 		assert.NoError(t, f.Close())
 
-		v := Variable("Stream")
+		v := testVar("Stream")
 
 		ok, err := state.Open(Atom(f.Name()), Atom("append"), v, List(&Compound{
 			Functor: "alias",
@@ -3309,14 +3388,14 @@ func TestState_Open(t *testing.T) {
 
 	t.Run("sourceSink is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Variable("Source_Sink"), Atom("read"), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(testVar("Source_Sink"), Atom("read"), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
 
 	t.Run("mode is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Atom("/dev/null"), Variable("Mode"), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom("/dev/null"), testVar("Mode"), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -3324,7 +3403,7 @@ func TestState_Open(t *testing.T) {
 	t.Run("options is a partial list or a list with an element E which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
 			var state State
-			ok, err := state.Open(Atom("/dev/null"), Atom("read"), Variable("Stream"), ListRest(Variable("Rest"),
+			ok, err := state.Open(Atom("/dev/null"), Atom("read"), testVar("Stream"), ListRest(testVar("Rest"),
 				&Compound{Functor: "type", Args: []Term{Atom("text")}},
 				&Compound{Functor: "alias", Args: []Term{Atom("foo")}},
 			), Success, nil).Force(context.Background())
@@ -3334,8 +3413,8 @@ func TestState_Open(t *testing.T) {
 
 		t.Run("variable element", func(t *testing.T) {
 			var state State
-			ok, err := state.Open(Atom("/dev/null"), Atom("read"), Variable("Stream"), List(
-				Variable("Option"),
+			ok, err := state.Open(Atom("/dev/null"), Atom("read"), testVar("Stream"), List(
+				testVar("Option"),
 				&Compound{Functor: "type", Args: []Term{Atom("text")}},
 				&Compound{Functor: "alias", Args: []Term{Atom("foo")}},
 			), Success, nil).Force(context.Background())
@@ -3346,14 +3425,14 @@ func TestState_Open(t *testing.T) {
 
 	t.Run("mode is neither a variable nor an atom", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Atom("/dev/null"), Integer(0), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom("/dev/null"), Integer(0), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorAtom(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("options is neither a partial list nor a list", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Atom("/dev/null"), Atom("read"), Variable("Stream"), Atom("list"), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom("/dev/null"), Atom("read"), testVar("Stream"), Atom("list"), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorList(Atom("list")), err)
 		assert.False(t, ok)
 	})
@@ -3367,21 +3446,21 @@ func TestState_Open(t *testing.T) {
 
 	t.Run("sourceSink is neither a variable nor a source/sink", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Integer(0), Atom("read"), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(Integer(0), Atom("read"), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorSourceSink(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("mode is an atom but not an input/output mode", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Atom("/dev/null"), Atom("foo"), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom("/dev/null"), Atom("foo"), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorIOMode(Atom("foo")), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("an element E of the options list is neither a variable nor a stream-option", func(t *testing.T) {
 		var state State
-		ok, err := state.Open(Atom("/dev/null"), Atom("read"), Variable("Stream"), List(Atom("foo")), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom("/dev/null"), Atom("read"), testVar("Stream"), List(Atom("foo")), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorStreamOption(Atom("foo")), err)
 		assert.False(t, ok)
 	})
@@ -3392,7 +3471,7 @@ func TestState_Open(t *testing.T) {
 		assert.NoError(t, os.Remove(f.Name()))
 
 		var state State
-		ok, err := state.Open(Atom(f.Name()), Atom("read"), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom(f.Name()), Atom("read"), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, existenceErrorSourceSink(Atom(f.Name())), err)
 		assert.False(t, ok)
 	})
@@ -3407,7 +3486,7 @@ func TestState_Open(t *testing.T) {
 		assert.NoError(t, f.Chmod(0200))
 
 		var state State
-		ok, err := state.Open(Atom(f.Name()), Atom("read"), Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Open(Atom(f.Name()), Atom("read"), testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, PermissionError("open", "source_sink", Atom(f.Name())), err)
 		assert.False(t, ok)
 	})
@@ -3424,7 +3503,7 @@ func TestState_Open(t *testing.T) {
 				Atom("foo"): nil,
 			},
 		}
-		ok, err := state.Open(Atom(f.Name()), Atom("read"), Variable("Stream"), List(&Compound{
+		ok, err := state.Open(Atom(f.Name()), Atom("read"), testVar("Stream"), List(&Compound{
 			Functor: "alias",
 			Args:    []Term{Atom("foo")},
 		}), Success, nil).Force(context.Background())
@@ -3563,7 +3642,7 @@ func TestState_Close(t *testing.T) {
 
 	t.Run("streamOrAlias ia a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.Close(Variable("Stream"), List(), Success, nil).Force(context.Background())
+		ok, err := state.Close(testVar("Stream"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -3571,7 +3650,7 @@ func TestState_Close(t *testing.T) {
 	t.Run("options is a partial list or a list with an element E which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
 			var state State
-			ok, err := state.Close(&Stream{}, ListRest(Variable("Rest"),
+			ok, err := state.Close(&Stream{}, ListRest(testVar("Rest"),
 				&Compound{Functor: "force", Args: []Term{Atom("true")}},
 			), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
@@ -3580,7 +3659,7 @@ func TestState_Close(t *testing.T) {
 
 		t.Run("variable element", func(t *testing.T) {
 			var state State
-			ok, err := state.Close(&Stream{}, List(Variable("Option"), &Compound{Functor: "force", Args: []Term{Atom("true")}}), Success, nil).Force(context.Background())
+			ok, err := state.Close(&Stream{}, List(testVar("Option"), &Compound{Functor: "force", Args: []Term{Atom("true")}}), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
 		})
@@ -3661,7 +3740,7 @@ func TestState_FlushOutput(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.FlushOutput(Variable("Stream"), Success, nil).Force(context.Background())
+		ok, err := state.FlushOutput(testVar("Stream"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -3825,9 +3904,56 @@ func TestState_WriteTerm(t *testing.T) {
 		})
 	})
 
+	t.Run("max_depth", func(t *testing.T) {
+		var m mockTerm
+		m.On("Unparse", mock.Anything, (*Env)(nil), mock.Anything).Once()
+		defer m.AssertExpectations(t)
+
+		ok, err := state.WriteTerm(s, &m, List(&Compound{
+			Functor: "max_depth",
+			Args:    []Term{Integer(3)},
+		}), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.Equal(t, 3, m.maxDepth)
+	})
+
+	t.Run("portray", func(t *testing.T) {
+		t.Run("false", func(t *testing.T) {
+			var m mockTerm
+			m.On("Unparse", mock.Anything, (*Env)(nil), mock.Anything).Once()
+			defer m.AssertExpectations(t)
+
+			ok, err := state.WriteTerm(s, &m, List(&Compound{
+				Functor: "portray",
+				Args:    []Term{Atom("false")},
+			}), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			assert.Nil(t, m.portray)
+		})
+
+		t.Run("true", func(t *testing.T) {
+			var m mockTerm
+			m.On("Unparse", mock.Anything, (*Env)(nil), mock.Anything).Once()
+			defer m.AssertExpectations(t)
+
+			ok, err := state.WriteTerm(s, &m, List(&Compound{
+				Functor: "portray",
+				Args:    []Term{Atom("true")},
+			}), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			assert.NotNil(t, m.portray)
+		})
+	})
+
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.WriteTerm(Variable("Stream"), Atom("foo"), List(), Success, nil).Force(context.Background())
+		ok, err := state.WriteTerm(testVar("Stream"), Atom("foo"), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -3835,7 +3961,7 @@ func TestState_WriteTerm(t *testing.T) {
 	t.Run("options is a partial list or a list with an element which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
 			var state State
-			ok, err := state.WriteTerm(s, Atom("foo"), ListRest(Variable("Rest"),
+			ok, err := state.WriteTerm(s, Atom("foo"), ListRest(testVar("Rest"),
 				&Compound{Functor: "quoted", Args: []Term{Atom("true")}},
 			), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
@@ -3844,7 +3970,7 @@ func TestState_WriteTerm(t *testing.T) {
 
 		t.Run("variable element", func(t *testing.T) {
 			var state State
-			ok, err := state.WriteTerm(s, Atom("foo"), List(Variable("Option"), &Compound{Functor: "quoted", Args: []Term{Atom("true")}}), Success, nil).Force(context.Background())
+			ok, err := state.WriteTerm(s, Atom("foo"), List(testVar("Option"), &Compound{Functor: "quoted", Args: []Term{Atom("true")}}), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
 		})
@@ -3959,7 +4085,7 @@ func TestCharCode(t *testing.T) {
 	})
 
 	t.Run("query char", func(t *testing.T) {
-		v := Variable("Char")
+		v := testVar("Char")
 
 		ok, err := CharCode(v, Integer(128512), func(env *Env) *Promise {
 			assert.Equal(t, Atom("😀"), env.Resolve(v))
@@ -3970,7 +4096,7 @@ func TestCharCode(t *testing.T) {
 	})
 
 	t.Run("query code", func(t *testing.T) {
-		v := Variable("Code")
+		v := testVar("Code")
 		ok, err := CharCode(Atom("😀"), v, func(env *Env) *Promise {
 			assert.Equal(t, Integer(128512), env.Resolve(v))
 			return Bool(true)
@@ -3980,7 +4106,7 @@ func TestCharCode(t *testing.T) {
 	})
 
 	t.Run("char and code are variables", func(t *testing.T) {
-		char, code := Variable("Char"), Variable("Code")
+		char, code := testVar("Char"), testVar("Code")
 
 		ok, err := CharCode(char, code, Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
@@ -4017,7 +4143,7 @@ func TestCharCode(t *testing.T) {
 
 	t.Run("code is neither a variable nor a character-code", func(t *testing.T) {
 		ok, err := CharCode(NewVariable(), Integer(-1), Success, nil).Force(context.Background())
-		assert.Equal(t, representationError("character_code"), err)
+		assert.Equal(t, RepresentationError("character_code"), err)
 		assert.False(t, ok)
 	})
 }
@@ -4082,7 +4208,7 @@ func TestState_PutByte(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.PutByte(Variable("Stream"), Integer(97), Success, nil).Force(context.Background())
+		ok, err := state.PutByte(testVar("Stream"), Integer(97), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -4092,7 +4218,7 @@ func TestState_PutByte(t *testing.T) {
 		s.streamType = StreamTypeBinary
 
 		var state State
-		ok, err := state.PutByte(s, Variable("Byte"), Success, nil).Force(context.Background())
+		ok, err := state.PutByte(s, testVar("Byte"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -4115,7 +4241,7 @@ func TestState_PutByte(t *testing.T) {
 	})
 
 	t.Run("streamOrAlias is an input stream", func(t *testing.T) {
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, NewStream(os.Stdin, StreamModeRead))
 
@@ -4126,7 +4252,7 @@ func TestState_PutByte(t *testing.T) {
 	})
 
 	t.Run("streamOrAlias is associated with a text stream", func(t *testing.T) {
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, NewStream(os.Stdout, StreamModeWrite))
 
@@ -4194,14 +4320,14 @@ func TestState_PutCode(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.PutCode(Variable("Stream"), Integer(97), Success, nil).Force(context.Background())
+		ok, err := state.PutCode(testVar("Stream"), Integer(97), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
 
 	t.Run("code is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.PutCode(NewStream(os.Stdout, StreamModeWrite), Variable("Code"), Success, nil).Force(context.Background())
+		ok, err := state.PutCode(NewStream(os.Stdout, StreamModeWrite), testVar("Code"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -4228,7 +4354,7 @@ func TestState_PutCode(t *testing.T) {
 	})
 
 	t.Run("streamOrAlias is an input stream", func(t *testing.T) {
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, NewStream(os.Stdin, StreamModeRead))
 
@@ -4242,7 +4368,7 @@ func TestState_PutCode(t *testing.T) {
 		stream := NewStream(os.Stdout, StreamModeWrite)
 		stream.streamType = StreamTypeBinary
 
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, stream)
 
@@ -4255,7 +4381,7 @@ func TestState_PutCode(t *testing.T) {
 	t.Run("code is an integer but not an character code", func(t *testing.T) {
 		var state State
 		ok, err := state.PutCode(NewStream(os.Stdout, StreamModeWrite), Integer(-1), Success, nil).Force(context.Background())
-		assert.Equal(t, representationError("character_code"), err)
+		assert.Equal(t, RepresentationError("character_code"), err)
 		assert.False(t, ok)
 	})
 
@@ -4290,7 +4416,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Term")
+		v := testVar("Term")
 
 		var state State
 		ok, err := state.ReadTerm(s, v, List(), func(env *Env) *Promise {
@@ -4308,7 +4434,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Term")
+		v := testVar("Term")
 
 		state := State{
 			streams: map[Term]*Stream{
@@ -4330,7 +4456,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v, singletons := Variable("Term"), Variable("Singletons")
+		v, singletons := testVar("Term"), testVar("Singletons")
 
 		var state State
 		ok, err := state.ReadTerm(s, v, List(&Compound{
@@ -4364,7 +4490,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v, variables := Variable("Term"), Variable("Variables")
+		v, variables := testVar("Term"), testVar("Variables")
 
 		var state State
 		ok, err := state.ReadTerm(s, v, List(&Compound{
@@ -4398,7 +4524,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v, variableNames := Variable("Term"), Variable("VariableNames")
+		v, variableNames := testVar("Term"), testVar("VariableNames")
 
 		var state State
 		ok, err := state.ReadTerm(s, v, List(&Compound{
@@ -4441,7 +4567,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Term")
+		v := testVar("Term")
 
 		var state State
 
@@ -4469,7 +4595,7 @@ func TestState_ReadTerm(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.ReadTerm(Variable("Stream"), NewVariable(), List(), Success, nil).Force(context.Background())
+		ok, err := state.ReadTerm(testVar("Stream"), NewVariable(), List(), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -4477,8 +4603,8 @@ func TestState_ReadTerm(t *testing.T) {
 	t.Run("options is a partial list or a list with an element which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
 			var state State
-			ok, err := state.ReadTerm(NewStream(os.Stdin, StreamModeRead), NewVariable(), ListRest(Variable("Rest"),
-				&Compound{Functor: "variables", Args: []Term{Variable("VL")}},
+			ok, err := state.ReadTerm(NewStream(os.Stdin, StreamModeRead), NewVariable(), ListRest(testVar("Rest"),
+				&Compound{Functor: "variables", Args: []Term{testVar("VL")}},
 			), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
@@ -4486,7 +4612,7 @@ func TestState_ReadTerm(t *testing.T) {
 
 		t.Run("variable element", func(t *testing.T) {
 			var state State
-			ok, err := state.ReadTerm(NewStream(os.Stdin, StreamModeRead), NewVariable(), List(Variable("Option"), &Compound{Functor: "variables", Args: []Term{Variable("VL")}}), Success, nil).Force(context.Background())
+			ok, err := state.ReadTerm(NewStream(os.Stdin, StreamModeRead), NewVariable(), List(testVar("Option"), &Compound{Functor: "variables", Args: []Term{testVar("VL")}}), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
 		})
@@ -4527,7 +4653,7 @@ func TestState_ReadTerm(t *testing.T) {
 	})
 
 	t.Run("streamOrAlias is an output stream", func(t *testing.T) {
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, NewStream(os.Stdout, StreamModeWrite))
 
@@ -4541,7 +4667,7 @@ func TestState_ReadTerm(t *testing.T) {
 		stream := NewStream(os.Stdin, StreamModeRead)
 		stream.streamType = StreamTypeBinary
 
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, stream)
 
@@ -4560,7 +4686,7 @@ func TestState_ReadTerm(t *testing.T) {
 			assert.NoError(t, stream.Close())
 		}()
 
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, stream)
 
@@ -4622,7 +4748,7 @@ func TestState_GetByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Byte")
+		v := testVar("Byte")
 
 		var state State
 		ok, err := state.GetByte(s, v, func(env *Env) *Promise {
@@ -4641,7 +4767,7 @@ func TestState_GetByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Byte")
+		v := testVar("Byte")
 
 		state := State{
 			streams: map[Term]*Stream{
@@ -4664,7 +4790,7 @@ func TestState_GetByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Byte")
+		v := testVar("Byte")
 
 		var state State
 		ok, err := state.GetByte(s, v, func(env *Env) *Promise {
@@ -4688,14 +4814,14 @@ func TestState_GetByte(t *testing.T) {
 
 		var state State
 
-		v := Variable("V")
+		v := testVar("V")
 		_, err := state.GetByte(s, v, Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.GetByte(Variable("Stream"), Variable("InByte"), Success, nil).Force(context.Background())
+		ok, err := state.GetByte(testVar("Stream"), testVar("InByte"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -4712,36 +4838,36 @@ func TestState_GetByte(t *testing.T) {
 
 	t.Run("streamOrAlias is neither a variable nor a stream-term or alias", func(t *testing.T) {
 		var state State
-		ok, err := state.GetByte(Integer(0), Variable("InByte"), Success, nil).Force(context.Background())
+		ok, err := state.GetByte(Integer(0), testVar("InByte"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorStreamOrAlias(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is not associated with an open stream", func(t *testing.T) {
 		var state State
-		ok, err := state.GetByte(Atom("foo"), Variable("InByte"), Success, nil).Force(context.Background())
+		ok, err := state.GetByte(Atom("foo"), testVar("InByte"), Success, nil).Force(context.Background())
 		assert.Equal(t, existenceErrorStream(Atom("foo")), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is an output stream", func(t *testing.T) {
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, NewStream(os.Stdout, StreamModeWrite))
 
 		var state State
-		ok, err := state.GetByte(streamOrAlias, Variable("InByte"), Success, env).Force(context.Background())
+		ok, err := state.GetByte(streamOrAlias, testVar("InByte"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is associated with a text stream", func(t *testing.T) {
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, NewStream(os.Stdin, StreamModeRead))
 
 		var state State
-		ok, err := state.GetByte(streamOrAlias, Variable("InByte"), Success, env).Force(context.Background())
+		ok, err := state.GetByte(streamOrAlias, testVar("InByte"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputTextStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -4756,12 +4882,12 @@ func TestState_GetByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.GetByte(streamOrAlias, Variable("InByte"), Success, env).Force(context.Background())
+		ok, err := state.GetByte(streamOrAlias, testVar("InByte"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputPastEndOfStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -4775,7 +4901,7 @@ func TestState_GetChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Char")
+		v := testVar("Char")
 
 		var state State
 		ok, err := state.GetChar(s, v, func(env *Env) *Promise {
@@ -4793,7 +4919,7 @@ func TestState_GetChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Char")
+		v := testVar("Char")
 
 		state := State{
 			streams: map[Term]*Stream{
@@ -4815,7 +4941,7 @@ func TestState_GetChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Char")
+		v := testVar("Char")
 
 		var state State
 		ok, err := state.GetChar(s, v, func(env *Env) *Promise {
@@ -4834,7 +4960,7 @@ func TestState_GetChar(t *testing.T) {
 			readRune = (*bufio.Reader).ReadRune
 		}()
 
-		v := Variable("V")
+		v := testVar("V")
 
 		var state State
 		ok, err := state.GetChar(NewStream(os.Stdin, StreamModeRead), v, Success, nil).Force(context.Background())
@@ -4844,7 +4970,7 @@ func TestState_GetChar(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.GetChar(Variable("Stream"), Variable("Char"), Success, nil).Force(context.Background())
+		ok, err := state.GetChar(testVar("Stream"), testVar("Char"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -4858,18 +4984,18 @@ func TestState_GetChar(t *testing.T) {
 
 	t.Run("streamOrAlias is neither a variable nor a stream term or alias", func(t *testing.T) {
 		var state State
-		ok, err := state.GetChar(Integer(0), Variable("Char"), Success, nil).Force(context.Background())
+		ok, err := state.GetChar(Integer(0), testVar("Char"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorStreamOrAlias(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is an output stream", func(t *testing.T) {
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, NewStream(os.Stdout, StreamModeWrite))
 
 		var state State
-		ok, err := state.GetChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
+		ok, err := state.GetChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -4878,12 +5004,12 @@ func TestState_GetChar(t *testing.T) {
 		s := NewStream(os.Stdin, StreamModeRead)
 		s.streamType = StreamTypeBinary
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.GetChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
+		ok, err := state.GetChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputBinaryStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -4897,12 +5023,12 @@ func TestState_GetChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.GetChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
+		ok, err := state.GetChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputPastEndOfStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -4914,13 +5040,13 @@ func TestState_GetChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.GetChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
-		assert.Equal(t, representationError("character"), err)
+		ok, err := state.GetChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
+		assert.Equal(t, RepresentationError("character"), err)
 		assert.False(t, ok)
 	})
 }
@@ -4935,7 +5061,7 @@ func TestState_PeekByte(t *testing.T) {
 
 		s.streamType = StreamTypeBinary
 
-		v := Variable("Byte")
+		v := testVar("Byte")
 
 		var state State
 		ok, err := state.PeekByte(s, v, func(env *Env) *Promise {
@@ -4958,7 +5084,7 @@ func TestState_PeekByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Byte")
+		v := testVar("Byte")
 
 		state := State{
 			streams: map[Term]*Stream{
@@ -4981,7 +5107,7 @@ func TestState_PeekByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Byte")
+		v := testVar("Byte")
 
 		var state State
 		ok, err := state.PeekByte(s, v, func(env *Env) *Promise {
@@ -5003,7 +5129,7 @@ func TestState_PeekByte(t *testing.T) {
 		s := NewStream(os.Stdin, StreamModeRead)
 		s.streamType = StreamTypeBinary
 
-		v := Variable("V")
+		v := testVar("V")
 
 		var state State
 		ok, err := state.PeekByte(s, v, Success, nil).Force(context.Background())
@@ -5013,7 +5139,7 @@ func TestState_PeekByte(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.PeekByte(Variable("Stream"), Variable("Byte"), Success, nil).Force(context.Background())
+		ok, err := state.PeekByte(testVar("Stream"), testVar("Byte"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -5030,29 +5156,29 @@ func TestState_PeekByte(t *testing.T) {
 
 	t.Run("streamOrAlias is neither a variable nor a stream term or alias", func(t *testing.T) {
 		var state State
-		ok, err := state.PeekByte(Integer(0), Variable("Byte"), Success, nil).Force(context.Background())
+		ok, err := state.PeekByte(Integer(0), testVar("Byte"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorStreamOrAlias(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is an output stream", func(t *testing.T) {
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, NewStream(os.Stdout, StreamModeWrite))
 
 		var state State
-		ok, err := state.PeekByte(streamOrAlias, Variable("Byte"), Success, env).Force(context.Background())
+		ok, err := state.PeekByte(streamOrAlias, testVar("Byte"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is associated with a text stream", func(t *testing.T) {
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, NewStream(os.Stdin, StreamModeRead))
 
 		var state State
-		ok, err := state.PeekByte(streamOrAlias, Variable("Byte"), Success, env).Force(context.Background())
+		ok, err := state.PeekByte(streamOrAlias, testVar("Byte"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputTextStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -5067,12 +5193,12 @@ func TestState_PeekByte(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.PeekByte(streamOrAlias, Variable("Byte"), Success, env).Force(context.Background())
+		ok, err := state.PeekByte(streamOrAlias, testVar("Byte"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputPastEndOfStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -5086,7 +5212,7 @@ func TestState_PeekChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Char")
+		v := testVar("Char")
 
 		var state State
 		ok, err := state.PeekChar(s, v, func(env *Env) *Promise {
@@ -5111,7 +5237,7 @@ func TestState_PeekChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Char")
+		v := testVar("Char")
 
 		state := State{
 			streams: map[Term]*Stream{
@@ -5133,7 +5259,7 @@ func TestState_PeekChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("Char")
+		v := testVar("Char")
 
 		var state State
 		ok, err := state.PeekChar(s, v, func(env *Env) *Promise {
@@ -5153,7 +5279,7 @@ func TestState_PeekChar(t *testing.T) {
 				readRune = (*bufio.Reader).ReadRune
 			}()
 
-			v := Variable("V")
+			v := testVar("V")
 
 			var state State
 			ok, err := state.PeekChar(NewStream(os.Stdin, StreamModeRead), v, Success, nil).Force(context.Background())
@@ -5175,7 +5301,7 @@ func TestState_PeekChar(t *testing.T) {
 				assert.NoError(t, s.Close())
 			}()
 
-			v := Variable("V")
+			v := testVar("V")
 
 			var state State
 			ok, err := state.PeekChar(s, v, Success, nil).Force(context.Background())
@@ -5186,7 +5312,7 @@ func TestState_PeekChar(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.PeekChar(Variable("Stream"), Variable("Char"), Success, nil).Force(context.Background())
+		ok, err := state.PeekChar(testVar("Stream"), testVar("Char"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -5200,18 +5326,18 @@ func TestState_PeekChar(t *testing.T) {
 
 	t.Run("streamOrAlias is neither a variable nor a stream term or alias", func(t *testing.T) {
 		var state State
-		ok, err := state.PeekChar(Integer(0), Variable("Char"), Success, nil).Force(context.Background())
+		ok, err := state.PeekChar(Integer(0), testVar("Char"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorStreamOrAlias(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("streamOrAlias is an output stream", func(t *testing.T) {
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, NewStream(os.Stdout, StreamModeWrite))
 
 		var state State
-		ok, err := state.PeekChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
+		ok, err := state.PeekChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -5220,12 +5346,12 @@ func TestState_PeekChar(t *testing.T) {
 		s := NewStream(os.Stdin, StreamModeRead)
 		s.streamType = StreamTypeBinary
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.PeekChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
+		ok, err := state.PeekChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputBinaryStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -5239,12 +5365,12 @@ func TestState_PeekChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.PeekChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
+		ok, err := state.PeekChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
 		assert.Equal(t, permissionErrorInputPastEndOfStream(streamOrAlias), err)
 		assert.False(t, ok)
 	})
@@ -5256,13 +5382,13 @@ func TestState_PeekChar(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		streamOrAlias := Variable("Stream")
+		streamOrAlias := testVar("Stream")
 		env := NewEnv().
 			Bind(streamOrAlias, s)
 
 		var state State
-		ok, err := state.PeekChar(streamOrAlias, Variable("Char"), Success, env).Force(context.Background())
-		assert.Equal(t, representationError("character"), err)
+		ok, err := state.PeekChar(streamOrAlias, testVar("Char"), Success, env).Force(context.Background())
+		assert.Equal(t, RepresentationError("character"), err)
 		assert.False(t, ok)
 	})
 }
@@ -5286,7 +5412,7 @@ func Test_Halt(t *testing.T) {
 	})
 
 	t.Run("n is a variable", func(t *testing.T) {
-		n := Variable("N")
+		n := testVar("N")
 
 		ok, err := Halt(n, Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
@@ -5302,8 +5428,8 @@ func Test_Halt(t *testing.T) {
 
 func TestState_Clause(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		x := Variable("X")
-		what, body := Variable("What"), Variable("Body")
+		x := testVar("X")
+		what, body := testVar("What"), testVar("Body")
 
 		var c int
 
@@ -5349,7 +5475,7 @@ func TestState_Clause(t *testing.T) {
 
 	t.Run("head is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.Clause(Variable("Head"), Atom("true"), Success, nil).Force(context.Background())
+		ok, err := state.Clause(testVar("Head"), Atom("true"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -5362,7 +5488,7 @@ func TestState_Clause(t *testing.T) {
 	})
 
 	t.Run("the predicate indicator Pred of Head is that of a private (ie. Not public) procedure", func(t *testing.T) {
-		what, body := Variable("What"), Variable("Body")
+		what, body := testVar("What"), testVar("Body")
 
 		state := State{
 			VM: VM{
@@ -5406,7 +5532,7 @@ func TestAtomLength(t *testing.T) {
 	})
 
 	t.Run("atom is a variable", func(t *testing.T) {
-		atom := Variable("Atom")
+		atom := testVar("Atom")
 		ok, err := AtomLength(atom, Integer(0), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
@@ -5433,7 +5559,7 @@ func TestAtomLength(t *testing.T) {
 
 func TestAtomConcat(t *testing.T) {
 	t.Run("atom3 is a variable", func(t *testing.T) {
-		atom3 := Variable("Atom3")
+		atom3 := testVar("Atom3")
 
 		ok, err := AtomConcat(Atom("foo"), Atom("bar"), atom3, func(env *Env) *Promise {
 			assert.Equal(t, Atom("foobar"), env.Resolve(atom3))
@@ -5445,7 +5571,7 @@ func TestAtomConcat(t *testing.T) {
 
 	t.Run("atom3 is an atom", func(t *testing.T) {
 		var c int
-		v1, v2 := Variable("V1"), Variable("V2")
+		v1, v2 := testVar("V1"), testVar("V2")
 		ok, err := AtomConcat(v1, v2, Atom("foo"), func(env *Env) *Promise {
 			switch c {
 			case 0:
@@ -5471,7 +5597,7 @@ func TestAtomConcat(t *testing.T) {
 	})
 
 	t.Run("atom1 and atom3 are variables", func(t *testing.T) {
-		atom1, atom3 := Variable("Atom1"), Variable("Atom3")
+		atom1, atom3 := testVar("Atom1"), testVar("Atom3")
 
 		ok, err := AtomConcat(atom1, Atom("bar"), atom3, Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
@@ -5479,7 +5605,7 @@ func TestAtomConcat(t *testing.T) {
 	})
 
 	t.Run("atom2 and atom3 are variables", func(t *testing.T) {
-		atom2, atom3 := Variable("Atom2"), Variable("Atom3")
+		atom2, atom3 := testVar("Atom2"), testVar("Atom3")
 
 		ok, err := AtomConcat(Atom("foo"), atom2, atom3, Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
@@ -5488,7 +5614,7 @@ func TestAtomConcat(t *testing.T) {
 
 	t.Run("atom1 is neither a variable nor an atom", func(t *testing.T) {
 		t.Run("atom3 is a variable", func(t *testing.T) {
-			ok, err := AtomConcat(Integer(1), Atom("bar"), Variable("Atom3"), Success, nil).Force(context.Background())
+			ok, err := AtomConcat(Integer(1), Atom("bar"), testVar("Atom3"), Success, nil).Force(context.Background())
 			assert.Equal(t, TypeErrorAtom(Integer(1)), err)
 			assert.False(t, ok)
 		})
@@ -5502,7 +5628,7 @@ func TestAtomConcat(t *testing.T) {
 
 	t.Run("atom2 is neither a variable nor an atom", func(t *testing.T) {
 		t.Run("atom3 is a variable", func(t *testing.T) {
-			ok, err := AtomConcat(Atom("foo"), Integer(2), Variable("Atom3"), Success, nil).Force(context.Background())
+			ok, err := AtomConcat(Atom("foo"), Integer(2), testVar("Atom3"), Success, nil).Force(context.Background())
 			assert.Equal(t, TypeErrorAtom(Integer(2)), err)
 			assert.False(t, ok)
 		})
@@ -5523,7 +5649,7 @@ func TestAtomConcat(t *testing.T) {
 
 func TestSubAtom(t *testing.T) {
 	t.Run("multiple solutions", func(t *testing.T) {
-		before, length, after := Variable("Before"), Variable("Length"), Variable("After")
+		before, length, after := testVar("Before"), testVar("Length"), testVar("After")
 		var c int
 		ok, err := SubAtom(Atom("xATGATGAxATGAxATGAx"), before, length, after, Atom("ATGA"), func(env *Env) *Promise {
 			switch c {
@@ -5554,7 +5680,7 @@ func TestSubAtom(t *testing.T) {
 	})
 
 	t.Run("get the first char", func(t *testing.T) {
-		char := Variable("Char")
+		char := testVar("Char")
 		ok, err := SubAtom(Atom("a"), Integer(0), Integer(1), Integer(0), char, func(env *Env) *Promise {
 			assert.Equal(t, Atom("a"), env.Resolve(char))
 			return Bool(true)
@@ -5564,55 +5690,55 @@ func TestSubAtom(t *testing.T) {
 	})
 
 	t.Run("atom is a variable", func(t *testing.T) {
-		ok, err := SubAtom(Variable("Atom"), Variable("Before"), Variable("Length"), Variable("After"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(testVar("Atom"), testVar("Before"), testVar("Length"), testVar("After"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
 
 	t.Run("atom is neither a variable nor an atom", func(t *testing.T) {
-		ok, err := SubAtom(Integer(0), Variable("Before"), Variable("Length"), Variable("After"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Integer(0), testVar("Before"), testVar("Length"), testVar("After"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorAtom(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("subAtom is neither a variable nor an atom", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Variable("Before"), Variable("Length"), Variable("After"), Integer(0), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), testVar("Before"), testVar("Length"), testVar("After"), Integer(0), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorAtom(Integer(0)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("before is neither a variable nor an integer", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Atom("before"), Variable("Length"), Variable("After"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), Atom("before"), testVar("Length"), testVar("After"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorInteger(Atom("before")), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("length is neither a variable nor an integer", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Variable("Before"), Atom("length"), Variable("After"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), testVar("Before"), Atom("length"), testVar("After"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorInteger(Atom("length")), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("after is neither a variable nor an integer", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Variable("Before"), Variable("Length"), Atom("after"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), testVar("Before"), testVar("Length"), Atom("after"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, TypeErrorInteger(Atom("after")), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("before is an integer less than zero", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Integer(-1), Variable("Length"), Variable("After"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), Integer(-1), testVar("Length"), testVar("After"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorNotLessThanZero(Integer(-1)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("length is an integer less than zero", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Variable("Before"), Integer(-1), Variable("After"), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), testVar("Before"), Integer(-1), testVar("After"), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorNotLessThanZero(Integer(-1)), err)
 		assert.False(t, ok)
 	})
 
 	t.Run("after is an integer less than zero", func(t *testing.T) {
-		ok, err := SubAtom(Atom("foo"), Variable("Before"), Variable("Length"), Integer(-1), Variable("SubAtom"), Success, nil).Force(context.Background())
+		ok, err := SubAtom(Atom("foo"), testVar("Before"), testVar("Length"), Integer(-1), testVar("SubAtom"), Success, nil).Force(context.Background())
 		assert.Equal(t, domainErrorNotLessThanZero(Integer(-1)), err)
 		assert.False(t, ok)
 	})
@@ -5620,7 +5746,7 @@ func TestSubAtom(t *testing.T) {
 
 func TestAtomChars(t *testing.T) {
 	t.Run("break down", func(t *testing.T) {
-		chars := Variable("Char")
+		chars := testVar("Char")
 
 		ok, err := AtomChars(Atom("foo"), chars, func(env *Env) *Promise {
 			assert.Equal(t, List(Atom("f"), Atom("o"), Atom("o")), env.Resolve(chars))
@@ -5631,7 +5757,7 @@ func TestAtomChars(t *testing.T) {
 	})
 
 	t.Run("construct", func(t *testing.T) {
-		atom := Variable("Atom")
+		atom := testVar("Atom")
 
 		ok, err := AtomChars(atom, List(Atom("f"), Atom("o"), Atom("o")), func(env *Env) *Promise {
 			assert.Equal(t, Atom("foo"), env.Resolve(atom))
@@ -5646,7 +5772,7 @@ func TestAtomChars(t *testing.T) {
 
 	t.Run("atom is a variable and List is a partial list or list with an element which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
-			chars := ListRest(Variable("Rest"),
+			chars := ListRest(testVar("Rest"),
 				Atom("0"),
 				Atom("0"),
 			)
@@ -5657,7 +5783,7 @@ func TestAtomChars(t *testing.T) {
 		})
 
 		t.Run("variable element", func(t *testing.T) {
-			char := Variable("Char")
+			char := testVar("Char")
 			ok, err := AtomChars(NewVariable(), List(char, Atom("o"), Atom("o")), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
 			assert.False(t, ok)
@@ -5693,7 +5819,7 @@ func TestAtomChars(t *testing.T) {
 
 func TestAtomCodes(t *testing.T) {
 	t.Run("break up", func(t *testing.T) {
-		codes := Variable("Codes")
+		codes := testVar("Codes")
 
 		ok, err := AtomCodes(Atom("foo"), codes, func(env *Env) *Promise {
 			assert.Equal(t, List(Integer(102), Integer(111), Integer(111)), env.Resolve(codes))
@@ -5704,7 +5830,7 @@ func TestAtomCodes(t *testing.T) {
 	})
 
 	t.Run("construct", func(t *testing.T) {
-		atom := Variable("Atom")
+		atom := testVar("Atom")
 
 		ok, err := AtomCodes(atom, List(Integer(102), Integer(111), Integer(111)), func(env *Env) *Promise {
 			assert.Equal(t, Atom("foo"), env.Resolve(atom))
@@ -5716,7 +5842,7 @@ func TestAtomCodes(t *testing.T) {
 
 	t.Run("atom is a variable and List is a partial list or list with an element which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
-			codes := ListRest(Variable("Rest"),
+			codes := ListRest(testVar("Rest"),
 				Integer(111),
 				Integer(111),
 			)
@@ -5726,7 +5852,7 @@ func TestAtomCodes(t *testing.T) {
 		})
 
 		t.Run("variable element", func(t *testing.T) {
-			code := Variable("Code")
+			code := testVar("Code")
 
 			ok, err := AtomCodes(NewVariable(), List(code, Integer(111), Integer(111)), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
@@ -5748,14 +5874,14 @@ func TestAtomCodes(t *testing.T) {
 
 	t.Run("atom is a variable and an element E of the list List is neither a variable nor a character-code", func(t *testing.T) {
 		ok, err := AtomCodes(NewVariable(), List(Atom("f"), Integer(111), Integer(111)), Success, nil).Force(context.Background())
-		assert.Equal(t, representationError("character_code"), err)
+		assert.Equal(t, RepresentationError("character_code"), err)
 		assert.False(t, ok)
 	})
 }
 
 func TestNumberChars(t *testing.T) {
 	t.Run("number to chars", func(t *testing.T) {
-		chars := Variable("Chars")
+		chars := testVar("Chars")
 
 		ok, err := NumberChars(Float(23.4), chars, func(env *Env) *Promise {
 			assert.Equal(t, List(Atom("2"), Atom("3"), Atom("."), Atom("4")), env.Resolve(chars))
@@ -5766,7 +5892,7 @@ func TestNumberChars(t *testing.T) {
 	})
 
 	t.Run("chars to number", func(t *testing.T) {
-		num := Variable("Num")
+		num := testVar("Num")
 
 		ok, err := NumberChars(num, List(Atom("2"), Atom("3"), Atom("."), Atom("4")), func(env *Env) *Promise {
 			assert.Equal(t, Float(23.4), env.Resolve(num))
@@ -5792,7 +5918,7 @@ func TestNumberChars(t *testing.T) {
 
 	t.Run("num is a variable and chars is a partial list or list with an element which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
-			codes := ListRest(Variable("Rest"),
+			codes := ListRest(testVar("Rest"),
 				Atom("2"), Atom("3"), Atom("."), Atom("4"),
 			)
 
@@ -5802,7 +5928,7 @@ func TestNumberChars(t *testing.T) {
 		})
 
 		t.Run("variable element", func(t *testing.T) {
-			code := Variable("Code")
+			code := testVar("Code")
 
 			ok, err := NumberChars(NewVariable(), List(code, Atom("3"), Atom("."), Atom("4")), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
@@ -5844,7 +5970,7 @@ func TestNumberChars(t *testing.T) {
 
 func TestNumberCodes(t *testing.T) {
 	t.Run("number to codes", func(t *testing.T) {
-		codes := Variable("Codes")
+		codes := testVar("Codes")
 
 		ok, err := NumberCodes(Float(23.4), codes, func(env *Env) *Promise {
 			assert.Equal(t, List(Integer(50), Integer(51), Integer(46), Integer(52)), env.Resolve(codes))
@@ -5855,7 +5981,7 @@ func TestNumberCodes(t *testing.T) {
 	})
 
 	t.Run("codes to number", func(t *testing.T) {
-		num := Variable("Num")
+		num := testVar("Num")
 
 		ok, err := NumberCodes(num, List(Integer(50), Integer(51), Integer(46), Integer(52)), func(env *Env) *Promise {
 			assert.Equal(t, Float(23.4), env.Resolve(num))
@@ -5887,7 +6013,7 @@ func TestNumberCodes(t *testing.T) {
 
 	t.Run("num is a variable and codes is a partial list or list with an element which is a variable", func(t *testing.T) {
 		t.Run("partial list", func(t *testing.T) {
-			codes := ListRest(Variable("Rest"),
+			codes := ListRest(testVar("Rest"),
 				Integer(50), Integer(51), Integer(46), Integer(52),
 			)
 
@@ -5897,7 +6023,7 @@ func TestNumberCodes(t *testing.T) {
 		})
 
 		t.Run("variable element", func(t *testing.T) {
-			code := Variable("Code")
+			code := testVar("Code")
 
 			ok, err := NumberCodes(NewVariable(), List(code, Integer(50), Integer(51), Integer(46), Integer(52)), Success, nil).Force(context.Background())
 			assert.Equal(t, ErrInstantiation, err)
@@ -5919,7 +6045,7 @@ func TestNumberCodes(t *testing.T) {
 
 	t.Run("an element E of the list codes is neither a variable nor a one-character atom", func(t *testing.T) {
 		ok, err := NumberCodes(NewVariable(), List(Atom("2"), Integer(51), Integer(46), Integer(52)), Success, nil).Force(context.Background())
-		assert.Equal(t, representationError("character_code"), err)
+		assert.Equal(t, RepresentationError("character_code"), err)
 		assert.False(t, ok)
 	})
 
@@ -5958,7 +6084,7 @@ func TestState_StreamProperty(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("V")
+		v := testVar("V")
 		c := 0
 		var state State
 		ok, err := state.StreamProperty(s, v, func(env *Env) *Promise {
@@ -5991,7 +6117,7 @@ func TestState_StreamProperty(t *testing.T) {
 			assert.NoError(t, s.Close())
 		}()
 
-		v := Variable("V")
+		v := testVar("V")
 		c := 0
 		var state State
 		ok, err := state.StreamProperty(s, v, func(env *Env) *Promise {
@@ -6028,7 +6154,7 @@ func TestState_StreamProperty(t *testing.T) {
 				Atom("null"): s,
 			},
 		}
-		v := Variable("V")
+		v := testVar("V")
 		c := 0
 		ok, err := state.StreamProperty(Atom("null"), v, func(env *Env) *Promise {
 			assert.Equal(t, expected[c], env.Resolve(v))
@@ -6291,7 +6417,7 @@ func TestState_SetStreamPosition(t *testing.T) {
 
 	t.Run("streamOrAlias is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.SetStreamPosition(Variable("Stream"), Integer(0), Success, nil).Force(context.Background())
+		ok, err := state.SetStreamPosition(testVar("Stream"), Integer(0), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -6304,7 +6430,7 @@ func TestState_SetStreamPosition(t *testing.T) {
 		}()
 
 		var state State
-		ok, err := state.SetStreamPosition(s, Variable("Pos"), Success, nil).Force(context.Background())
+		ok, err := state.SetStreamPosition(s, testVar("Pos"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -6328,7 +6454,7 @@ func TestState_SetStreamPosition(t *testing.T) {
 
 		assert.False(t, stream.reposition)
 
-		s := Variable("Stream")
+		s := testVar("Stream")
 		env := NewEnv().
 			Bind(s, stream)
 
@@ -6365,14 +6491,14 @@ func TestState_CharConversion(t *testing.T) {
 
 	t.Run("inChar is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.CharConversion(Variable("In"), Atom("a"), Success, nil).Force(context.Background())
+		ok, err := state.CharConversion(testVar("In"), Atom("a"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
 
 	t.Run("outChar is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.CharConversion(Atom("a"), Variable("Out"), Success, nil).Force(context.Background())
+		ok, err := state.CharConversion(Atom("a"), testVar("Out"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -6381,14 +6507,14 @@ func TestState_CharConversion(t *testing.T) {
 		t.Run("not even an atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CharConversion(Integer(0), Atom("a"), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 
 		t.Run("multi-character atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CharConversion(Atom("foo"), Atom("a"), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 	})
@@ -6397,14 +6523,14 @@ func TestState_CharConversion(t *testing.T) {
 		t.Run("not even an atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CharConversion(Atom("a"), Integer(0), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 
 		t.Run("multi-character atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CharConversion(Atom("a"), Atom("foo"), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 	})
@@ -6432,7 +6558,7 @@ func TestState_CurrentCharConversion(t *testing.T) {
 	})
 
 	t.Run("not specified", func(t *testing.T) {
-		x, y := Variable("X"), Variable("Y")
+		x, y := testVar("X"), testVar("Y")
 
 		var r rune
 		var state State
@@ -6463,14 +6589,14 @@ func TestState_CurrentCharConversion(t *testing.T) {
 		t.Run("not even an atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CurrentCharConversion(Integer(0), Atom("b"), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 
 		t.Run("multi-character atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CurrentCharConversion(Atom("foo"), Atom("b"), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 	})
@@ -6479,14 +6605,14 @@ func TestState_CurrentCharConversion(t *testing.T) {
 		t.Run("not even an atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CurrentCharConversion(Atom("a"), Integer(0), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 
 		t.Run("multi-character atom", func(t *testing.T) {
 			var state State
 			ok, err := state.CurrentCharConversion(Atom("a"), Atom("bar"), Success, nil).Force(context.Background())
-			assert.Equal(t, representationError("character"), err)
+			assert.Equal(t, RepresentationError("character"), err)
 			assert.False(t, ok)
 		})
 	})
@@ -6552,19 +6678,19 @@ func TestState_SetPrologFlag(t *testing.T) {
 			ok, err := state.SetPrologFlag(Atom("debug"), Atom("on"), Success, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.True(t, state.debug)
+			assert.True(t, state.debugger.enabled)
 		})
 
 		t.Run("off", func(t *testing.T) {
-			state := State{debug: true}
+			state := State{VM: VM{debugger: debugger{enabled: true}}}
 			ok, err := state.SetPrologFlag(Atom("debug"), Atom("off"), Success, nil).Force(context.Background())
 			assert.NoError(t, err)
 			assert.True(t, ok)
-			assert.False(t, state.debug)
+			assert.False(t, state.debugger.enabled)
 		})
 
 		t.Run("unknown", func(t *testing.T) {
-			state := State{debug: true}
+			state := State{VM: VM{debugger: debugger{enabled: true}}}
 			ok, err := state.SetPrologFlag(Atom("debug"), Atom("foo"), Success, nil).Force(context.Background())
 			assert.Error(t, err)
 			assert.False(t, ok)
@@ -6578,6 +6704,13 @@ func TestState_SetPrologFlag(t *testing.T) {
 		assert.False(t, ok)
 	})
 
+	t.Run("argv", func(t *testing.T) {
+		var state State
+		ok, err := state.SetPrologFlag(Atom("argv"), List(), Success, nil).Force(context.Background())
+		assert.Equal(t, PermissionError("modify", "flag", Atom("argv")), err)
+		assert.False(t, ok)
+	})
+
 	t.Run("unknown", func(t *testing.T) {
 		t.Run("error", func(t *testing.T) {
 			state := State{VM: VM{unknown: unknownFail}}
@@ -6644,16 +6777,43 @@ func TestState_SetPrologFlag(t *testing.T) {
 		})
 	})
 
+	t.Run("answer_write_options", func(t *testing.T) {
+		t.Run("ok", func(t *testing.T) {
+			var state State
+			opts := List(&Compound{Functor: "max_depth", Args: []Term{Integer(3)}}, &Compound{Functor: "portray", Args: []Term{Atom("false")}})
+			ok, err := state.SetPrologFlag(Atom("answer_write_options"), opts, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, opts, state.answerWriteOptions)
+			assert.Len(t, state.AnswerWriteOptions(), 2)
+		})
+
+		t.Run("invalid option", func(t *testing.T) {
+			var state State
+			opts := List(&Compound{Functor: "foo", Args: []Term{Atom("true")}})
+			ok, err := state.SetPrologFlag(Atom("answer_write_options"), opts, Success, nil).Force(context.Background())
+			assert.Equal(t, domainErrorFlagValue(&Compound{Functor: "+", Args: []Term{Atom("answer_write_options"), opts}}), err)
+			assert.False(t, ok)
+		})
+
+		t.Run("partial list", func(t *testing.T) {
+			var state State
+			ok, err := state.SetPrologFlag(Atom("answer_write_options"), Cons(&Compound{Functor: "quoted", Args: []Term{Atom("true")}}, testVar("Rest")), Success, nil).Force(context.Background())
+			assert.Equal(t, ErrInstantiation, err)
+			assert.False(t, ok)
+		})
+	})
+
 	t.Run("flag is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.SetPrologFlag(Variable("Flag"), Atom("fail"), Success, nil).Force(context.Background())
+		ok, err := state.SetPrologFlag(testVar("Flag"), Atom("fail"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
 
 	t.Run("value is a variable", func(t *testing.T) {
 		var state State
-		ok, err := state.SetPrologFlag(Atom("unknown"), Variable("Value"), Success, nil).Force(context.Background())
+		ok, err := state.SetPrologFlag(Atom("unknown"), testVar("Value"), Success, nil).Force(context.Background())
 		assert.Equal(t, ErrInstantiation, err)
 		assert.False(t, ok)
 	})
@@ -6692,6 +6852,7 @@ func TestState_SetPrologFlag(t *testing.T) {
 
 func TestState_CurrentPrologFlag(t *testing.T) {
 	var state State
+	state.SetArgv([]string{"1pl", "foo"})
 
 	t.Run("specified", func(t *testing.T) {
 		ok, err := state.CurrentPrologFlag(Atom("bounded"), Atom("true"), Success, nil).Force(context.Background())
@@ -6728,7 +6889,7 @@ func TestState_CurrentPrologFlag(t *testing.T) {
 	})
 
 	t.Run("not specified", func(t *testing.T) {
-		flag, value := Variable("Flag"), Variable("Value")
+		flag, value := testVar("Flag"), testVar("Value")
 		var c int
 		ok, err := state.CurrentPrologFlag(flag, value, func(env *Env) *Promise {
 			switch c {
@@ -6759,6 +6920,12 @@ func TestState_CurrentPrologFlag(t *testing.T) {
 			case 8:
 				assert.Equal(t, Atom("double_quotes"), env.Resolve(flag))
 				assert.Equal(t, Atom(state.doubleQuotes.String()), env.Resolve(value))
+			case 9:
+				assert.Equal(t, Atom("answer_write_options"), env.Resolve(flag))
+				assert.Equal(t, defaultAnswerWriteOptions, env.Resolve(value))
+			case 10:
+				assert.Equal(t, Atom("argv"), env.Resolve(flag))
+				assert.Equal(t, List(Atom("1pl"), Atom("foo")), env.Resolve(value))
 			default:
 				assert.Fail(t, "unreachable")
 			}
@@ -6767,7 +6934,7 @@ func TestState_CurrentPrologFlag(t *testing.T) {
 		}, nil).Force(context.Background())
 		assert.NoError(t, err)
 		assert.False(t, ok)
-		assert.Equal(t, 9, c)
+		assert.Equal(t, 11, c)
 	})
 
 	t.Run("flag is neither a variable nor an atom", func(t *testing.T) {
@@ -7004,6 +7171,103 @@ func TestState_ExpandTerm(t *testing.T) {
 	})
 }
 
+func TestState_ExpandGoal(t *testing.T) {
+	t.Run("goal_expansion/2 is undefined", func(t *testing.T) {
+		var state State
+		ok, err := state.ExpandGoal(Atom("foo"), Atom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("goal_expansion/2 is defined", func(t *testing.T) {
+		state := State{
+			VM: VM{
+				procedures: map[ProcedureIndicator]procedure{
+					{Name: "goal_expansion", Arity: 2}: predicate2(func(t1, t2 Term, k func(*Env) *Promise, env *Env) *Promise {
+						switch env.Resolve(t1) {
+						case Atom("foo"):
+							return Unify(t2, Atom("bar"), k, env)
+						case Atom("bar"):
+							return Unify(t2, Atom("baz"), k, env)
+						case Atom("loop"):
+							return Unify(t2, &Compound{Functor: ",", Args: []Term{Atom("loop"), Atom("true")}}, k, env)
+						case Atom("ping"):
+							return Unify(t2, Atom("pong"), k, env)
+						case Atom("pong"):
+							return Unify(t2, Atom("ping"), k, env)
+						}
+						if c, ok := env.Resolve(t1).(*Compound); ok && c.Functor == "p" && len(c.Args) == 2 {
+							return Unify(t2, &Compound{Functor: "q", Args: c.Args}, k, env)
+						}
+						return Bool(false)
+					}),
+				},
+			},
+		}
+
+		t.Run("repeatedly", func(t *testing.T) {
+			ok, err := state.ExpandGoal(Atom("foo"), Atom("baz"), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("subgoals", func(t *testing.T) {
+			ok, err := state.ExpandGoal(&Compound{Functor: ",", Args: []Term{
+				Atom("foo"),
+				&Compound{Functor: "\\+", Args: []Term{Atom("bar")}},
+			}}, &Compound{Functor: ",", Args: []Term{
+				Atom("baz"),
+				&Compound{Functor: "\\+", Args: []Term{Atom("baz")}},
+			}}, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("expansion contains the goal", func(t *testing.T) {
+			ok, err := state.ExpandGoal(Atom("loop"), &Compound{Functor: ",", Args: []Term{Atom("loop"), Atom("true")}}, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("cycle", func(t *testing.T) {
+			ok, err := state.ExpandGoal(Atom("ping"), Atom("ping"), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("bagof", func(t *testing.T) {
+			x := NewVariable()
+			ok, err := state.ExpandGoal(
+				&Compound{Functor: "bagof", Args: []Term{x, &Compound{Functor: "^", Args: []Term{x, Atom("foo")}}, NewVariable()}},
+				&Compound{Functor: "bagof", Args: []Term{x, &Compound{Functor: "^", Args: []Term{x, Atom("baz")}}, NewVariable()}},
+				Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("closure", func(t *testing.T) {
+			ok, err := state.ExpandGoal(
+				&Compound{Functor: "call", Args: []Term{&Compound{Functor: "p", Args: []Term{Atom("a")}}, Atom("b")}},
+				&Compound{Functor: "call", Args: []Term{&Compound{Functor: "q", Args: []Term{Atom("a"), Atom("b")}}}},
+				Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			g := &Compound{Functor: "call", Args: []Term{NewVariable(), Atom("b")}}
+			ok, err = state.ExpandGoal(g, g, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("not a goal argument", func(t *testing.T) {
+			g := &Compound{Functor: "qux", Args: []Term{Atom("foo")}}
+			ok, err := state.ExpandGoal(g, g, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	})
+}
+
 func TestEnviron(t *testing.T) {
 	os.Clearenv()
 	assert.NoError(t, os.Setenv("FOO", "foo"))
@@ -7011,8 +7275,8 @@ func TestEnviron(t *testing.T) {
 
 	var (
 		count = 0
-		key   = Variable("Key")
-		value = Variable("Value")
+		key   = testVar("Key")
+		value = testVar("Value")
 	)
 	ok, err := Environ(key, value, func(env *Env) *Promise {
 		count++
@@ -7041,8 +7305,8 @@ func TestState_Expand(t *testing.T) {
 			term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), List()}}, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-				&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
-				&Compound{Functor: "=", Args: []Term{Variable("_1"), Variable("_3")}},
+				&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
+				&Compound{Functor: "=", Args: []Term{Variable(1), Variable(3)}},
 			}}, term)
 		})
 
@@ -7051,8 +7315,8 @@ func TestState_Expand(t *testing.T) {
 			term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), List(Atom("a"))}}, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-				&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
-				&Compound{Functor: "=", Args: []Term{Variable("_1"), ListRest(Variable("_3"), Atom("a"))}},
+				&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
+				&Compound{Functor: "=", Args: []Term{Variable(1), ListRest(Variable(3), Atom("a"))}},
 			}}, term)
 		})
 
@@ -7062,10 +7326,10 @@ func TestState_Expand(t *testing.T) {
 				term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), Seq(",", Atom("a"), Atom("b"))}}, nil)
 				assert.NoError(t, err)
 				assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-					&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+					&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 					Seq(",",
-						&Compound{Functor: "a", Args: []Term{Variable("_1"), Variable("_4")}},
-						&Compound{Functor: "b", Args: []Term{Variable("_4"), Variable("_3")}},
+						&Compound{Functor: "a", Args: []Term{Variable(1), Variable(4)}},
+						&Compound{Functor: "b", Args: []Term{Variable(4), Variable(3)}},
 					),
 				}}, term)
 			})
@@ -7090,10 +7354,10 @@ func TestState_Expand(t *testing.T) {
 					term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), Seq(";", Atom("a"), Atom("b"))}}, nil)
 					assert.NoError(t, err)
 					assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-						&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+						&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 						Seq(";",
-							&Compound{Functor: "a", Args: []Term{Variable("_1"), Variable("_3")}},
-							&Compound{Functor: "b", Args: []Term{Variable("_1"), Variable("_3")}},
+							&Compound{Functor: "a", Args: []Term{Variable(1), Variable(3)}},
+							&Compound{Functor: "b", Args: []Term{Variable(1), Variable(3)}},
 						),
 					}}, term)
 				})
@@ -7103,13 +7367,13 @@ func TestState_Expand(t *testing.T) {
 					term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), Seq(";", &Compound{Functor: "->", Args: []Term{Atom("a"), Atom("b")}}, Atom("c"))}}, nil)
 					assert.NoError(t, err)
 					assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-						&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+						&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 						Seq(";",
 							&Compound{Functor: "->", Args: []Term{
-								&Compound{Functor: "a", Args: []Term{Variable("_1"), Variable("_4")}},
-								&Compound{Functor: "b", Args: []Term{Variable("_4"), Variable("_3")}},
+								&Compound{Functor: "a", Args: []Term{Variable(1), Variable(4)}},
+								&Compound{Functor: "b", Args: []Term{Variable(4), Variable(3)}},
 							}},
-							&Compound{Functor: "c", Args: []Term{Variable("_1"), Variable("_3")}},
+							&Compound{Functor: "c", Args: []Term{Variable(1), Variable(3)}},
 						),
 					}}, term)
 				})
@@ -7134,10 +7398,10 @@ func TestState_Expand(t *testing.T) {
 				term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), Seq("|", Atom("a"), Atom("b"))}}, nil)
 				assert.NoError(t, err)
 				assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-					&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+					&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 					Seq(";",
-						&Compound{Functor: "a", Args: []Term{Variable("_1"), Variable("_3")}},
-						&Compound{Functor: "b", Args: []Term{Variable("_1"), Variable("_3")}},
+						&Compound{Functor: "a", Args: []Term{Variable(1), Variable(3)}},
+						&Compound{Functor: "b", Args: []Term{Variable(1), Variable(3)}},
 					),
 				}}, term)
 			})
@@ -7160,10 +7424,10 @@ func TestState_Expand(t *testing.T) {
 			term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), &Compound{Functor: "{}", Args: []Term{Atom("a")}}}}, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-				&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+				&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 				Seq(",",
 					Atom("a"),
-					&Compound{Functor: "=", Args: []Term{Variable("_1"), Variable("_3")}},
+					&Compound{Functor: "=", Args: []Term{Variable(1), Variable(3)}},
 				),
 			}}, term)
 		})
@@ -7173,8 +7437,8 @@ func TestState_Expand(t *testing.T) {
 			term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), &Compound{Functor: "call", Args: []Term{Atom("a")}}}}, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-				&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
-				&Compound{Functor: "call", Args: []Term{Atom("a"), Variable("_1"), Variable("_3")}},
+				&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
+				&Compound{Functor: "call", Args: []Term{Atom("a"), Variable(1), Variable(3)}},
 			}}, term)
 		})
 
@@ -7183,8 +7447,8 @@ func TestState_Expand(t *testing.T) {
 			term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), &Compound{Functor: "phrase", Args: []Term{Atom("a")}}}}, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-				&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
-				&Compound{Functor: "phrase", Args: []Term{Atom("a"), Variable("_1"), Variable("_3")}},
+				&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
+				&Compound{Functor: "phrase", Args: []Term{Atom("a"), Variable(1), Variable(3)}},
 			}}, term)
 		})
 
@@ -7193,10 +7457,10 @@ func TestState_Expand(t *testing.T) {
 			term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), Atom("!")}}, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-				&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+				&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 				Seq(",",
 					Atom("!"),
-					&Compound{Functor: "=", Args: []Term{Variable("_1"), Variable("_3")}},
+					&Compound{Functor: "=", Args: []Term{Variable(1), Variable(3)}},
 				),
 			}}, term)
 		})
@@ -7207,10 +7471,10 @@ func TestState_Expand(t *testing.T) {
 				term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), &Compound{Functor: `\+`, Args: []Term{Atom("a")}}}}, nil)
 				assert.NoError(t, err)
 				assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-					&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+					&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 					Seq(",",
-						&Compound{Functor: `\+`, Args: []Term{&Compound{Functor: "a", Args: []Term{Variable("_1"), Variable("_4")}}}},
-						&Compound{Functor: "=", Args: []Term{Variable("_1"), Variable("_3")}},
+						&Compound{Functor: `\+`, Args: []Term{&Compound{Functor: "a", Args: []Term{Variable(1), Variable(4)}}}},
+						&Compound{Functor: "=", Args: []Term{Variable(1), Variable(3)}},
 					),
 				}}, term)
 			})
@@ -7228,12 +7492,12 @@ func TestState_Expand(t *testing.T) {
 				term, err := state.Expand(&Compound{Functor: "-->", Args: []Term{Atom("s"), &Compound{Functor: "->", Args: []Term{Atom("a"), Atom("b")}}}}, nil)
 				assert.NoError(t, err)
 				assert.Equal(t, &Compound{Functor: ":-", Args: []Term{
-					&Compound{Functor: "s", Args: []Term{Variable("_1"), Variable("_3")}},
+					&Compound{Functor: "s", Args: []Term{Variable(1), Variable(3)}},
 					&Compound{
 						Functor: "->",
 						Args: []Term{
-							&Compound{Functor: "a", Args: []Term{Variable("_1"), Variable("_4")}},
-							&Compound{Functor: "b", Args: []Term{Variable("_4"), Variable("_3")}},
+							&Compound{Functor: "a", Args: []Term{Variable(1), Variable(4)}},
+							&Compound{Functor: "b", Args: []Term{Variable(4), Variable(3)}},
 						},
 					},
 				}}, term)
@@ -7260,18 +7524,18 @@ func TestState_Expand(t *testing.T) {
 				assert.Equal(t, &Compound{
 					Functor: ":-",
 					Args: []Term{
-						&Compound{Functor: "phrase1", Args: []Term{Variable("_1"), Variable("_3")}},
+						&Compound{Functor: "phrase1", Args: []Term{Variable(1), Variable(3)}},
 						&Compound{
 							Functor: ",",
 							Args: []Term{
 								&Compound{
 									Functor: ",",
 									Args: []Term{
-										&Compound{Functor: "phrase2", Args: []Term{Variable("_1"), Variable("_4")}},
-										&Compound{Functor: "phrase3", Args: []Term{Variable("_4"), Variable("_2")}},
+										&Compound{Functor: "phrase2", Args: []Term{Variable(1), Variable(4)}},
+										&Compound{Functor: "phrase3", Args: []Term{Variable(4), Variable(2)}},
 									},
 								},
-								&Compound{Functor: "=", Args: []Term{Variable("_3"), ListRest(Variable("_2"), Atom("word"))}},
+								&Compound{Functor: "=", Args: []Term{Variable(3), ListRest(Variable(2), Atom("word"))}},
 							},
 						},
 					},
@@ -7298,3 +7562,35 @@ func TestState_Expand(t *testing.T) {
 		})
 	})
 }
+
+func BenchmarkCopyTerm(b *testing.B) {
+	args := make([]Term, 100)
+	for i := range args {
+		args[i] = NewVariable()
+	}
+	in := &Compound{Functor: "f", Args: args}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = CopyTerm(in, NewVariable(), Success, nil).Force(context.Background())
+	}
+}
+
+func BenchmarkState_FindAll(b *testing.B) {
+	var state State
+	state.Register1("p", func(t Term, k func(*Env) *Promise, env *Env) *Promise {
+		ks := make([]func(context.Context) *Promise, 100)
+		for i := range ks {
+			ks[i] = func(context.Context) *Promise {
+				return Unify(t, &Compound{Functor: "f", Args: []Term{NewVariable(), NewVariable()}}, k, env)
+			}
+		}
+		return Delay(ks...)
+	})
+	x := NewVariable()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = state.FindAll(x, &Compound{Functor: "p", Args: []Term{x}}, NewVariable(), Success, nil).Force(context.Background())
+	}
+}
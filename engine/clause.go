@@ -3,64 +3,49 @@ package engine
 import (
 	"context"
 	"errors"
+	"math"
+	"sync/atomic"
 )
 
 type clauses []clause
 
 func (cs clauses) Call(vm *VM, args []Term, k func(*Env) *Promise, env *Env) *Promise {
-	if len(cs) == 0 {
-		return Bool(false)
-	}
-
-	if vm.OnCall == nil {
-		vm.OnCall = func(pi ProcedureIndicator, args []Term, env *Env) {}
-	}
-	if vm.OnExit == nil {
-		vm.OnExit = func(pi ProcedureIndicator, args []Term, env *Env) {}
-	}
-	if vm.OnFail == nil {
-		vm.OnFail = func(pi ProcedureIndicator, args []Term, env *Env) {}
-	}
-	if vm.OnRedo == nil {
-		vm.OnRedo = func(pi ProcedureIndicator, args []Term, env *Env) {}
+	var first Term
+	if len(args) > 0 {
+		first = env.Resolve(args[0])
 	}
 
 	var p *Promise
-	ks := make([]func(context.Context) *Promise, len(cs))
+	ks := make([]func(context.Context) *Promise, 0, len(cs))
 	for i := range cs {
-		i, c := i, cs[i]
-		ks[i] = func(context.Context) *Promise {
-			if i == 0 {
-				vm.OnCall(c.pi, args, env)
-			} else {
-				vm.OnRedo(c.pi, args, env)
-			}
-			vars := make([]Variable, len(c.vars))
-			for i := range vars {
-				vars[i] = NewVariable()
+		c := cs[i]
+
+		// Skip the clauses that never match so that we don't leave unnecessary choicepoints.
+		if !c.mayMatch(first) {
+			continue
+		}
+
+		ks = append(ks, func(context.Context) *Promise {
+			cont := k
+			if vm.coverage.enabled && debuggable(c.pi) {
+				cont = vm.coverage.enter(c.id, k)
 			}
-			return Delay(func(context.Context) *Promise {
-				env := env
-				return vm.exec(registers{
-					pc:   c.bytecode,
-					xr:   c.xrTable,
-					vars: vars,
-					cont: func(env *Env) *Promise {
-						vm.OnExit(c.pi, args, env)
-						return k(env)
-					},
-					args:      List(args...),
-					astack:    List(),
-					pi:        c.piTable,
-					env:       env,
-					cutParent: p,
-				})
-			}, func(context.Context) *Promise {
-				env := env
-				vm.OnFail(c.pi, args, env)
-				return Bool(false)
+			return vm.exec(registers{
+				pc:        c.bytecode,
+				xr:        c.xrTable,
+				pi:        c.piTable,
+				vars:      make([]Term, len(c.vars)),
+				cont:      cont,
+				args:      args,
+				env:       env,
+				cutParent: p,
+				base:      env,
+				last:      NewVariable(), // A fresh variable so that the bindings made after it have newer stamps.
 			})
-		}
+		})
+	}
+	if len(ks) == 0 {
+		return Bool(false)
 	}
 	p = Delay(ks...)
 	return p
@@ -74,15 +59,52 @@ type (
 )
 
 type clause struct {
+	id       uint64
 	pi       ProcedureIndicator
 	raw      Term
+	pos      SourcePos
 	xrTable  []Term
 	piTable  []ProcedureIndicator
 	vars     []Variable
 	bytecode bytecode
 }
 
-func compile(t Term) (clauses, error) {
+// mayMatch checks if the first argument of the head possibly matches with the resolved first argument of the goal.
+func (c *clause) mayMatch(first Term) bool {
+	if first == nil || len(c.bytecode) == 0 {
+		return true
+	}
+	if _, ok := first.(Variable); ok {
+		return true
+	}
+	switch i := c.bytecode[0]; i.opcode {
+	case opGetConst:
+		if _, ok := first.(*Compound); ok {
+			return false
+		}
+		_, ok := c.xrTable[i.operand].Unify(first, false, nil)
+		return ok
+	case opGetFunctor:
+		f, ok := first.(*Compound)
+		if !ok {
+			return false
+		}
+		pi := c.piTable[i.operand]
+		return f.Functor == pi.Name && Integer(len(f.Args)) == pi.Arity
+	default:
+		return true
+	}
+}
+
+// SourcePos is a position in a source file.
+type SourcePos struct {
+	File string
+	Line int
+}
+
+var clauseCounter uint64
+
+func compile(t Term, pos SourcePos) (clauses, error) {
 	if t, ok := t.(*Compound); ok && t.Functor == ":-" {
 		var cs []clause
 		head, body := t.Args[0], t.Args[1]
@@ -91,17 +113,24 @@ func compile(t Term) (clauses, error) {
 			if err != nil {
 				return err
 			}
+			c.id = atomic.AddUint64(&clauseCounter, 1)
 			c.raw = t
+			c.pos = pos
 			cs = append(cs, c)
 			return nil
 		}, nil); err != nil {
-			return nil, TypeErrorCallable(body)
+			if errors.Is(err, errNotCallable) {
+				return nil, TypeErrorCallable(body)
+			}
+			return nil, err
 		}
 		return cs, nil
 	}
 
 	c, err := compileClause(t, nil)
+	c.id = atomic.AddUint64(&clauseCounter, 1)
 	c.raw = t
+	c.pos = pos
 	return []clause{c}, err
 }
 
@@ -113,12 +142,14 @@ func compileClause(head Term, body Term) (clause, error) {
 	case *Compound:
 		c.pi = ProcedureIndicator{Name: head.Functor, Arity: Integer(len(head.Args))}
 		for _, a := range head.Args {
-			c.compileArg(a)
+			if err := c.compileGetArg(a); err != nil {
+				return c, err
+			}
 		}
 	}
 	if body != nil {
 		if err := c.compileBody(body); err != nil {
-			return c, TypeErrorCallable(body)
+			return c, err
 		}
 	}
 	c.bytecode = append(c.bytecode, instruction{opcode: opExit})
@@ -127,9 +158,112 @@ func compileClause(head Term, body Term) (clause, error) {
 
 func (c *clause) compileBody(body Term) error {
 	c.bytecode = append(c.bytecode, instruction{opcode: opEnter})
-	return EachSeq(body, ",", func(elem Term) error {
-		return c.compilePred(elem)
-	}, nil)
+	return c.compileGoal(body, false)
+}
+
+// compileGoal compiles the control constructs into the instructions and the other goals into calls.
+// If local is true, the goal is in the condition of if-then-else or negation and cuts are local to the condition.
+func (c *clause) compileGoal(g Term, local bool) error {
+	switch g := g.(type) {
+	case Atom:
+		if g == "!" {
+			op := opCut
+			if local {
+				op = opCutLocal
+			}
+			c.bytecode = append(c.bytecode, instruction{opcode: op})
+			return nil
+		}
+	case *Compound:
+		switch {
+		case g.Functor == "," && len(g.Args) == 2:
+			if err := c.compileGoal(g.Args[0], local); err != nil {
+				return err
+			}
+			return c.compileGoal(g.Args[1], local)
+		case g.Functor == ";" && len(g.Args) == 2:
+			if cond, ok := g.Args[0].(*Compound); ok && len(cond.Args) == 2 {
+				switch cond.Functor {
+				case "->":
+					return c.compileIf(cond.Args[0], cond.Args[1], g.Args[1], opThen, local)
+				case "*->":
+					return c.compileIf(cond.Args[0], cond.Args[1], g.Args[1], opSoftThen, local)
+				}
+			}
+			return c.compileOr(g.Args[0], g.Args[1], local)
+		case g.Functor == "->" && len(g.Args) == 2:
+			return c.compileIf(g.Args[0], g.Args[1], nil, opThen, local)
+		case g.Functor == "*->" && len(g.Args) == 2:
+			return c.compileIf(g.Args[0], g.Args[1], nil, opSoftThen, local)
+		case g.Functor == "\\+" && len(g.Args) == 1:
+			return c.compileNot(g.Args[0])
+		}
+	}
+	return c.compilePred(g)
+}
+
+// compileOr compiles a disjunction. The layout is:
+//
+//	try L; <left>; jump E; L: <right>; E:
+func (c *clause) compileOr(left, right Term, local bool) error {
+	try := c.emitJump(opTry)
+	if err := c.compileGoal(left, local); err != nil {
+		return err
+	}
+	end := c.emitJump(opJump)
+	c.patchJump(try)
+	if err := c.compileGoal(right, local); err != nil {
+		return err
+	}
+	c.patchJump(end)
+	return nil
+}
+
+// compileIf compiles an if-then-else or a soft-cut. If els is nil, the else branch fails. The layout is:
+//
+//	if L; <cond>; then; <then>; jump E; L: <else>; E:
+func (c *clause) compileIf(cond, then, els Term, thenOp opcode, local bool) error {
+	ite := c.emitJump(opIf)
+	if err := c.compileGoal(cond, true); err != nil {
+		return err
+	}
+	c.bytecode = append(c.bytecode, instruction{opcode: thenOp})
+	if err := c.compileGoal(then, local); err != nil {
+		return err
+	}
+	end := c.emitJump(opJump)
+	c.patchJump(ite)
+	if els == nil {
+		c.bytecode = append(c.bytecode, instruction{opcode: opFail})
+	} else if err := c.compileGoal(els, local); err != nil {
+		return err
+	}
+	c.patchJump(end)
+	return nil
+}
+
+// compileNot compiles a negation as (Goal -> fail; true). The layout is:
+//
+//	if L; <goal>; then; fail; L:
+func (c *clause) compileNot(goal Term) error {
+	ite := c.emitJump(opIf)
+	if err := c.compileGoal(goal, true); err != nil {
+		return err
+	}
+	c.bytecode = append(c.bytecode, instruction{opcode: opThen}, instruction{opcode: opFail})
+	c.patchJump(ite)
+	return nil
+}
+
+// emitJump emits the instruction which jumps forward and returns its position to patch later.
+func (c *clause) emitJump(op opcode) int {
+	c.bytecode = append(c.bytecode, instruction{opcode: op})
+	return len(c.bytecode) - 1
+}
+
+// patchJump sets the operand of the instruction at pos so that it jumps to the next instruction to emit.
+func (c *clause) patchJump(pos int) {
+	c.bytecode[pos].operand = uint32(len(c.bytecode) - pos)
 }
 
 var errNotCallable = errors.New("not callable")
@@ -147,60 +281,130 @@ func (c *clause) compilePred(p Term) error {
 			c.bytecode = append(c.bytecode, instruction{opcode: opCut})
 			return nil
 		}
-		c.bytecode = append(c.bytecode, instruction{opcode: opCall, operand: c.piOffset(ProcedureIndicator{Name: p, Arity: 0})})
+		o, err := c.piOffset(ProcedureIndicator{Name: p, Arity: 0})
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opCall, operand: o})
 		return nil
 	case *Compound:
 		for _, a := range p.Args {
-			c.compileArg(a)
+			if err := c.compilePutArg(a); err != nil {
+				return err
+			}
 		}
-		c.bytecode = append(c.bytecode, instruction{opcode: opCall, operand: c.piOffset(ProcedureIndicator{Name: p.Functor, Arity: Integer(len(p.Args))})})
+		o, err := c.piOffset(ProcedureIndicator{Name: p.Functor, Arity: Integer(len(p.Args))})
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opCall, operand: o})
 		return nil
 	default:
 		return errNotCallable
 	}
 }
 
-func (c *clause) compileArg(a Term) {
+func (c *clause) compileGetArg(a Term) error {
+	switch a := a.(type) {
+	case Variable:
+		o, err := c.varOffset(a)
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opGetVar, operand: o})
+	case *Compound:
+		o, err := c.piOffset(ProcedureIndicator{Name: a.Functor, Arity: Integer(len(a.Args))})
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opGetFunctor, operand: o})
+		for _, n := range a.Args {
+			if err := c.compileGetArg(n); err != nil {
+				return err
+			}
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opPop})
+	default:
+		o, err := c.xrOffset(a)
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opGetConst, operand: o})
+	}
+	return nil
+}
+
+func (c *clause) compilePutArg(a Term) error {
 	switch a := a.(type) {
 	case Variable:
-		c.bytecode = append(c.bytecode, instruction{opcode: opVar, operand: c.varOffset(a)})
+		o, err := c.varOffset(a)
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opPutVar, operand: o})
 	case *Compound:
-		c.bytecode = append(c.bytecode, instruction{opcode: opFunctor, operand: c.piOffset(ProcedureIndicator{Name: a.Functor, Arity: Integer(len(a.Args))})})
+		o, err := c.piOffset(ProcedureIndicator{Name: a.Functor, Arity: Integer(len(a.Args))})
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opPutFunctor, operand: o})
 		for _, n := range a.Args {
-			c.compileArg(n)
+			if err := c.compilePutArg(n); err != nil {
+				return err
+			}
 		}
 		c.bytecode = append(c.bytecode, instruction{opcode: opPop})
 	default:
-		c.bytecode = append(c.bytecode, instruction{opcode: opConst, operand: c.xrOffset(a)})
+		o, err := c.xrOffset(a)
+		if err != nil {
+			return err
+		}
+		c.bytecode = append(c.bytecode, instruction{opcode: opPutConst, operand: o})
 	}
+	return nil
 }
 
-func (c *clause) xrOffset(o Term) byte {
+// maxOperand is the maximum number of entries in each of the constant, variable, and functor tables of a clause.
+const maxOperand uint64 = math.MaxUint32
+
+// operandLimit is the limit actually applied. It's lower than maxOperand only in tests.
+var operandLimit = maxOperand
+
+func (c *clause) xrOffset(o Term) (uint32, error) {
 	for i, r := range c.xrTable {
 		if _, ok := r.Unify(o, false, nil); ok {
-			return byte(i)
+			return uint32(i), nil
 		}
 	}
+	if uint64(len(c.xrTable)) >= operandLimit {
+		return 0, resourceError(Atom("constants"), Atom("Too many constants in a clause."))
+	}
 	c.xrTable = append(c.xrTable, o)
-	return byte(len(c.xrTable) - 1)
+	return uint32(len(c.xrTable) - 1), nil
 }
 
-func (c *clause) varOffset(o Variable) byte {
+func (c *clause) varOffset(o Variable) (uint32, error) {
 	for i, v := range c.vars {
 		if v == o {
-			return byte(i)
+			return uint32(i), nil
 		}
 	}
+	if uint64(len(c.vars)) >= operandLimit {
+		return 0, resourceError(Atom("variables"), Atom("Too many variables in a clause."))
+	}
 	c.vars = append(c.vars, o)
-	return byte(len(c.vars) - 1)
+	return uint32(len(c.vars) - 1), nil
 }
 
-func (c *clause) piOffset(o ProcedureIndicator) byte {
+func (c *clause) piOffset(o ProcedureIndicator) (uint32, error) {
 	for i, r := range c.piTable {
 		if r == o {
-			return byte(i)
+			return uint32(i), nil
 		}
 	}
+	if uint64(len(c.piTable)) >= operandLimit {
+		return 0, resourceError(Atom("functors"), Atom("Too many functors in a clause."))
+	}
 	c.piTable = append(c.piTable, o)
-	return byte(len(c.piTable) - 1)
+	return uint32(len(c.piTable) - 1), nil
 }
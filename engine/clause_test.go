@@ -0,0 +1,457 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	t.Run("many constants", func(t *testing.T) {
+		args := make([]Term, 1000)
+		for i := range args {
+			args[i] = Atom(fmt.Sprintf("c%d", i))
+		}
+		cs, err := compile(&Compound{Functor: "foo", Args: args}, SourcePos{})
+		assert.NoError(t, err)
+		assert.Len(t, cs, 1)
+		assert.Len(t, cs[0].xrTable, 1000)
+
+		var vm VM
+		ok, err := cs.Call(&vm, args, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		wrong := make([]Term, len(args))
+		copy(wrong, args)
+		wrong[300] = Atom("c44")
+		ok, err = cs.Call(&vm, wrong, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("many variables", func(t *testing.T) {
+		vars := make([]Term, 1000)
+		for i := range vars {
+			vars[i] = NewVariable()
+		}
+		cs, err := compile(&Compound{
+			Functor: ":-",
+			Args: []Term{
+				&Compound{Functor: "foo", Args: append(vars, testVar("Y"))},
+				&Compound{Functor: "=", Args: []Term{testVar("Y"), vars[999]}},
+			},
+		}, SourcePos{})
+		assert.NoError(t, err)
+		assert.Len(t, cs, 1)
+		assert.Len(t, cs[0].vars, 1001)
+
+		vm := VM{
+			procedures: map[ProcedureIndicator]procedure{
+				{Name: "=", Arity: 2}: predicate2(Unify),
+			},
+		}
+		args := make([]Term, 1001)
+		for i := range vars {
+			args[i] = Integer(i)
+		}
+		args[1000] = testVar("Result")
+		ok, err := cs.Call(&vm, args, func(env *Env) *Promise {
+			assert.Equal(t, Integer(999), env.Resolve(testVar("Result")))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("many functors", func(t *testing.T) {
+		args := make([]Term, 1000)
+		for i := range args {
+			args[i] = &Compound{Functor: Atom(fmt.Sprintf("f%d", i)), Args: []Term{Atom("a")}}
+		}
+		cs, err := compile(&Compound{Functor: "foo", Args: args}, SourcePos{})
+		assert.NoError(t, err)
+		assert.Len(t, cs, 1)
+		assert.Len(t, cs[0].piTable, 1000)
+
+		var vm VM
+		ok, err := cs.Call(&vm, args, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		wrong := make([]Term, len(args))
+		copy(wrong, args)
+		wrong[300] = &Compound{Functor: "f44", Args: []Term{Atom("a")}}
+		ok, err = cs.Call(&vm, wrong, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("compound in head", func(t *testing.T) {
+		cs, err := compile(&Compound{
+			Functor: "foo",
+			Args: []Term{
+				&Compound{Functor: "f", Args: []Term{testVar("X"), Atom("a"), testVar("X")}},
+			},
+		}, SourcePos{})
+		assert.NoError(t, err)
+		assert.Equal(t, bytecode{
+			{opcode: opGetFunctor, operand: 0},
+			{opcode: opGetVar, operand: 0},
+			{opcode: opGetConst, operand: 0},
+			{opcode: opGetVar, operand: 0},
+			{opcode: opPop},
+			{opcode: opExit},
+		}, cs[0].bytecode)
+
+		var vm VM
+
+		t.Run("read", func(t *testing.T) {
+			ok, err := cs.Call(&vm, []Term{
+				&Compound{Functor: "f", Args: []Term{Atom("b"), Atom("a"), Atom("b")}},
+			}, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = cs.Call(&vm, []Term{
+				&Compound{Functor: "f", Args: []Term{Atom("b"), Atom("a"), Atom("c")}},
+			}, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+
+		t.Run("write", func(t *testing.T) {
+			ok, err := cs.Call(&vm, []Term{testVar("Y")}, func(env *Env) *Promise {
+				c, ok := env.Resolve(testVar("Y")).(*Compound)
+				assert.True(t, ok)
+				assert.Equal(t, Atom("f"), c.Functor)
+				assert.Len(t, c.Args, 3)
+				assert.Equal(t, env.Resolve(c.Args[0]), env.Resolve(c.Args[2]))
+				assert.Equal(t, Atom("a"), env.Resolve(c.Args[1]))
+				return Bool(true)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	})
+
+	t.Run("compound in body", func(t *testing.T) {
+		cs, err := compile(&Compound{
+			Functor: ":-",
+			Args: []Term{
+				&Compound{Functor: "foo", Args: []Term{testVar("X")}},
+				&Compound{Functor: "bar", Args: []Term{
+					&Compound{Functor: "f", Args: []Term{testVar("X"), &Compound{Functor: "g", Args: []Term{testVar("Y")}}}},
+				}},
+			},
+		}, SourcePos{})
+		assert.NoError(t, err)
+		assert.Equal(t, bytecode{
+			{opcode: opGetVar, operand: 0},
+			{opcode: opEnter},
+			{opcode: opPutFunctor, operand: 0},
+			{opcode: opPutVar, operand: 0},
+			{opcode: opPutFunctor, operand: 1},
+			{opcode: opPutVar, operand: 1},
+			{opcode: opPop},
+			{opcode: opPop},
+			{opcode: opCall, operand: 2},
+			{opcode: opExit},
+		}, cs[0].bytecode)
+
+		vm := VM{
+			procedures: map[ProcedureIndicator]procedure{
+				{Name: "bar", Arity: 1}: predicate1(func(t1 Term, k func(*Env) *Promise, env *Env) *Promise {
+					c, ok := env.Resolve(t1).(*Compound)
+					assert.True(t, ok)
+					assert.Equal(t, Atom("f"), c.Functor)
+					assert.Equal(t, Atom("a"), env.Resolve(c.Args[0]))
+					g, ok := env.Resolve(c.Args[1]).(*Compound)
+					assert.True(t, ok)
+					assert.Equal(t, Atom("g"), g.Functor)
+					assert.Len(t, g.Args, 1)
+					return k(env)
+				}),
+			},
+		}
+		ok, err := cs.Call(&vm, []Term{Atom("a")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("control constructs", func(t *testing.T) {
+		cs, err := compile(&Compound{
+			Functor: ":-",
+			Args: []Term{
+				Atom("foo"),
+				Seq(",",
+					Seq(";", &Compound{Functor: "->", Args: []Term{Seq(",", Atom("a"), Atom("!")), Atom("b")}}, Atom("c")),
+					&Compound{Functor: "\\+", Args: []Term{Atom("d")}},
+					Seq(";", Atom("e"), Atom("!")),
+				),
+			},
+		}, SourcePos{})
+		assert.NoError(t, err)
+		assert.Len(t, cs, 1)
+		assert.Equal(t, bytecode{
+			{opcode: opEnter},
+			{opcode: opIf, operand: 6},
+			{opcode: opCall, operand: 0},
+			{opcode: opCutLocal},
+			{opcode: opThen},
+			{opcode: opCall, operand: 1},
+			{opcode: opJump, operand: 2},
+			{opcode: opCall, operand: 2},
+			{opcode: opIf, operand: 4},
+			{opcode: opCall, operand: 3},
+			{opcode: opThen},
+			{opcode: opFail},
+			{opcode: opTry, operand: 3},
+			{opcode: opCall, operand: 4},
+			{opcode: opJump, operand: 2},
+			{opcode: opCut},
+			{opcode: opExit},
+		}, cs[0].bytecode)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		defer func(n uint64) {
+			operandLimit = n
+		}(operandLimit)
+		operandLimit = 2
+
+		t.Run("constants", func(t *testing.T) {
+			_, err := compile(&Compound{Functor: "foo", Args: []Term{Atom("a"), Atom("b"), Atom("c")}}, SourcePos{})
+			assert.Equal(t, resourceError(Atom("constants"), Atom("Too many constants in a clause.")), err)
+		})
+
+		t.Run("variables", func(t *testing.T) {
+			_, err := compile(&Compound{Functor: "foo", Args: []Term{testVar("X"), testVar("Y"), testVar("Z")}}, SourcePos{})
+			assert.Equal(t, resourceError(Atom("variables"), Atom("Too many variables in a clause.")), err)
+		})
+
+		t.Run("functors", func(t *testing.T) {
+			_, err := compile(&Compound{
+				Functor: ":-",
+				Args: []Term{
+					Atom("foo"),
+					Seq(",", Atom("a"), Atom("b"), Atom("c")),
+				},
+			}, SourcePos{})
+			assert.Equal(t, resourceError(Atom("functors"), Atom("Too many functors in a clause.")), err)
+		})
+	})
+}
+
+func TestClauses_Call(t *testing.T) {
+	t.Run("deterministic recursion runs in constant memory", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("loops ten million times")
+		}
+
+		const n = 10000000
+
+		var (
+			state State
+			m     runtime.MemStats
+			heap  uint64
+		)
+		state.Register2("is", state.Is)
+		state.Register1("measure", func(i Term, k func(*Env) *Promise, env *Env) *Promise {
+			if env.Resolve(i) == Integer(n/10) {
+				runtime.GC()
+				runtime.ReadMemStats(&m)
+				heap = m.HeapAlloc
+			}
+			return k(env)
+		})
+
+		// count(N, N) :- !.
+		// count(I, N) :- measure(I), I1 is I + 1, count(I1, N).
+		var (
+			i, n1, i1 = NewVariable(), NewVariable(), NewVariable()
+			cs        clauses
+		)
+		for _, c := range []Term{
+			&Compound{Functor: ":-", Args: []Term{
+				&Compound{Functor: "count", Args: []Term{n1, n1}},
+				Atom("!"),
+			}},
+			&Compound{Functor: ":-", Args: []Term{
+				&Compound{Functor: "count", Args: []Term{i, n1}},
+				Seq(",",
+					&Compound{Functor: "measure", Args: []Term{i}},
+					&Compound{Functor: "is", Args: []Term{i1, &Compound{Functor: "+", Args: []Term{i, Integer(1)}}}},
+					&Compound{Functor: "count", Args: []Term{i1, n1}},
+				),
+			}},
+		} {
+			c, err := compile(c, SourcePos{})
+			assert.NoError(t, err)
+			cs = append(cs, c...)
+		}
+		state.procedures[ProcedureIndicator{Name: "count", Arity: 2}] = cs
+
+		ok, err := state.Arrive(ProcedureIndicator{Name: "count", Arity: 2}, []Term{Integer(0), Integer(n)}, func(env *Env) *Promise {
+			runtime.GC()
+			runtime.ReadMemStats(&m)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Less(t, int64(m.HeapAlloc)-int64(heap), int64(1<<20))
+	})
+
+	t.Run("deterministic recursion in if-then-else runs in constant memory", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("loops a million times")
+		}
+
+		const n = 1000000
+
+		var (
+			vm   VM
+			i    int
+			m    runtime.MemStats
+			heap uint64
+		)
+		vm.Register0("step", func(k func(*Env) *Promise, env *Env) *Promise {
+			i++
+			if i == n/10 {
+				runtime.GC()
+				runtime.ReadMemStats(&m)
+				heap = m.HeapAlloc
+			}
+			return k(env)
+		})
+		vm.Register0("done", func(k func(*Env) *Promise, env *Env) *Promise {
+			if i < n {
+				return Bool(false)
+			}
+			return k(env)
+		})
+		vm.Register0("true", func(k func(*Env) *Promise, env *Env) *Promise {
+			return k(env)
+		})
+		cs, err := compile(&Compound{
+			Functor: ":-",
+			Args: []Term{Atom("loop"), Seq(";",
+				&Compound{Functor: "->", Args: []Term{
+					&Compound{Functor: "\\+", Args: []Term{Atom("done")}},
+					Seq(",", Atom("step"), Atom("loop")),
+				}},
+				Atom("true"),
+			)},
+		}, SourcePos{})
+		assert.NoError(t, err)
+		vm.procedures[ProcedureIndicator{Name: "loop", Arity: 0}] = cs
+
+		ok, err := vm.Arrive(ProcedureIndicator{Name: "loop", Arity: 0}, nil, func(env *Env) *Promise {
+			runtime.GC()
+			runtime.ReadMemStats(&m)
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, n, i)
+		assert.Less(t, int64(m.HeapAlloc)-int64(heap), int64(1<<20))
+	})
+
+	t.Run("first argument indexing", func(t *testing.T) {
+		cs, err := compile(&Compound{Functor: "foo", Args: []Term{Atom("a")}}, SourcePos{})
+		assert.NoError(t, err)
+		for _, c := range []Term{
+			&Compound{Functor: "foo", Args: []Term{Integer(1)}},
+			&Compound{Functor: "foo", Args: []Term{&Compound{Functor: "f", Args: []Term{Atom("a")}}}},
+			&Compound{Functor: "foo", Args: []Term{testVar("X")}},
+		} {
+			more, err := compile(c, SourcePos{})
+			assert.NoError(t, err)
+			cs = append(cs, more...)
+		}
+
+		var vm VM
+
+		t.Run("atom", func(t *testing.T) {
+			var n int
+			ok, err := cs.Call(&vm, []Term{Atom("a")}, func(*Env) *Promise {
+				n++
+				return Bool(false)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.Equal(t, 2, n)
+		})
+
+		t.Run("compound", func(t *testing.T) {
+			var n int
+			ok, err := cs.Call(&vm, []Term{&Compound{Functor: "f", Args: []Term{testVar("Y")}}}, func(*Env) *Promise {
+				n++
+				return Bool(false)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.Equal(t, 2, n)
+		})
+
+		t.Run("variable", func(t *testing.T) {
+			var n int
+			ok, err := cs.Call(&vm, []Term{testVar("Y")}, func(*Env) *Promise {
+				n++
+				return Bool(false)
+			}, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			assert.Equal(t, 4, n)
+		})
+
+		t.Run("no match", func(t *testing.T) {
+			c, err := compile(&Compound{Functor: "foo", Args: []Term{Atom("a")}}, SourcePos{})
+			assert.NoError(t, err)
+			ok, err := c.Call(&vm, []Term{Atom("b")}, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	})
+}
+
+func BenchmarkClauses_Call(b *testing.B) {
+	x, y := NewVariable(), NewVariable()
+	cs, err := compile(&Compound{
+		Functor: "foo",
+		Args:    []Term{&Compound{Functor: "f", Args: []Term{x, y}}, x, y},
+	}, SourcePos{})
+	assert.NoError(b, err)
+
+	var vm VM
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = cs.Call(&vm, []Term{NewVariable(), NewVariable(), NewVariable()}, Success, nil).Force(context.Background())
+	}
+}
+
+// withoutClauseIDs clears the IDs of the clauses so that we can compare them with expected ones.
+func withoutClauseIDs(p procedure) procedure {
+	clear := func(cs clauses) clauses {
+		ret := make(clauses, len(cs))
+		for i, c := range cs {
+			c.id = 0
+			ret[i] = c
+		}
+		return ret
+	}
+	switch p := p.(type) {
+	case clauses:
+		return clear(p)
+	case static:
+		return static{clear(p.clauses)}
+	case builtin:
+		return builtin{clear(p.clauses)}
+	default:
+		return p
+	}
+}
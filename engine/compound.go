@@ -43,6 +43,21 @@ func (c *Compound) Unparse(emit func(Token), env *Env, opts ...WriteOption) {
 		o(&wto)
 	}
 
+	if wto.maxDepth > 0 && wto.depth >= wto.maxDepth {
+		Atom("...").Unparse(emit, env, opts...)
+		return
+	}
+
+	if wto.portray != nil {
+		if s, ok := wto.portray(c, env); ok {
+			// The output of portray/1 is opaque. Like a quoted atom, it needs no spaces around it.
+			emit(Token{Kind: TokenQuotedIdent, Val: s})
+			return
+		}
+	}
+
+	opts = append(opts, withDepth(wto.depth+1))
+
 	if c.Functor == "." && len(c.Args) == 2 {
 		c.unparseList(emit, env, opts...)
 		return
@@ -184,8 +199,13 @@ func (c *Compound) unparseList(emit func(Token), env *Env, opts ...WriteOption)
 	emit(Token{Kind: TokenBracketL, Val: "["})
 	env.Resolve(c.Args[0]).Unparse(emit, env, opts...)
 	t := env.Resolve(c.Args[1])
-	for {
+	for n := wto.depth; ; n++ {
 		if l, ok := t.(*Compound); ok && l.Functor == "." && len(l.Args) == 2 {
+			if wto.maxDepth > 0 && n >= wto.maxDepth {
+				emit(Token{Kind: TokenBar, Val: "|"})
+				Atom("...").Unparse(emit, env, opts...)
+				break
+			}
 			emit(Token{Kind: TokenComma, Val: ","})
 			env.Resolve(l.Args[0]).Unparse(emit, env, opts...)
 			t = env.Resolve(l.Args[1])
@@ -208,15 +228,17 @@ func (c *Compound) unparseBlock(emit func(Token), env *Env, opts ...WriteOption)
 }
 
 func (c *Compound) unparseNumberVar(n Integer, emit func(Token)) {
+	emit(Token{Kind: TokenVariable, Val: letterName(int(n))})
+}
+
+// letterName returns A, B, ..., Z, A1, B1, ... for 0, 1, 2, ....
+func letterName(n int) string {
 	const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	i, j := int(n)%len(letters), int(n)/len(letters)
+	i, j := n%len(letters), n/len(letters)
 	if j == 0 {
-		s := string(letters[i])
-		emit(Token{Kind: TokenVariable, Val: s})
-		return
+		return string(letters[i])
 	}
-	s := fmt.Sprintf("%s%d", string(letters[i]), j)
-	emit(Token{Kind: TokenVariable, Val: s})
+	return fmt.Sprintf("%s%d", string(letters[i]), j)
 }
 
 func (c *Compound) unparse(emit func(Token), env *Env, opts ...WriteOption) {
@@ -354,8 +376,8 @@ func EachAlternative(seq Term, f func(elem Term) error, env *Env) error {
 			return false
 		}
 
-		// if-then-else construct
-		if c, ok := p.Args[0].(*Compound); ok && c.Functor == "->" && len(c.Args) == 2 {
+		// if-then-else and soft-cut constructs
+		if c, ok := p.Args[0].(*Compound); ok && (c.Functor == "->" || c.Functor == "*->") && len(c.Args) == 2 {
 			return false
 		}
 
@@ -27,20 +27,20 @@ func TestCompound_Unify(t *testing.T) {
 
 	t.Run("variable", func(t *testing.T) {
 		t.Run("free", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env, ok := unit.Unify(v, false, nil)
 			assert.True(t, ok)
 			assert.Equal(t, &unit, env.Resolve(v))
 		})
 		t.Run("bound to the same value", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env := NewEnv().
 				Bind(v, &unit)
 			_, ok := unit.Unify(v, false, env)
 			assert.True(t, ok)
 		})
 		t.Run("bound to a different value", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env := NewEnv().
 				Bind(v, &Compound{
 					Functor: "foo",
@@ -73,7 +73,7 @@ func TestCompound_Unify(t *testing.T) {
 			Args:    []Term{Atom("baz")},
 		}, false, nil)
 		assert.False(t, ok)
-		v := Variable("X")
+		v := testVar("X")
 		env, ok := unit.Unify(&Compound{
 			Functor: "foo",
 			Args:    []Term{v},
@@ -495,7 +495,7 @@ func TestEnv_Set(t *testing.T) {
 func TestEachList(t *testing.T) {
 	t.Run("variable", func(t *testing.T) {
 		var ret []Term
-		assert.Equal(t, ErrInstantiation, EachList(Variable("X"), func(elem Term) error {
+		assert.Equal(t, ErrInstantiation, EachList(testVar("X"), func(elem Term) error {
 			ret = append(ret, elem)
 			return nil
 		}, nil))
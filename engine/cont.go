@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// resetFrame is a delimiter of continuations set by reset/3 or by a call of a captured continuation.
+// The frames live in the promise stack as the delimiter of their boxes so that they follow the execution.
+type resetFrame struct {
+	// ball is the term that shift/1 unifies with, or nil if the frame is of a call of a continuation.
+	ball Term
+
+	// exit continues the execution after the frame with the captured continuation or 0.
+	exit func(cont Term, env *Env) *Promise
+
+	// box is the promise that delimits the frame and base is the lowest promise of the frame.
+	box, base *Promise
+
+	// active is true while the execution is inside the frame.
+	active bool
+}
+
+// Continuation is the rest of the execution up to the nearest reset/3 captured by shift/1.
+type Continuation struct {
+	k func(*Env) *Promise
+
+	// env is the bindings when the continuation was captured.
+	env *Env
+
+	// frames are the delimiters inside the continuation from the innermost to the outermost.
+	frames []*resetFrame
+
+	// height is the height of the stack when the continuation was captured.
+	height int
+}
+
+// Unify unifies the continuation with t.
+func (c *Continuation) Unify(t Term, occursCheck bool, env *Env) (*Env, bool) {
+	switch t := env.Resolve(t).(type) {
+	case *Continuation:
+		return env, c == t
+	case Variable:
+		return t.Unify(c, occursCheck, env)
+	default:
+		return env, false
+	}
+}
+
+// Unparse emits tokens that represent the continuation.
+func (c *Continuation) Unparse(emit func(Token), _ *Env, _ ...WriteOption) {
+	emit(Token{Kind: TokenIdent, Val: fmt.Sprintf("<continuation>(%p)", c)})
+}
+
+// Compare compares the continuation to another term.
+func (c *Continuation) Compare(t Term, env *Env) int64 {
+	switch t := env.Resolve(t).(type) {
+	case *Continuation:
+		if c == t {
+			return 0
+		}
+		return 1
+	default:
+		return 1
+	}
+}
+
+// Reset calls goal. If goal calls shift/1 with a term that unifies with ball, reset/3 exits right away and cont is
+// unified with a goal that calls the rest of goal after shift/1. Otherwise, cont is unified with 0 once goal exits.
+func (state *State) Reset(goal, ball, cont Term, k func(*Env) *Promise, env *Env) *Promise {
+	f := resetFrame{
+		ball: ball,
+		exit: func(c Term, env *Env) *Promise {
+			return Unify(cont, c, k, env)
+		},
+	}
+	return delimit(&f, func() *Promise {
+		return state.Call(goal, leaveReset, env)
+	})
+}
+
+// Shift makes the nearest reset/3 whose ball unifies with ball exit with the continuation up to the reset/3.
+func (state *State) Shift(ball Term, k func(*Env) *Promise, env *Env) *Promise {
+	return &Promise{delimited: func(s promiseStack) *Promise {
+		frames := s.delimiters()
+		for i, f := range frames {
+			if f.ball == nil {
+				continue
+			}
+			if fenv, ok := f.ball.Unify(ball, false, env); ok {
+				c := Continuation{
+					k:      k,
+					env:    env,
+					frames: make([]*resetFrame, i),
+					height: len(s),
+				}
+				for j, g := range frames[:i] {
+					c.frames[j] = &resetFrame{ball: g.ball, exit: g.exit}
+				}
+				return leave(f, frames[:i], &Compound{Functor: "call_continuation", Args: []Term{&c}}, fenv)
+			}
+		}
+		return Error(ExistenceError("reset", env.Resolve(ball)))
+	}}
+}
+
+// CallContinuation calls the continuation captured by shift/1.
+func (state *State) CallContinuation(cont Term, k func(*Env) *Promise, env *Env) *Promise {
+	switch c := env.Resolve(cont).(type) {
+	case Variable:
+		return Error(ErrInstantiation)
+	case *Continuation:
+		return &Promise{delimited: func(s promiseStack) *Promise {
+			// The end of the continuation leads to k instead of the original reset/3.
+			// Inside the continuation, the bindings when it was captured take precedence over the current ones.
+			f := resetFrame{
+				exit: func(_ Term, cenv *Env) *Promise {
+					return k(env.merge(cenv))
+				},
+			}
+
+			// The cuts in the continuation refer to the promises of the original execution.
+			// We raise the frame above them so that it stops the cuts.
+			return pad(c.height+1-len(s), &f, func() *Promise {
+				return delimit(&f, func() *Promise {
+					return c.resume(len(c.frames), c.env.merge(env))
+				})
+			})
+		}}
+	default:
+		return Error(TypeError("continuation", c))
+	}
+}
+
+// pad pushes n choicepoints which simply fail below the frame f.
+func pad(n int, f *resetFrame, k func() *Promise) *Promise {
+	if n <= 0 {
+		return k()
+	}
+	p := Delay(func(context.Context) *Promise {
+		return pad(n-1, f, k)
+	}, func(context.Context) *Promise {
+		return Bool(false)
+	})
+	if f.base == nil {
+		f.base = p
+	}
+	return p
+}
+
+// resume restores the n outermost delimiters inside the continuation and continues the execution.
+func (c *Continuation) resume(n int, env *Env) *Promise {
+	if n == 0 {
+		return c.k(env)
+	}
+	g := c.frames[n-1]
+	f := resetFrame{ball: g.ball, exit: g.exit}
+	return delimit(&f, func() *Promise {
+		return c.resume(n-1, env)
+	})
+}
+
+// delimit pushes the delimiter f and runs the execution inside it.
+func delimit(f *resetFrame, run func() *Promise) *Promise {
+	f.box = &Promise{
+		delayed: []func(context.Context) *Promise{
+			func(context.Context) *Promise {
+				f.active = true
+				return run()
+			},
+			func(context.Context) *Promise {
+				return Bool(false)
+			},
+		},
+		delimiter: f,
+	}
+	if f.base == nil {
+		f.base = f.box
+	}
+	return f.box
+}
+
+// leaveReset leaves the innermost delimiter since the execution reached the end of it.
+func leaveReset(env *Env) *Promise {
+	return &Promise{delimited: func(s promiseStack) *Promise {
+		f := s.innermost()
+		if f == nil {
+			return Error(errors.New("engine: no delimiter to leave"))
+		}
+		return leave(f, nil, Integer(0), env)
+	}}
+}
+
+// leave leaves f and the delimiters inner inside it and continues the execution after f.
+func leave(f *resetFrame, inner []*resetFrame, cont Term, env *Env) *Promise {
+	return detect(f.box, func(det bool) *Promise {
+		f.active = false
+		for _, g := range inner {
+			g.active = false
+		}
+		if det {
+			// Since there's no way to redo, we leave the frame for good.
+			return Cut(f.base, func(context.Context) *Promise {
+				return f.exit(cont, env)
+			})
+		}
+		return Delay(func(context.Context) *Promise {
+			return f.exit(cont, env)
+		}, func(context.Context) *Promise {
+			// Backtracking gets the execution back inside the frames.
+			f.active = true
+			for _, g := range inner {
+				g.active = true
+			}
+			return Bool(false)
+		})
+	})
+}
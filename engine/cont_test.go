@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_Reset(t *testing.T) {
+	var state State
+	state.Register0("true", func(k func(*Env) *Promise, env *Env) *Promise {
+		return k(env)
+	})
+	state.Register1("throw", Throw)
+	state.Register1("shift", state.Shift)
+
+	reset := func(goal, ball, cont Term) (bool, bool, *Env, error) {
+		var (
+			det    bool
+			result *Env
+			p      *Promise
+		)
+		p = Delay(func(context.Context) *Promise {
+			return state.Reset(goal, ball, cont, func(env *Env) *Promise {
+				return detect(p, func(d bool) *Promise {
+					det, result = d, env
+					return Bool(true)
+				})
+			}, nil)
+		})
+		ok, err := p.Force(context.Background())
+		return ok, det, result, err
+	}
+
+	t.Run("exit", func(t *testing.T) {
+		cont := NewVariable()
+		ok, det, env, err := reset(Atom("true"), NewVariable(), cont)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, det)
+		assert.Equal(t, Integer(0), env.Resolve(cont))
+	})
+
+	t.Run("shift", func(t *testing.T) {
+		ball, cont := NewVariable(), NewVariable()
+		ok, det, env, err := reset(&Compound{Functor: "shift", Args: []Term{Atom("a")}}, ball, cont)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, det)
+		assert.Equal(t, Atom("a"), env.Resolve(ball))
+		c, ok := env.Resolve(cont).(*Compound)
+		assert.True(t, ok)
+		assert.Equal(t, Atom("call_continuation"), c.Functor)
+		assert.IsType(t, &Continuation{}, c.Args[0])
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		_, _, _, err := reset(&Compound{Functor: "throw", Args: []Term{Atom("e")}}, NewVariable(), NewVariable())
+		var e *Exception
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, Atom("e"), e.Term)
+	})
+}
+
+func TestState_Shift(t *testing.T) {
+	var state State
+	_, err := state.Shift(Atom("a"), Success, nil).Force(context.Background())
+	assert.Equal(t, ExistenceError("reset", Atom("a")), err)
+}
+
+func TestState_CallContinuation(t *testing.T) {
+	var state State
+
+	t.Run("variable", func(t *testing.T) {
+		_, err := state.CallContinuation(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("not a continuation", func(t *testing.T) {
+		_, err := state.CallContinuation(Atom("foo"), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeError("continuation", Atom("foo")), err)
+	})
+}
+
+func TestContinuation_Unify(t *testing.T) {
+	c, d := &Continuation{}, &Continuation{}
+	x := NewVariable()
+
+	_, ok := c.Unify(c, false, nil)
+	assert.True(t, ok)
+	_, ok = c.Unify(d, false, nil)
+	assert.False(t, ok)
+	env, ok := c.Unify(x, false, nil)
+	assert.True(t, ok)
+	assert.Equal(t, c, env.Resolve(x))
+	_, ok = c.Unify(Atom("foo"), false, nil)
+	assert.False(t, ok)
+}
+
+func TestContinuation_Compare(t *testing.T) {
+	c, d := &Continuation{}, &Continuation{}
+	assert.Equal(t, int64(0), c.Compare(c, nil))
+	assert.Equal(t, int64(1), c.Compare(d, nil))
+	assert.Equal(t, int64(1), c.Compare(Atom("foo"), nil))
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type coverage struct {
+	enabled bool
+	counts  map[uint64]*clauseCounts
+}
+
+type clauseCounts struct {
+	entered, exited int64
+}
+
+// enter records an entry to the clause and returns the continuation which records an exit from the clause.
+func (c *coverage) enter(id uint64, k func(*Env) *Promise) func(*Env) *Promise {
+	n, ok := c.counts[id]
+	if !ok {
+		n = &clauseCounts{}
+		c.counts[id] = n
+	}
+	n.entered++
+	return func(env *Env) *Promise {
+		if c.enabled {
+			n.exited++
+		}
+		return k(env)
+	}
+}
+
+// StartCoverage starts recording which user-defined clauses are entered and exited. The records so far are discarded.
+func (vm *VM) StartCoverage() {
+	vm.coverage = coverage{
+		enabled: true,
+		counts:  map[uint64]*clauseCounts{},
+	}
+}
+
+// StopCoverage stops recording. The records are kept until the next StartCoverage.
+func (vm *VM) StopCoverage() {
+	vm.coverage.enabled = false
+}
+
+// Coverage returns the records of user-defined clauses sorted by their positions.
+func (vm *VM) Coverage() *Coverage {
+	pis := make([]ProcedureIndicator, 0, len(vm.procedures))
+	for pi := range vm.procedures {
+		pis = append(pis, pi)
+	}
+	sort.Slice(pis, func(i, j int) bool {
+		return pis[i].String() < pis[j].String()
+	})
+
+	var c Coverage
+	for _, pi := range pis {
+		var cs clauses
+		switch p := vm.procedures[pi].(type) {
+		case clauses:
+			cs = p
+		case static:
+			cs = p.clauses
+		default:
+			continue
+		}
+		for _, cl := range cs {
+			cc := ClauseCoverage{
+				PI:     cl.pi,
+				Clause: cl.raw,
+				Pos:    cl.pos,
+			}
+			if n, ok := vm.coverage.counts[cl.id]; ok {
+				cc.Entered, cc.Exited = n.entered, n.exited
+			}
+			c.Clauses = append(c.Clauses, cc)
+		}
+	}
+	sort.SliceStable(c.Clauses, func(i, j int) bool {
+		pi, pj := c.Clauses[i].Pos, c.Clauses[j].Pos
+		if pi.File != pj.File {
+			return pi.File < pj.File
+		}
+		return pi.Line < pj.Line
+	})
+	return &c
+}
+
+// Coverage is the records of which clauses were entered and exited.
+type Coverage struct {
+	Clauses []ClauseCoverage
+}
+
+// ClauseCoverage is the record of a clause.
+// A clause with disjunctions in its body is recorded as separate clauses for each alternative.
+type ClauseCoverage struct {
+	PI     ProcedureIndicator
+	Clause Term
+	Pos    SourcePos
+
+	// Entered is the number of times the clause was tried.
+	Entered int64
+	// Exited is the number of times the clause succeeded.
+	Exited int64
+}
+
+// WriteLCOV writes the records in LCOV format. The line of a clause is covered if the clause was entered.
+// Whether the clause succeeded is reported as a branch. Clauses without source files are omitted.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	var sb strings.Builder
+	var (
+		file          string
+		lines         []int
+		counts        map[int]int64
+		blocks        map[int]int
+		branches, hit int
+	)
+	flush := func() {
+		if file == "" {
+			return
+		}
+		var lh int
+		for _, l := range lines {
+			_, _ = fmt.Fprintf(&sb, "DA:%d,%d\n", l, counts[l])
+			if counts[l] > 0 {
+				lh++
+			}
+		}
+		_, _ = fmt.Fprintf(&sb, "BRF:%d\nBRH:%d\n", branches, hit)
+		_, _ = fmt.Fprintf(&sb, "LF:%d\nLH:%d\nend_of_record\n", len(lines), lh)
+	}
+	for _, cl := range c.Clauses {
+		if cl.Pos.File == "" {
+			continue
+		}
+		if cl.Pos.File != file {
+			flush()
+			file, lines, counts, blocks, branches, hit = cl.Pos.File, nil, map[int]int64{}, map[int]int{}, 0, 0
+			_, _ = fmt.Fprintf(&sb, "TN:\nSF:%s\n", file)
+		}
+		l := cl.Pos.Line
+		if _, ok := counts[l]; !ok {
+			lines = append(lines, l)
+		}
+		counts[l] += cl.Entered
+
+		taken := "-"
+		if cl.Entered > 0 {
+			taken = fmt.Sprintf("%d", cl.Exited)
+		}
+		_, _ = fmt.Fprintf(&sb, "BRDA:%d,%d,0,%s\n", l, blocks[l], taken)
+		blocks[l]++
+		branches++
+		if cl.Exited > 0 {
+			hit++
+		}
+	}
+	flush()
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_Coverage(t *testing.T) {
+	newState := func(t *testing.T) *State {
+		var state State
+		for i, c := range []Term{
+			&Compound{Functor: ":-", Args: []Term{
+				&Compound{Functor: "foo", Args: []Term{testVar("X")}},
+				&Compound{Functor: "bar", Args: []Term{testVar("X")}},
+			}},
+			&Compound{Functor: "bar", Args: []Term{Atom("a")}},
+			&Compound{Functor: "bar", Args: []Term{Atom("b")}},
+			&Compound{Functor: "baz", Args: []Term{Atom("c")}},
+		} {
+			assert.NoError(t, state.AssertAt(c, SourcePos{File: "foo.pl", Line: i + 1}, nil))
+		}
+		return &state
+	}
+
+	foo := ProcedureIndicator{Name: "foo", Arity: 1}
+	bar := ProcedureIndicator{Name: "bar", Arity: 1}
+	baz := ProcedureIndicator{Name: "baz", Arity: 1}
+
+	t.Run("covered", func(t *testing.T) {
+		state := newState(t)
+		state.StartCoverage()
+		ok, err := state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		state.StopCoverage()
+
+		ok, err = state.Call(&Compound{Functor: "baz", Args: []Term{Atom("c")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		c := state.Coverage()
+		assert.Equal(t, &Coverage{
+			Clauses: []ClauseCoverage{
+				{PI: foo, Clause: &Compound{Functor: ":-", Args: []Term{&Compound{Functor: "foo", Args: []Term{testVar("X")}}, &Compound{Functor: "bar", Args: []Term{testVar("X")}}}}, Pos: SourcePos{File: "foo.pl", Line: 1}, Entered: 1, Exited: 1},
+				{PI: bar, Clause: &Compound{Functor: "bar", Args: []Term{Atom("a")}}, Pos: SourcePos{File: "foo.pl", Line: 2}},
+				{PI: bar, Clause: &Compound{Functor: "bar", Args: []Term{Atom("b")}}, Pos: SourcePos{File: "foo.pl", Line: 3}, Entered: 1, Exited: 1},
+				{PI: baz, Clause: &Compound{Functor: "baz", Args: []Term{Atom("c")}}, Pos: SourcePos{File: "foo.pl", Line: 4}},
+			},
+		}, c)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		state := newState(t)
+		state.StartCoverage()
+		ok, err := state.Call(&Compound{Functor: "foo", Args: []Term{NewVariable()}}, Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		c := state.Coverage()
+		assert.Len(t, c.Clauses, 4)
+		assert.Equal(t, []int64{1, 2, 1, 0}, []int64{c.Clauses[0].Entered, c.Clauses[0].Exited, c.Clauses[1].Entered, c.Clauses[3].Entered})
+	})
+}
+
+func TestCoverage_WriteLCOV(t *testing.T) {
+	c := Coverage{
+		Clauses: []ClauseCoverage{
+			{Pos: SourcePos{File: "bar.pl", Line: 1}, Entered: 2, Exited: 1},
+			{Pos: SourcePos{File: "foo.pl", Line: 1}, Entered: 1, Exited: 0},
+			{Pos: SourcePos{File: "foo.pl", Line: 1}, Entered: 1, Exited: 1},
+			{Pos: SourcePos{File: "foo.pl", Line: 3}},
+			{Pos: SourcePos{Line: 3}, Entered: 1, Exited: 1},
+		},
+	}
+
+	var sb strings.Builder
+	assert.NoError(t, c.WriteLCOV(&sb))
+	assert.Equal(t, `TN:
+SF:bar.pl
+BRDA:1,0,0,1
+DA:1,2
+BRF:1
+BRH:1
+LF:1
+LH:1
+end_of_record
+TN:
+SF:foo.pl
+BRDA:1,0,0,0
+BRDA:1,1,0,1
+BRDA:3,0,0,-
+DA:1,2
+DA:3,0
+BRF:3
+BRH:1
+LF:2
+LH:1
+end_of_record
+`, sb.String())
+}
@@ -0,0 +1,324 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DebugAction is what the debugger does after it shows a leashed port.
+type DebugAction int8
+
+// Debug actions.
+const (
+	// DebugCreep proceeds to the next port.
+	DebugCreep DebugAction = iota
+	// DebugSkip proceeds without showing the ports inside the goal.
+	DebugSkip
+	// DebugLeap proceeds without showing ports until it reaches a spy point.
+	DebugLeap
+	// DebugFail makes the goal fail.
+	DebugFail
+	// DebugRetry restarts the goal from its call port.
+	DebugRetry
+	// DebugAbort aborts the execution.
+	DebugAbort
+)
+
+// ErrAborted is an error for an execution aborted by the debugger.
+var ErrAborted = errors.New("execution aborted")
+
+// DebugFrame is a goal at a port shown by the debugger.
+type DebugFrame struct {
+	Event
+
+	// Leashed is true if the debugger waits for the action.
+	// Otherwise, the action returned from OnDebug is ignored.
+	Leashed bool
+}
+
+type portSet uint8
+
+func (s portSet) has(p Port) bool {
+	return s&(1<<p) != 0
+}
+
+type debugger struct {
+	enabled   bool // debug mode.
+	tracing   bool
+	spyPoints map[ProcedureIndicator]struct{}
+	unleashed portSet
+	skip      int64 // the invocation number of the goal we skip through.
+}
+
+// debuggable checks if the debugger shows the procedure. Control constructs and internal procedures are transparent.
+func debuggable(pi ProcedureIndicator) bool {
+	switch pi.Name {
+	case ",", ";", "->", "call":
+		return false
+	default:
+		return !strings.HasPrefix(string(pi.Name), "$")
+	}
+}
+
+// debugPort shows the port if needed and returns the action to take.
+func (vm *VM) debugPort(e *Event) DebugAction {
+	d := &vm.debugger
+	if !d.enabled || !debuggable(e.PI) {
+		return DebugCreep
+	}
+
+	f := DebugFrame{Event: *e}
+	if d.skip != 0 {
+		if d.skip != f.Invocation || f.Port == PortCall || f.Port == PortRedo {
+			return DebugCreep
+		}
+		d.skip = 0
+	}
+
+	if _, ok := d.spyPoints[f.PI]; !ok && !d.tracing {
+		return DebugCreep
+	}
+
+	if vm.OnDebug == nil {
+		return DebugCreep
+	}
+
+	f.Leashed = !d.unleashed.has(f.Port)
+	a := vm.OnDebug(&f)
+	if !f.Leashed {
+		return DebugCreep
+	}
+
+	switch a {
+	case DebugCreep:
+		d.tracing = true
+	case DebugSkip:
+		d.tracing = true
+		if f.Port == PortCall || f.Port == PortRedo {
+			d.skip = f.Invocation
+		}
+		return DebugCreep
+	case DebugLeap:
+		d.tracing = false
+		return DebugCreep
+	case DebugRetry:
+		if f.Port == PortCall {
+			return DebugCreep
+		}
+	}
+	return a
+}
+
+// Trace turns on the debug mode and starts tracing.
+func (state *State) Trace(k func(*Env) *Promise, env *Env) *Promise {
+	state.debugger.enabled = true
+	state.debugger.tracing = true
+	return k(env)
+}
+
+// Notrace stops tracing. The debug mode stays on.
+func (state *State) Notrace(k func(*Env) *Promise, env *Env) *Promise {
+	state.debugger.tracing = false
+	return k(env)
+}
+
+// Spy sets spy points on the procedures specified by Name/Arity, Name, or a list of them, and turns on the debug mode.
+func (state *State) Spy(spec Term, k func(*Env) *Promise, env *Env) *Promise {
+	pis, err := state.spySpec(spec, env)
+	if err != nil {
+		return Error(err)
+	}
+	if state.debugger.spyPoints == nil {
+		state.debugger.spyPoints = map[ProcedureIndicator]struct{}{}
+	}
+	for _, pi := range pis {
+		state.debugger.spyPoints[pi] = struct{}{}
+	}
+	state.debugger.enabled = true
+	return k(env)
+}
+
+// Nospy removes spy points on the procedures specified by Name/Arity, Name, or a list of them.
+func (state *State) Nospy(spec Term, k func(*Env) *Promise, env *Env) *Promise {
+	pis, err := state.spySpec(spec, env)
+	if err != nil {
+		return Error(err)
+	}
+	for _, pi := range pis {
+		delete(state.debugger.spyPoints, pi)
+	}
+	return k(env)
+}
+
+func (state *State) spySpec(spec Term, env *Env) ([]ProcedureIndicator, error) {
+	switch s := env.Resolve(spec).(type) {
+	case Variable:
+		return nil, ErrInstantiation
+	case Atom:
+		if s == "[]" {
+			return nil, nil
+		}
+		var pis []ProcedureIndicator
+		for pi := range state.procedures {
+			if pi.Name == s {
+				pis = append(pis, pi)
+			}
+		}
+		if len(pis) == 0 {
+			return nil, existenceErrorProcedure(s)
+		}
+		return pis, nil
+	case *Compound:
+		switch {
+		case s.Functor == "." && len(s.Args) == 2:
+			var pis []ProcedureIndicator
+			iter := ListIterator{List: s, Env: env}
+			for iter.Next() {
+				ps, err := state.spySpec(iter.Current(), env)
+				if err != nil {
+					return nil, err
+				}
+				pis = append(pis, ps...)
+			}
+			return pis, iter.Err()
+		case s.Functor == "/" && len(s.Args) == 2:
+			name, arity := env.Resolve(s.Args[0]), env.Resolve(s.Args[1])
+			switch name.(type) {
+			case Variable:
+				return nil, ErrInstantiation
+			case Atom:
+				break
+			default:
+				return nil, TypeErrorAtom(name)
+			}
+			switch arity.(type) {
+			case Variable:
+				return nil, ErrInstantiation
+			case Integer:
+				break
+			default:
+				return nil, TypeErrorInteger(arity)
+			}
+			return []ProcedureIndicator{{Name: name.(Atom), Arity: arity.(Integer)}}, nil
+		default:
+			return nil, TypeErrorPredicateIndicator(s)
+		}
+	default:
+		return nil, TypeErrorPredicateIndicator(s)
+	}
+}
+
+// Leash sets the ports where the debugger waits for an action.
+// Ports is either a port name, all, full, tight, half, loose, none, +Ports, -Ports, or a list of them.
+// +Ports and -Ports add and remove ports from the leashed ports respectively.
+func (state *State) Leash(ports Term, k func(*Env) *Promise, env *Env) *Promise {
+	leashed, err := leashPorts(ports, ^state.debugger.unleashed&allPorts, env)
+	if err != nil {
+		return Error(err)
+	}
+	state.debugger.unleashed = ^leashed & allPorts
+	return k(env)
+}
+
+const allPorts = portSet(1<<_portLen - 1)
+
+var namedPortSets = map[Atom]portSet{
+	"call":      1 << PortCall,
+	"exit":      1 << PortExit,
+	"redo":      1 << PortRedo,
+	"fail":      1 << PortFail,
+	"exception": 1 << PortException,
+	"all":       allPorts,
+	"full":      allPorts,
+	"tight":     1<<PortCall | 1<<PortRedo | 1<<PortFail | 1<<PortException,
+	"half":      1<<PortCall | 1<<PortRedo,
+	"loose":     1 << PortCall,
+	"none":      0,
+}
+
+func leashPorts(ports Term, leashed portSet, env *Env) (portSet, error) {
+	switch p := env.Resolve(ports).(type) {
+	case Variable:
+		return 0, ErrInstantiation
+	case Atom:
+		if p == "[]" {
+			return 0, nil
+		}
+		s, ok := namedPortSets[p]
+		if !ok {
+			return 0, domainErrorPort(p)
+		}
+		return s, nil
+	case *Compound:
+		switch {
+		case p.Functor == "." && len(p.Args) == 2:
+			var s portSet
+			iter := ListIterator{List: p, Env: env}
+			for iter.Next() {
+				e, err := leashPorts(iter.Current(), s, env)
+				if err != nil {
+					return 0, err
+				}
+				// A port name in a list adds the port while +Ports and -Ports modify the ports so far.
+				if _, ok := env.Resolve(iter.Current()).(Atom); ok {
+					e |= s
+				}
+				s = e
+			}
+			return s, iter.Err()
+		case p.Functor == "+" && len(p.Args) == 1:
+			s, err := leashPorts(p.Args[0], leashed, env)
+			return leashed | s, err
+		case p.Functor == "-" && len(p.Args) == 1:
+			s, err := leashPorts(p.Args[0], leashed, env)
+			return leashed &^ s, err
+		default:
+			return 0, domainErrorPort(p)
+		}
+	default:
+		return 0, domainErrorPort(p)
+	}
+}
+
+// Debugging prints the status of the debugger to the current output.
+func (state *State) Debugging(k func(*Env) *Promise, env *Env) *Promise {
+	var sb strings.Builder
+	if state.debugger.enabled {
+		_, _ = fmt.Fprintln(&sb, "Debug mode is on.")
+	} else {
+		_, _ = fmt.Fprintln(&sb, "Debug mode is off.")
+	}
+
+	pis := make([]string, 0, len(state.debugger.spyPoints))
+	for pi := range state.debugger.spyPoints {
+		pis = append(pis, pi.String())
+	}
+	sort.Strings(pis)
+	if len(pis) == 0 {
+		_, _ = fmt.Fprintln(&sb, "No spy points.")
+	} else {
+		_, _ = fmt.Fprintln(&sb, "Spy points:")
+		for _, pi := range pis {
+			_, _ = fmt.Fprintf(&sb, "\t%s\n", pi)
+		}
+	}
+
+	var ports []string
+	for p := PortCall; p < _portLen; p++ {
+		if !state.debugger.unleashed.has(p) {
+			ports = append(ports, p.String())
+		}
+	}
+	if len(ports) == 0 {
+		_, _ = fmt.Fprintln(&sb, "No leashed ports.")
+	} else {
+		_, _ = fmt.Fprintf(&sb, "Leashed ports: %s.\n", strings.Join(ports, ", "))
+	}
+
+	if _, err := fmt.Fprint(state.output.file, sb.String()); err != nil {
+		return Error(err)
+	}
+	return k(env)
+}
@@ -0,0 +1,341 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDebugState(t *testing.T, actions ...DebugAction) (*State, *[]string) {
+	var state State
+	state.Register1("throw", Throw)
+	for _, c := range []Term{
+		&Compound{Functor: ":-", Args: []Term{
+			&Compound{Functor: "foo", Args: []Term{testVar("X")}},
+			&Compound{Functor: "bar", Args: []Term{testVar("X")}},
+		}},
+		&Compound{Functor: "bar", Args: []Term{Atom("a")}},
+		&Compound{Functor: "bar", Args: []Term{Atom("b")}},
+		&Compound{Functor: ":-", Args: []Term{
+			Atom("baz"),
+			&Compound{Functor: "throw", Args: []Term{Atom("oops")}},
+		}},
+	} {
+		_, err := state.Assertz(c, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+	}
+
+	var log []string
+	state.OnDebug = func(f *DebugFrame) DebugAction {
+		var sb strings.Builder
+		_ = Write(&sb, f.Goal, f.Env)
+		log = append(log, fmt.Sprintf("%s %d %s", f.Port, f.Depth, sb.String()))
+		if !f.Leashed || len(actions) == 0 {
+			return DebugCreep
+		}
+		var a DebugAction
+		a, actions = actions[0], actions[1:]
+		return a
+	}
+	return &state, &log
+}
+
+func TestState_Trace(t *testing.T) {
+	t.Run("creep", func(t *testing.T) {
+		state, log := newDebugState(t)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		x := NewVariable()
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{x}}, Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []string{
+			fmt.Sprintf("call 1 foo(%s)", x),
+			fmt.Sprintf("call 2 bar(%s)", x),
+			"exit 2 bar(a)",
+			"exit 1 foo(a)",
+			"redo 1 foo(a)",
+			"redo 2 bar(a)",
+			"exit 2 bar(b)",
+			"exit 1 foo(b)",
+		}, *log)
+	})
+
+	t.Run("fail port", func(t *testing.T) {
+		state, log := newDebugState(t)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("c")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []string{
+			"call 1 foo(c)",
+			"call 2 bar(c)",
+			"fail 2 bar(c)",
+			"fail 1 foo(c)",
+		}, *log)
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		state, log := newDebugState(t)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = state.Call(Atom("baz"), Success, nil).Force(context.Background())
+		assert.Equal(t, &Exception{Term: Atom("oops")}, err)
+		assert.Equal(t, []string{
+			"call 1 baz",
+			"call 2 throw(oops)",
+			"exception 2 throw(oops)",
+			"exception 1 baz",
+		}, *log)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		state, log := newDebugState(t, DebugSkip)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{
+			"call 1 foo(b)",
+			"exit 1 foo(b)",
+		}, *log)
+	})
+
+	t.Run("leap", func(t *testing.T) {
+		state, log := newDebugState(t, DebugLeap)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{
+			"call 1 foo(b)",
+		}, *log)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		state, log := newDebugState(t, DebugCreep, DebugFail)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []string{
+			"call 1 foo(b)",
+			"call 2 bar(b)",
+			"fail 2 bar(b)",
+			"fail 1 foo(b)",
+		}, *log)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		state, log := newDebugState(t, DebugCreep, DebugCreep, DebugCreep, DebugRetry)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{
+			"call 1 foo(b)",
+			"call 2 bar(b)",
+			"exit 2 bar(b)",
+			"exit 1 foo(b)",
+			"call 1 foo(b)",
+			"call 2 bar(b)",
+			"exit 2 bar(b)",
+			"exit 1 foo(b)",
+		}, *log)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		state, log := newDebugState(t, DebugCreep, DebugAbort)
+		ok, err := state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.Equal(t, ErrAborted, err)
+		assert.False(t, ok)
+		assert.Equal(t, []string{
+			"call 1 foo(b)",
+			"call 2 bar(b)",
+		}, *log)
+	})
+}
+
+func TestState_Notrace(t *testing.T) {
+	state, log := newDebugState(t)
+	ok, err := state.Trace(Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = state.Notrace(Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, state.debugger.enabled)
+
+	ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, *log)
+}
+
+func TestState_Spy(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		state, log := newDebugState(t, DebugLeap, DebugLeap)
+		ok, err := state.Spy(&Compound{Functor: "/", Args: []Term{Atom("bar"), Integer(1)}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, state.debugger.enabled)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{
+			"call 2 bar(b)",
+			"exit 2 bar(b)",
+		}, *log)
+	})
+
+	t.Run("name", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		ok, err := state.Spy(Atom("bar"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, map[ProcedureIndicator]struct{}{
+			{Name: "bar", Arity: 1}: {},
+		}, state.debugger.spyPoints)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		_, err := state.Spy(Atom("qux"), Success, nil).Force(context.Background())
+		assert.Equal(t, existenceErrorProcedure(Atom("qux")), err)
+	})
+
+	t.Run("spec is a variable", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		_, err := state.Spy(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("spec is neither a predicate indicator nor an atom", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		_, err := state.Spy(Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorPredicateIndicator(Integer(0)), err)
+	})
+}
+
+func TestState_Nospy(t *testing.T) {
+	state, log := newDebugState(t)
+	ok, err := state.Spy(List(Atom("foo"), Atom("bar")), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = state.Nospy(List(Atom("foo"), Atom("bar")), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, state.debugger.spyPoints)
+
+	ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, *log)
+}
+
+func TestState_Leash(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var state State
+		for _, c := range []struct {
+			ports   Term
+			leashed []Port
+		}{
+			{ports: Atom("none"), leashed: nil},
+			{ports: Atom("full"), leashed: []Port{PortCall, PortExit, PortRedo, PortFail, PortException}},
+			{ports: Atom("half"), leashed: []Port{PortCall, PortRedo}},
+			{ports: &Compound{Functor: "-", Args: []Term{Atom("redo")}}, leashed: []Port{PortCall}},
+			{ports: &Compound{Functor: "+", Args: []Term{List(Atom("exit"), Atom("fail"))}}, leashed: []Port{PortCall, PortExit, PortFail}},
+			{ports: List(Atom("exception")), leashed: []Port{PortException}},
+		} {
+			ok, err := state.Leash(c.ports, Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			var leashed []Port
+			for p := PortCall; p < _portLen; p++ {
+				if !state.debugger.unleashed.has(p) {
+					leashed = append(leashed, p)
+				}
+			}
+			assert.Equal(t, c.leashed, leashed)
+		}
+	})
+
+	t.Run("unleashed ports are shown but don't wait", func(t *testing.T) {
+		state, log := newDebugState(t, DebugFail)
+		ok, err := state.Leash(Atom("none"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = state.Trace(Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Len(t, *log, 4)
+	})
+
+	t.Run("ports is a variable", func(t *testing.T) {
+		var state State
+		_, err := state.Leash(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("ports is not a port", func(t *testing.T) {
+		var state State
+		_, err := state.Leash(Atom("foo"), Success, nil).Force(context.Background())
+		assert.Equal(t, domainErrorPort(Atom("foo")), err)
+	})
+}
+
+func TestState_Debugging(t *testing.T) {
+	var buf bytes.Buffer
+	state, _ := newDebugState(t)
+	state.SetUserOutput(&buf)
+	ok, err := state.Spy(Atom("bar"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = state.Leash(Atom("half"), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = state.Debugging(Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `Debug mode is on.
+Spy points:
+	bar/1
+Leashed ports: call, redo.
+`, buf.String())
+}
@@ -0,0 +1,264 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Engine is a Prolog engine which computes the answers of a goal lazily on its own promise stack.
+type Engine struct {
+	template Term
+	stack    promiseStack
+	alias    Atom
+
+	answer    Term // the last answer or the term yielded by engine_yield/1.
+	posted    Term // the term posted by engine_post/2 and not fetched yet.
+	running   bool
+	destroyed bool
+}
+
+// Unify unifies the engine with t.
+func (e *Engine) Unify(t Term, occursCheck bool, env *Env) (*Env, bool) {
+	switch t := env.Resolve(t).(type) {
+	case *Engine:
+		return env, e == t
+	case Variable:
+		return t.Unify(e, occursCheck, env)
+	default:
+		return env, false
+	}
+}
+
+// Unparse emits tokens that represent the engine.
+func (e *Engine) Unparse(emit func(Token), _ *Env, _ ...WriteOption) {
+	if e.alias != "" {
+		emit(Token{Kind: TokenIdent, Val: string(e.alias)})
+		return
+	}
+	emit(Token{Kind: TokenIdent, Val: fmt.Sprintf("<engine>(%p)", e)})
+}
+
+// Compare compares the engine to another term.
+func (e *Engine) Compare(t Term, env *Env) int64 {
+	switch t := env.Resolve(t).(type) {
+	case *Engine:
+		if e == t {
+			return 0
+		}
+		return 1
+	default:
+		return 1
+	}
+}
+
+// next resumes the engine until it finds the next answer or yields a term.
+func (e *Engine) next(ctx context.Context, state *State) (bool, error) {
+	if e.running {
+		return false, PermissionError("resume", "engine", e)
+	}
+	e.running = true
+	active := state.activeEngine
+	state.activeEngine = e
+	defer func() {
+		state.activeEngine = active
+		e.running = false
+	}()
+
+	e.answer = nil
+	return e.stack.force(ctx)
+}
+
+// EngineCreate creates an engine which computes the answers of goal. The answers of the engine are copies of template.
+func (state *State) EngineCreate(template, goal, engine Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.EngineCreateWithOptions(template, goal, engine, List(), k, env)
+}
+
+// EngineCreateWithOptions creates an engine with options. The only supported option is alias(A).
+func (state *State) EngineCreateWithOptions(template, goal, engine, options Term, k func(*Env) *Promise, env *Env) *Promise {
+	switch g := env.Resolve(goal).(type) {
+	case Variable:
+		return Error(ErrInstantiation)
+	case Atom, *Compound:
+		break
+	default:
+		return Error(TypeErrorCallable(g))
+	}
+
+	var e Engine
+	iter := ListIterator{List: options, Env: env}
+	for iter.Next() {
+		switch o := env.Resolve(iter.Current()).(type) {
+		case Variable:
+			return Error(ErrInstantiation)
+		case *Compound:
+			if o.Functor != "alias" || len(o.Args) != 1 {
+				return Error(DomainError("engine_option", o))
+			}
+			switch a := env.Resolve(o.Args[0]).(type) {
+			case Variable:
+				return Error(ErrInstantiation)
+			case Atom:
+				if _, ok := state.engines[a]; ok {
+					return Error(PermissionError("create", "engine", a))
+				}
+				e.alias = a
+			default:
+				return Error(TypeErrorAtom(a))
+			}
+		default:
+			return Error(DomainError("engine_option", o))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return Error(err)
+	}
+
+	// The engine works on a copy so that it doesn't share variables with the caller.
+	c := copyTerm(&Compound{Functor: "-", Args: []Term{template, goal}}, nil, env).(*Compound)
+	e.template = c.Args[0]
+	e.stack = promiseStack{Delay(func(context.Context) *Promise {
+		return state.Call(c.Args[1], func(env *Env) *Promise {
+			e.answer = copyTerm(e.template, nil, env)
+			return Bool(true)
+		}, nil)
+	})}
+
+	if e.alias == "" {
+		return Unify(engine, &e, k, env)
+	}
+	if state.engines == nil {
+		state.engines = map[Atom]*Engine{}
+	}
+	state.engines[e.alias] = &e
+	return Unify(engine, e.alias, k, env)
+}
+
+// EngineNext unifies term with the next answer of the engine. It fails if there are no more answers.
+func (state *State) EngineNext(engine, term Term, k func(*Env) *Promise, env *Env) *Promise {
+	e, err := state.engine(engine, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Delay(func(ctx context.Context) *Promise {
+		ok, err := e.next(ctx, state)
+		if err != nil {
+			return Error(err)
+		}
+		if !ok {
+			return Bool(false)
+		}
+		return Unify(term, e.answer, k, env)
+	})
+}
+
+// EngineNextReified is similar to EngineNext but unifies term with the(Answer), no, or throw(Error) instead of
+// failing or raising an exception.
+func (state *State) EngineNextReified(engine, term Term, k func(*Env) *Promise, env *Env) *Promise {
+	e, err := state.engine(engine, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Delay(func(ctx context.Context) *Promise {
+		ok, err := e.next(ctx, state)
+		var result Term
+		switch {
+		case err != nil:
+			var ex *Exception
+			if !errors.As(err, &ex) {
+				return Error(err)
+			}
+			result = &Compound{Functor: "throw", Args: []Term{ex.Term}}
+		case ok:
+			result = &Compound{Functor: "the", Args: []Term{e.answer}}
+		default:
+			result = Atom("no")
+		}
+		return Unify(term, result, k, env)
+	})
+}
+
+// EnginePost posts term to the engine so that the engine can fetch it by engine_fetch/1.
+func (state *State) EnginePost(engine, term Term, k func(*Env) *Promise, env *Env) *Promise {
+	e, err := state.engine(engine, env)
+	if err != nil {
+		return Error(err)
+	}
+	if e.posted != nil {
+		return Error(PermissionError("post_to", "engine", e))
+	}
+	e.posted = copyTerm(term, nil, env)
+	return k(env)
+}
+
+// EnginePostWithReply posts term to the engine and then unifies reply with the next answer of the engine.
+func (state *State) EnginePostWithReply(engine, term, reply Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.EnginePost(engine, term, func(env *Env) *Promise {
+		return state.EngineNext(engine, reply, k, env)
+	}, env)
+}
+
+// EngineYield makes engine_next/2 of the running engine answer term. The engine continues from there when it's
+// asked for the next answer.
+func (state *State) EngineYield(term Term, k func(*Env) *Promise, env *Env) *Promise {
+	e := state.activeEngine
+	if e == nil {
+		return Error(PermissionError("yield", "engine", Atom("main")))
+	}
+	e.answer = copyTerm(term, nil, env)
+	return Delay(func(context.Context) *Promise {
+		return Bool(true)
+	}, func(context.Context) *Promise {
+		return k(env)
+	})
+}
+
+// EngineFetch unifies term with the term posted to the running engine by engine_post/2.
+func (state *State) EngineFetch(term Term, k func(*Env) *Promise, env *Env) *Promise {
+	e := state.activeEngine
+	if e == nil {
+		return Error(PermissionError("fetch", "engine", Atom("main")))
+	}
+	if e.posted == nil {
+		return Error(ExistenceError("term", Atom("delivery")))
+	}
+	t := e.posted
+	e.posted = nil
+	return Unify(term, t, k, env)
+}
+
+// EngineDestroy destroys the engine. The choicepoints left in the engine are discarded.
+func (state *State) EngineDestroy(engine Term, k func(*Env) *Promise, env *Env) *Promise {
+	e, err := state.engine(engine, env)
+	if err != nil {
+		return Error(err)
+	}
+	return Delay(func(ctx context.Context) *Promise {
+		e.stack.truncate(ctx, 0)
+		e.destroyed = true
+		if e.alias != "" {
+			delete(state.engines, e.alias)
+		}
+		return k(env)
+	})
+}
+
+func (state *State) engine(engine Term, env *Env) (*Engine, error) {
+	switch e := env.Resolve(engine).(type) {
+	case Variable:
+		return nil, ErrInstantiation
+	case Atom:
+		v, ok := state.engines[e]
+		if !ok {
+			return nil, ExistenceError("engine", e)
+		}
+		return v, nil
+	case *Engine:
+		if e.destroyed {
+			return nil, ExistenceError("engine", e)
+		}
+		return e, nil
+	default:
+		return nil, TypeError("engine", e)
+	}
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_EngineCreate(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var state State
+		e := NewVariable()
+		ok, err := state.EngineCreate(NewVariable(), Atom("true"), e, func(env *Env) *Promise {
+			assert.IsType(t, &Engine{}, env.Resolve(e))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("alias", func(t *testing.T) {
+		var state State
+		ok, err := state.EngineCreateWithOptions(NewVariable(), Atom("true"), Atom("foo"), List(&Compound{Functor: "alias", Args: []Term{Atom("foo")}}), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Contains(t, state.engines, Atom("foo"))
+
+		_, err = state.EngineCreateWithOptions(NewVariable(), Atom("true"), NewVariable(), List(&Compound{Functor: "alias", Args: []Term{Atom("foo")}}), Success, nil).Force(context.Background())
+		assert.Equal(t, PermissionError("create", "engine", Atom("foo")), err)
+	})
+
+	t.Run("goal is a variable", func(t *testing.T) {
+		var state State
+		_, err := state.EngineCreate(NewVariable(), NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("goal is not callable", func(t *testing.T) {
+		var state State
+		_, err := state.EngineCreate(NewVariable(), Integer(0), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorCallable(Integer(0)), err)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		var state State
+		_, err := state.EngineCreateWithOptions(NewVariable(), Atom("true"), NewVariable(), List(Atom("foo")), Success, nil).Force(context.Background())
+		assert.Equal(t, DomainError("engine_option", Atom("foo")), err)
+	})
+}
+
+func TestState_EngineNext(t *testing.T) {
+	var state State
+	state.Register1("p", func(x Term, k func(*Env) *Promise, env *Env) *Promise {
+		return Delay(func(context.Context) *Promise {
+			return Unify(x, Integer(1), k, env)
+		}, func(context.Context) *Promise {
+			return Unify(x, Integer(2), k, env)
+		})
+	})
+
+	x, e := NewVariable(), NewVariable()
+	var answers []Term
+	ok, err := state.EngineCreate(x, &Compound{Functor: "p", Args: []Term{x}}, e, func(env *Env) *Promise {
+		for i := 0; i < 3; i++ {
+			a := NewVariable()
+			ok, err := state.EngineNext(e, a, func(env *Env) *Promise {
+				answers = append(answers, env.Resolve(a))
+				return Bool(true)
+			}, env).Force(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, i < 2, ok)
+		}
+		return Bool(true)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []Term{Integer(1), Integer(2)}, answers)
+
+	t.Run("variable", func(t *testing.T) {
+		_, err := state.EngineNext(NewVariable(), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("unknown alias", func(t *testing.T) {
+		_, err := state.EngineNext(Atom("foo"), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ExistenceError("engine", Atom("foo")), err)
+	})
+
+	t.Run("not an engine", func(t *testing.T) {
+		_, err := state.EngineNext(Integer(0), NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeError("engine", Integer(0)), err)
+	})
+}
+
+func TestState_EngineYield(t *testing.T) {
+	var state State
+	_, err := state.EngineYield(Atom("a"), Success, nil).Force(context.Background())
+	assert.Equal(t, PermissionError("yield", "engine", Atom("main")), err)
+}
+
+func TestState_EngineFetch(t *testing.T) {
+	var state State
+	_, err := state.EngineFetch(NewVariable(), Success, nil).Force(context.Background())
+	assert.Equal(t, PermissionError("fetch", "engine", Atom("main")), err)
+}
+
+func TestEngine_Unify(t *testing.T) {
+	e, f := &Engine{}, &Engine{}
+	x := NewVariable()
+
+	_, ok := e.Unify(e, false, nil)
+	assert.True(t, ok)
+	_, ok = e.Unify(f, false, nil)
+	assert.False(t, ok)
+	env, ok := e.Unify(x, false, nil)
+	assert.True(t, ok)
+	assert.Equal(t, e, env.Resolve(x))
+	_, ok = e.Unify(Atom("foo"), false, nil)
+	assert.False(t, ok)
+}
+
+func TestEngine_Compare(t *testing.T) {
+	e, f := &Engine{}, &Engine{}
+	assert.Equal(t, int64(0), e.Compare(e, nil))
+	assert.Equal(t, int64(1), e.Compare(f, nil))
+	assert.Equal(t, int64(1), e.Compare(Atom("foo"), nil))
+}
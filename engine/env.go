@@ -1,5 +1,7 @@
 package engine
 
+import "sort"
+
 type color uint8
 
 const (
@@ -13,12 +15,23 @@ type Env struct {
 	color       color
 	left, right *Env
 	binding
+
+	// newest is the newest stamp of the bindings in the subtree.
+	newest Variable
+
+	// Or, a binding on top of parent in a mutable binding store if non-nil.
+	store  *store
+	parent *Env
+	depth  int
 }
 
 type binding struct {
 	variable Variable
 	value    Term
 	// attributes?
+
+	// stamp is the last variable created when the binding was made.
+	stamp Variable
 }
 
 // NewEnv creates an empty environment.
@@ -26,8 +39,55 @@ func NewEnv() *Env {
 	return nil
 }
 
+// NewTrailEnv creates an empty environment backed by a mutable binding store.
+// Instead of copying a path of the tree on every Bind, it updates the store in place and records the change in the trail.
+// Using an environment other than the latest one undoes the changes made after their common ancestor and redoes the
+// bindings of the environment. Thus, it's fast when environments are used in chronological order as in backtracking,
+// and still correct otherwise. Environments of the same store must not be used concurrently.
+func NewTrailEnv() *Env {
+	return &Env{store: &store{bindings: map[Variable]Term{}}}
+}
+
+type store struct {
+	bindings map[Variable]Term
+	trail    []*Env // the environments whose bindings are in bindings, from the oldest to the latest.
+}
+
+// activate makes the store reflect the bindings of e.
+func (e *Env) activate() {
+	s := e.store
+	if len(s.trail) == e.depth && (e.depth == 0 || s.trail[e.depth-1] == e) {
+		return
+	}
+
+	// Finds the common ancestor of e and the latest environment.
+	var redo []*Env
+	a := e
+	for a.depth > len(s.trail) || (a.depth > 0 && s.trail[a.depth-1] != a) {
+		redo = append(redo, a)
+		a = a.parent
+	}
+
+	for len(s.trail) > a.depth {
+		var t *Env
+		t, s.trail = s.trail[len(s.trail)-1], s.trail[:len(s.trail)-1]
+		delete(s.bindings, t.variable)
+	}
+	for i := len(redo) - 1; i >= 0; i-- {
+		t := redo[i]
+		s.bindings[t.variable] = t.value
+		s.trail = append(s.trail, t)
+	}
+}
+
 // Lookup returns a term that the given variable is bound to.
 func (e *Env) Lookup(k Variable) (Term, bool) {
+	if e != nil && e.store != nil {
+		e.activate()
+		t, ok := e.store.bindings[k]
+		return t, ok
+	}
+
 	node := e
 	for {
 		if node == nil {
@@ -46,32 +106,60 @@ func (e *Env) Lookup(k Variable) (Term, bool) {
 
 // Bind adds a new entry to the environment.
 func (e *Env) Bind(k Variable, v Term) *Env {
-	ret := *e.insert(k, v)
+	if e != nil && e.store != nil {
+		e.activate()
+		s := e.store
+		if _, ok := s.bindings[k]; ok {
+			return e
+		}
+		ret := &Env{binding: binding{variable: k, value: v, stamp: lastVariable()}, store: s, parent: e, depth: e.depth + 1}
+		s.bindings[k] = v
+		s.trail = append(s.trail, ret)
+		return ret
+	}
+
+	ret := *e.insert(k, v, lastVariable())
 	ret.color = black
 	return &ret
 }
 
-func (e *Env) insert(k Variable, v Term) *Env {
+func (e *Env) insert(k Variable, v Term, stamp Variable) *Env {
 	if e == nil {
-		return &Env{color: red, binding: binding{variable: k, value: v}}
+		return &Env{color: red, binding: binding{variable: k, value: v, stamp: stamp}, newest: stamp}
 	}
 	switch {
 	case k < e.variable:
 		ret := *e
-		ret.left = e.left.insert(k, v)
+		ret.left = e.left.insert(k, v, stamp)
 		ret.balance()
+		ret.renew()
 		return &ret
 	case k > e.variable:
 		ret := *e
-		ret.right = e.right.insert(k, v)
+		ret.right = e.right.insert(k, v, stamp)
 		ret.balance()
+		ret.renew()
 		return &ret
 	default:
 		return e
 	}
 }
 
+// renew updates the newest stamp of the subtree.
+func (e *Env) renew() {
+	e.newest = e.stamp
+	if e.left != nil && e.left.newest > e.newest {
+		e.newest = e.left.newest
+	}
+	if e.right != nil && e.right.newest > e.newest {
+		e.newest = e.right.newest
+	}
+}
+
 func (e *Env) balance() {
+	if e.color != black {
+		return
+	}
 	var (
 		a, b, c, d *Env
 		x, y, z    binding
@@ -122,17 +210,169 @@ func (e *Env) balance() {
 	default:
 		return
 	}
+	l := &Env{color: black, left: a, right: b, binding: x}
+	l.renew()
+	r := &Env{color: black, left: c, right: d, binding: z}
+	r.renew()
 	*e = Env{
 		color:   red,
-		left:    &Env{color: black, left: a, right: b, binding: x},
-		right:   &Env{color: black, left: c, right: d, binding: z},
+		left:    l,
+		right:   r,
 		binding: y,
 	}
 }
 
+// each calls f with every binding in the environment.
+func (e *Env) each(f func(Variable, Term)) {
+	if e != nil && e.store != nil {
+		for ; e.depth > 0; e = e.parent {
+			f(e.variable, e.value)
+		}
+		return
+	}
+
+	if e == nil {
+		return
+	}
+	e.left.each(f)
+	f(e.variable, e.value)
+	e.right.each(f)
+}
+
+// merge returns an environment with the bindings of other added unless the variables are already bound.
+func (e *Env) merge(other *Env) *Env {
+	ret := e
+	other.each(func(v Variable, t Term) {
+		if _, ok := ret.Lookup(v); !ok {
+			ret = ret.Bind(v, t)
+		}
+	})
+	return ret
+}
+
+// since calls f with the bindings made after the variable last was created.
+// base is the environment when last was created. It returns false if e isn't derived from base.
+func (e *Env) since(base *Env, last Variable, f func(binding)) bool {
+	if e != nil && e.store != nil {
+		for ; e != base; e = e.parent {
+			if e.depth == 0 {
+				return false
+			}
+			f(e.binding)
+		}
+		return true
+	}
+
+	if e == nil || e.newest < last {
+		return true
+	}
+	e.left.since(base, last, f)
+	if e.stamp >= last {
+		f(e.binding)
+	}
+	e.right.since(base, last, f)
+	return true
+}
+
+// forgetLimit is the number of compounds and variables forget looks through before it gives up.
+const forgetLimit = 1024
+
+// forget returns an environment without the bindings of the variables created after last which are reachable neither
+// from ts nor from the other bindings made after last. base is the environment when last was created.
+// old tells if a compound was made before last so that it has none of the variables.
+// If it takes too long to tell the unreachable bindings, it returns e as is.
+func (e *Env) forget(base *Env, last Variable, ts []Term, old func(*Compound) bool) *Env {
+	if e == base {
+		return e
+	}
+
+	var olds, news []binding
+	if !e.since(base, last, func(b binding) {
+		if b.variable > last {
+			news = append(news, b)
+		} else {
+			olds = append(olds, b)
+		}
+	}) || len(news) == 0 {
+		return e
+	}
+	sort.Slice(news, func(i, j int) bool {
+		return news[i].variable < news[j].variable
+	})
+
+	live := make([]bool, len(news))
+	budget := forgetLimit
+	var visit func(Term) bool
+	visit = func(t Term) bool {
+		budget--
+		if budget < 0 {
+			return false
+		}
+		switch t := t.(type) {
+		case Variable:
+			i := sort.Search(len(news), func(i int) bool {
+				return news[i].variable >= t
+			})
+			if i == len(news) || news[i].variable != t || live[i] {
+				return true
+			}
+			live[i] = true
+			return visit(news[i].value)
+		case *Compound:
+			if old(t) {
+				return true
+			}
+			for _, a := range t.Args {
+				if !visit(a) {
+					return false
+				}
+			}
+			return true
+		case Atom, Integer, Float, *Stream:
+			return true
+		default:
+			// It may refer to the variables in a way we don't know, e.g. continuations.
+			return false
+		}
+	}
+	for _, t := range ts {
+		if !visit(t) {
+			return e
+		}
+	}
+	for _, b := range olds {
+		if !visit(b.value) {
+			return e
+		}
+	}
+
+	// Forgetting costs as much as binding the rest again. We do it only if it saves at least as much.
+	dead := 0
+	for _, l := range live {
+		if !l {
+			dead++
+		}
+	}
+	if dead == 0 || dead < len(olds)+len(news)-dead {
+		return e
+	}
+
+	ret := base
+	for _, b := range olds {
+		ret = ret.Bind(b.variable, b.value)
+	}
+	for i, b := range news {
+		if live[i] {
+			ret = ret.Bind(b.variable, b.value)
+		}
+	}
+	return ret
+}
+
 // Resolve follows the variable chain and returns the first non-variable term or the last free variable.
 func (e *Env) Resolve(t Term) Term {
-	var stop []Variable
+	var buf [8]Variable
+	stop := buf[:0]
 	for t != nil {
 		switch v := t.(type) {
 		case Variable:
@@ -1,7 +1,6 @@
 package engine
 
 import (
-	"fmt"
 	"math/rand"
 	"testing"
 
@@ -10,19 +9,22 @@ import (
 
 func TestEnv_Bind(t *testing.T) {
 	var env *Env
+	last := lastVariable()
 	assert.Equal(t, &Env{
 		color: black,
 		binding: binding{
-			variable: "A",
+			variable: testVar("A"),
 			value:    Atom("a"),
+			stamp:    last,
 		},
-	}, env.Bind("A", Atom("a")))
+		newest: last,
+	}, env.Bind(testVar("A"), Atom("a")))
 }
 
 func TestEnv_Lookup(t *testing.T) {
 	vars := make([]Variable, 1000)
 	for i := range vars {
-		vars[i] = Variable(fmt.Sprintf("V%d", i))
+		vars[i] = NewVariable()
 	}
 
 	rand.Shuffle(len(vars), func(i, j int) {
@@ -39,10 +41,167 @@ func TestEnv_Lookup(t *testing.T) {
 	})
 
 	for _, v := range vars {
-		t.Run(string(v), func(t *testing.T) {
+		t.Run(v.String(), func(t *testing.T) {
 			w, ok := env.Lookup(v)
 			assert.True(t, ok)
 			assert.Equal(t, v, w)
 		})
 	}
 }
+
+func TestNewTrailEnv(t *testing.T) {
+	t.Run("bind and lookup", func(t *testing.T) {
+		vars := make([]Variable, 1000)
+		env := NewTrailEnv()
+		for i := range vars {
+			vars[i] = NewVariable()
+			env = env.Bind(vars[i], Integer(i))
+		}
+		for i, v := range vars {
+			v, ok := env.Lookup(v)
+			assert.True(t, ok)
+			assert.Equal(t, Integer(i), v)
+		}
+	})
+
+	t.Run("undo on backtrack", func(t *testing.T) {
+		root := NewTrailEnv()
+		env := root.Bind(testVar("A"), Atom("a"))
+		env = env.Bind(testVar("B"), Atom("b"))
+		assert.Equal(t, Atom("b"), env.Resolve(testVar("B")))
+
+		// Using an older environment undoes the bindings made after it.
+		_, ok := root.Lookup(testVar("A"))
+		assert.False(t, ok)
+
+		env = root.Bind(testVar("A"), Atom("c"))
+		assert.Equal(t, Atom("c"), env.Resolve(testVar("A")))
+		_, ok = env.Lookup(testVar("B"))
+		assert.False(t, ok)
+	})
+
+	t.Run("rebind", func(t *testing.T) {
+		// Both kinds of environments keep the first binding of a variable.
+		for _, env := range []*Env{NewEnv(), NewTrailEnv()} {
+			env = env.Bind(testVar("A"), Atom("a"))
+			env = env.Bind(testVar("A"), Atom("b"))
+			assert.Equal(t, Atom("a"), env.Resolve(testVar("A")))
+		}
+	})
+
+	t.Run("redo", func(t *testing.T) {
+		root := NewTrailEnv()
+		a := root.Bind(testVar("A"), Atom("a"))
+		ab := a.Bind(testVar("B"), Atom("b"))
+		c := root.Bind(testVar("C"), Atom("c"))
+
+		// Using an environment after its bindings were undone redoes them.
+		assert.Equal(t, Atom("b"), ab.Resolve(testVar("B")))
+		assert.Equal(t, Atom("a"), ab.Resolve(testVar("A")))
+		_, ok := ab.Lookup(testVar("C"))
+		assert.False(t, ok)
+
+		assert.Equal(t, Atom("c"), c.Resolve(testVar("C")))
+		_, ok = c.Lookup(testVar("A"))
+		assert.False(t, ok)
+
+		ac := a.Bind(testVar("C"), Atom("d"))
+		assert.Equal(t, Atom("d"), ac.Resolve(testVar("C")))
+		assert.Equal(t, Atom("b"), ab.Resolve(testVar("B")))
+		_, ok = ab.Lookup(testVar("C"))
+		assert.False(t, ok)
+	})
+}
+
+func TestEnv_forget(t *testing.T) {
+	for _, c := range []struct {
+		title string
+		env   func() *Env
+	}{
+		{title: "tree", env: NewEnv},
+		{title: "trail", env: NewTrailEnv},
+	} {
+		t.Run(c.title, func(t *testing.T) {
+			x, o := NewVariable(), NewVariable()
+			base := c.env().Bind(x, Atom("x"))
+			last := NewVariable()
+			a, b, d, e, f := NewVariable(), NewVariable(), NewVariable(), NewVariable(), NewVariable()
+			env := base.Bind(a, Integer(1))
+			env = env.Bind(e, Integer(4))
+			env = env.Bind(f, Integer(5))
+			env = env.Bind(b, Integer(2))
+			env = env.Bind(d, Integer(3))
+			env = env.Bind(o, &Compound{Functor: "f", Args: []Term{d}})
+
+			t.Run("unreachable", func(t *testing.T) {
+				env := env.forget(base, last, []Term{b, Atom("foo")}, func(*Compound) bool { return false })
+				assert.Equal(t, Atom("x"), env.Resolve(x))
+				for _, v := range []Variable{a, e, f} {
+					_, ok := env.Lookup(v)
+					assert.False(t, ok)
+				}
+				assert.Equal(t, Integer(2), env.Resolve(b))
+				assert.Equal(t, Integer(3), env.Resolve(d)) // o was bound after last.
+				assert.Equal(t, &Compound{Functor: "f", Args: []Term{d}}, env.Resolve(o))
+			})
+
+			t.Run("unknown term", func(t *testing.T) {
+				assert.Equal(t, env, env.forget(base, last, []Term{&Continuation{}}, func(*Compound) bool { return false }))
+			})
+		})
+	}
+}
+
+func BenchmarkEnv_Bind(b *testing.B) {
+	vars := make([]Variable, 1000)
+	for i := range vars {
+		vars[i] = NewVariable()
+	}
+	for _, c := range []struct {
+		title string
+		env   func() *Env
+	}{
+		{title: "tree", env: NewEnv},
+		{title: "trail", env: NewTrailEnv},
+	} {
+		b.Run(c.title, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				env := c.env()
+				for _, v := range vars {
+					env = env.Bind(v, Atom("a"))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEnv_Resolve(b *testing.B) {
+	vars := make([]Variable, 1000)
+	for i := range vars {
+		vars[i] = NewVariable()
+	}
+	for _, c := range []struct {
+		title string
+		env   func() *Env
+	}{
+		{title: "tree", env: NewEnv},
+		{title: "trail", env: NewTrailEnv},
+	} {
+		b.Run(c.title, func(b *testing.B) {
+			env := c.env()
+			for i, v := range vars {
+				if i == len(vars)-1 {
+					env = env.Bind(v, Atom("a"))
+					break
+				}
+				env = env.Bind(v, vars[i+1])
+			}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for _, v := range vars[len(vars)-10:] {
+					_ = env.Resolve(v)
+				}
+			}
+		})
+	}
+}
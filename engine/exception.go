@@ -180,6 +180,10 @@ func domainErrorOrder(culprit Term) *Exception {
 	return DomainError("order", culprit)
 }
 
+func domainErrorPort(culprit Term) *Exception {
+	return DomainError("port", culprit)
+}
+
 // DomainError creates a new domain error exception.
 func DomainError(validDomain Atom, culprit Term) *Exception {
 	return &Exception{
@@ -276,7 +280,8 @@ func PermissionError(operation, permissionType Atom, culprit Term) *Exception {
 	}
 }
 
-func representationError(limit Atom) *Exception {
+// RepresentationError creates a new representation error exception.
+func RepresentationError(limit Atom) *Exception {
 	return &Exception{
 		Term: &Compound{
 			Functor: "error",
@@ -38,5 +38,5 @@ func TestFloat_Compare(t *testing.T) {
 	assert.Equal(t, int64(-1), Float(0).Compare(Float(1), nil))
 	assert.Equal(t, int64(0), Float(0).Compare(Float(0), nil))
 	assert.Equal(t, int64(1), Float(1).Compare(Float(0), nil))
-	assert.Equal(t, int64(1), Float(1).Compare(Variable("X"), nil))
+	assert.Equal(t, int64(1), Float(1).Compare(testVar("X"), nil))
 }
@@ -0,0 +1,145 @@
+package engine
+
+import "context"
+
+// RegisterFunction registers an evaluable functor name/arity which evaluates to the result of fn.
+func (state *State) RegisterFunction(name string, arity int, fn func(args ...Number) (Number, error)) {
+	if state.functions.Unary == nil {
+		state.functions = NewEvaluableFunctors()
+	}
+	state.functions.Function[ProcedureIndicator{Name: Atom(name), Arity: Integer(arity)}] = fn
+}
+
+// evaluableFunctors returns the evaluable functors of the state, or DefaultEvaluableFunctors if the state has none of
+// its own. It never modifies the state since it's called during executions which may run concurrently.
+func (state *State) evaluableFunctors() EvaluableFunctors {
+	if state.functions.Unary == nil {
+		return DefaultEvaluableFunctors
+	}
+	return state.functions
+}
+
+// ArithmeticFunction declares the procedures indicated by pi as evaluable functors. An evaluable functor Name/Arity
+// evaluates to R by calling the predicate Name/Arity+1 with the evaluated arguments followed by R.
+func (state *State) ArithmeticFunction(pi Term, k func(*Env) *Promise, env *Env) *Promise {
+	if err := Each(pi, func(elem Term) error {
+		key, err := NewProcedureIndicator(elem, env)
+		if err != nil {
+			return err
+		}
+		if state.arithmeticFunctions == nil {
+			state.arithmeticFunctions = map[ProcedureIndicator]struct{}{}
+		}
+		state.arithmeticFunctions[key] = struct{}{}
+		return nil
+	}, env); err != nil {
+		return Error(err)
+	}
+	return k(env)
+}
+
+// Is evaluates expression and unifies the result with result.
+func (state *State) Is(result, expression Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand(expression, func(expression Term, env *Env) *Promise {
+		return state.evaluableFunctors().Is(result, expression, k, env)
+	}, env)
+}
+
+// Equal succeeds iff e1 equals to e2.
+func (state *State) Equal(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand2(e1, e2, state.evaluableFunctors().Equal, k, env)
+}
+
+// NotEqual succeeds iff e1 doesn't equal to e2.
+func (state *State) NotEqual(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand2(e1, e2, state.evaluableFunctors().NotEqual, k, env)
+}
+
+// LessThan succeeds iff e1 is less than e2.
+func (state *State) LessThan(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand2(e1, e2, state.evaluableFunctors().LessThan, k, env)
+}
+
+// GreaterThan succeeds iff e1 is greater than e2.
+func (state *State) GreaterThan(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand2(e1, e2, state.evaluableFunctors().GreaterThan, k, env)
+}
+
+// LessThanOrEqual succeeds iff e1 is less than or equal to e2.
+func (state *State) LessThanOrEqual(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand2(e1, e2, state.evaluableFunctors().LessThanOrEqual, k, env)
+}
+
+// GreaterThanOrEqual succeeds iff e1 is greater than or equal to e2.
+func (state *State) GreaterThanOrEqual(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand2(e1, e2, state.evaluableFunctors().GreaterThanOrEqual, k, env)
+}
+
+// expand2 expands both e1 and e2 and then compares them with cmp.
+func (state *State) expand2(e1, e2 Term, cmp func(e1, e2 Term, k func(*Env) *Promise, env *Env) *Promise, k func(*Env) *Promise, env *Env) *Promise {
+	return state.expand(e1, func(e1 Term, env *Env) *Promise {
+		return state.expand(e2, func(e2 Term, env *Env) *Promise {
+			return cmp(e1, e2, k, env)
+		}, env)
+	}, env)
+}
+
+// expand replaces the evaluable functors declared by arithmetic_function/1 in expression with their results.
+func (state *State) expand(expression Term, k func(Term, *Env) *Promise, env *Env) *Promise {
+	if len(state.arithmeticFunctions) == 0 {
+		return k(expression, env)
+	}
+
+	switch t := env.Resolve(expression).(type) {
+	case Atom:
+		pi := ProcedureIndicator{Name: t, Arity: 0}
+		if _, ok := state.arithmeticFunctions[pi]; !ok {
+			return k(t, env)
+		}
+		return state.callFunction(pi, nil, k, env)
+	case *Compound:
+		return state.expandArgs(t.Args, nil, func(args []Term, env *Env) *Promise {
+			pi := ProcedureIndicator{Name: t.Functor, Arity: Integer(len(t.Args))}
+			if _, ok := state.arithmeticFunctions[pi]; !ok {
+				return k(&Compound{Functor: t.Functor, Args: args}, env)
+			}
+			return state.callFunction(pi, args, k, env)
+		}, env)
+	default:
+		return k(t, env)
+	}
+}
+
+// expandArgs expands args one by one and appends them to expanded.
+func (state *State) expandArgs(args, expanded []Term, k func([]Term, *Env) *Promise, env *Env) *Promise {
+	if len(args) == 0 {
+		return k(expanded, env)
+	}
+	return state.expand(args[0], func(arg Term, env *Env) *Promise {
+		return state.expandArgs(args[1:], append(expanded[:len(expanded):len(expanded)], arg), k, env)
+	}, env)
+}
+
+// callFunction evaluates args and calls the predicate of the evaluable functor pi once to compute the result.
+func (state *State) callFunction(pi ProcedureIndicator, args []Term, k func(Term, *Env) *Promise, env *Env) *Promise {
+	goal := make([]Term, len(args)+1)
+	for i, a := range args {
+		x, err := state.evaluableFunctors().eval(a, env)
+		if err != nil {
+			return Error(err)
+		}
+		goal[i] = x
+	}
+	r := NewVariable()
+	goal[len(args)] = r
+
+	var p *Promise
+	p = Delay(func(context.Context) *Promise {
+		return state.Call(&Compound{Functor: pi.Name, Args: goal}, func(env *Env) *Promise {
+			return Cut(p, func(context.Context) *Promise {
+				return k(env.Resolve(r), env)
+			})
+		}, env)
+	})
+	return p
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_RegisterFunction(t *testing.T) {
+	var state State
+	state.RegisterFunction("clamp", 3, func(args ...Number) (Number, error) {
+		x, lo, hi := args[0].(Integer), args[1].(Integer), args[2].(Integer)
+		switch {
+		case x < lo:
+			return lo, nil
+		case x > hi:
+			return hi, nil
+		default:
+			return x, nil
+		}
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := state.Is(x, &Compound{Functor: "clamp", Args: []Term{
+			&Compound{Functor: "+", Args: []Term{Integer(5), Integer(5)}},
+			Integer(0),
+			Integer(3),
+		}}, func(env *Env) *Promise {
+			assert.Equal(t, Integer(3), env.Resolve(x))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		ok, err := state.Equal(&Compound{Functor: "+", Args: []Term{Integer(1), Integer(2)}}, Integer(3), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("another state", func(t *testing.T) {
+		var state State
+		_, err := state.Is(NewVariable(), &Compound{Functor: "clamp", Args: []Term{Integer(1), Integer(0), Integer(3)}}, Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorEvaluable(&Compound{Functor: "/", Args: []Term{Atom("clamp"), Integer(3)}}), err)
+	})
+}
+
+func TestState_ArithmeticFunction(t *testing.T) {
+	var state State
+	state.Register2("double", func(x, r Term, k func(*Env) *Promise, env *Env) *Promise {
+		return Delay(func(context.Context) *Promise {
+			return Unify(r, Integer(2)*env.Resolve(x).(Integer), k, env)
+		}, func(context.Context) *Promise {
+			return Unify(r, Integer(0), k, env)
+		})
+	})
+	state.Register1("answer", func(r Term, k func(*Env) *Promise, env *Env) *Promise {
+		return Unify(r, Integer(42), k, env)
+	})
+
+	ok, err := state.ArithmeticFunction(List(
+		&Compound{Functor: "/", Args: []Term{Atom("double"), Integer(1)}},
+		&Compound{Functor: "/", Args: []Term{Atom("answer"), Integer(0)}},
+	), Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("ok", func(t *testing.T) {
+		x := NewVariable()
+		var results []Term
+		ok, err := state.Is(x, &Compound{Functor: "+", Args: []Term{
+			&Compound{Functor: "double", Args: []Term{&Compound{Functor: "+", Args: []Term{Integer(1), Integer(2)}}}},
+			Atom("answer"),
+		}}, func(env *Env) *Promise {
+			results = append(results, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Term{Integer(48)}, results)
+	})
+
+	t.Run("comparison", func(t *testing.T) {
+		ok, err := state.LessThan(Atom("answer"), &Compound{Functor: "double", Args: []Term{Integer(22)}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("argument is not evaluable", func(t *testing.T) {
+		_, err := state.Is(NewVariable(), &Compound{Functor: "double", Args: []Term{Atom("foo")}}, Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorEvaluable(&Compound{Functor: "/", Args: []Term{Atom("foo"), Integer(0)}}), err)
+	})
+
+	t.Run("pi is a variable", func(t *testing.T) {
+		_, err := state.ArithmeticFunction(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+}
+
+func TestState_Is(t *testing.T) {
+	t.Run("concurrent", func(t *testing.T) {
+		var state State
+		done := make(chan struct{})
+		for n := 0; n < 4; n++ {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				ok, err := state.Is(Integer(3), &Compound{Functor: "+", Args: []Term{Integer(1), Integer(2)}}, Success, nil).Force(context.Background())
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			}()
+		}
+		for n := 0; n < 4; n++ {
+			<-done
+		}
+	})
+}
@@ -27,20 +27,20 @@ func TestInteger_Unify(t *testing.T) {
 
 	t.Run("variable", func(t *testing.T) {
 		t.Run("free", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env, ok := unit.Unify(v, false, nil)
 			assert.True(t, ok)
 			assert.Equal(t, unit, env.Resolve(v))
 		})
 		t.Run("bound to the same value", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env := NewEnv().
 				Bind(v, unit)
 			_, ok := unit.Unify(v, false, env)
 			assert.True(t, ok)
 		})
 		t.Run("bound to a different value", func(t *testing.T) {
-			v := Variable("X")
+			v := testVar("X")
 			env := NewEnv().
 				Bind(v, Integer(0))
 			_, ok := unit.Unify(v, false, env)
@@ -89,5 +89,5 @@ func TestInteger_Compare(t *testing.T) {
 	assert.Equal(t, int64(0), Integer(0).Compare(Integer(0), nil))
 	assert.Equal(t, int64(1), Integer(1).Compare(Integer(0), nil))
 	assert.Equal(t, int64(1), Integer(0).Compare(Float(0), nil))
-	assert.Equal(t, int64(1), Integer(0).Compare(Variable("X"), nil))
+	assert.Equal(t, int64(1), Integer(0).Compare(testVar("X"), nil))
 }
@@ -21,7 +21,7 @@ func TestListIterator_Next(t *testing.T) {
 
 	t.Run("improper list", func(t *testing.T) {
 		t.Run("variable", func(t *testing.T) {
-			iter := ListIterator{List: ListRest(Variable("X"), Atom("a"), Atom("b"))}
+			iter := ListIterator{List: ListRest(testVar("X"), Atom("a"), Atom("b"))}
 			assert.True(t, iter.Next())
 			assert.Equal(t, Atom("a"), iter.Current())
 			assert.True(t, iter.Next())
@@ -116,6 +116,9 @@ type Lexer struct {
 	pos             int
 	width           int
 	reserved        Token
+	line            int // the number of newlines read so far.
+	tokenLine       int // the line where the last token starts.
+	last            rune
 }
 
 // NewLexer create a lexer with an input and char conversions.
@@ -136,6 +139,7 @@ func (l *Lexer) Token() (Token, error) {
 	for unicode.IsSpace(r) {
 		r = l.next()
 	}
+	l.tokenLine = l.line
 
 	if r == utf8.RuneError {
 		return Token{Kind: TokenEOF}, nil
@@ -180,12 +184,20 @@ func (l *Lexer) rawNext() rune {
 	}
 	l.width = w
 	l.pos += l.width
+	l.last = r
+	if r == '\n' {
+		l.line++
+	}
 	return r
 }
 
 func (l *Lexer) backup() {
 	_ = l.input.UnreadRune()
 	l.pos -= l.width
+	if l.last == '\n' {
+		l.line--
+	}
+	l.last = utf8.RuneError
 }
 
 // Token is a smallest meaningful unit of prolog program.
@@ -3,59 +3,69 @@ package engine
 import "math"
 
 // DefaultEvaluableFunctors is a EvaluableFunctors with builtin functions.
-var DefaultEvaluableFunctors = EvaluableFunctors{
-	Constant: map[Atom]Number{
-		`pi`: Float(math.Pi),
-	},
-	Unary: map[Atom]func(Number) (Number, error){
-		`-`:                     Neg,
-		`abs`:                   Abs,
-		`sign`:                  Sign,
-		`float_integer_part`:    FloatIntegerPart,
-		`float_fractional_part`: FloatFractionalPart,
-		`float`:                 AsFloat,
-		`floor`:                 Floor,
-		`truncate`:              Truncate,
-		`round`:                 Round,
-		`ceiling`:               Ceiling,
-
-		`sin`:  Sin,
-		`cos`:  Cos,
-		`atan`: Atan,
-		`exp`:  Exp,
-		`log`:  Log,
-		`sqrt`: Sqrt,
-
-		`\`: BitwiseComplement,
-
-		`+`:    Pos,
-		`asin`: Asin,
-		`acos`: Acos,
-		`tan`:  Tan,
-	},
-	Binary: map[Atom]func(Number, Number) (Number, error){
-		`+`:   Add,
-		`-`:   Sub,
-		`*`:   Mul,
-		`//`:  IntDiv,
-		`/`:   Div,
-		`rem`: Rem,
-		`mod`: Mod,
-
-		`**`: Power,
-
-		`>>`: BitwiseRightShift,
-		`<<`: BitwiseLeftShift,
-		`/\`: BitwiseAnd,
-		`\/`: BitwiseOr,
-
-		`div`:   IntFloorDiv,
-		`max`:   Max,
-		`min`:   Min,
-		`^`:     IntegerPower,
-		`atan2`: Atan2,
-		`xor`:   Xor,
-	},
+// It's used by the states without their own functions, i.e. without State.RegisterFunction.
+//
+// Deprecated: Use NewEvaluableFunctors for a set of your own or State.RegisterFunction to add functions to a state.
+var DefaultEvaluableFunctors = NewEvaluableFunctors()
+
+// NewEvaluableFunctors returns an EvaluableFunctors with builtin functions.
+// Since it returns new sets every time, adding functions to one doesn't affect the others.
+func NewEvaluableFunctors() EvaluableFunctors {
+	return EvaluableFunctors{
+		Constant: map[Atom]Number{
+			`pi`: Float(math.Pi),
+		},
+		Unary: map[Atom]func(Number) (Number, error){
+			`-`:                     Neg,
+			`abs`:                   Abs,
+			`sign`:                  Sign,
+			`float_integer_part`:    FloatIntegerPart,
+			`float_fractional_part`: FloatFractionalPart,
+			`float`:                 AsFloat,
+			`floor`:                 Floor,
+			`truncate`:              Truncate,
+			`round`:                 Round,
+			`ceiling`:               Ceiling,
+
+			`sin`:  Sin,
+			`cos`:  Cos,
+			`atan`: Atan,
+			`exp`:  Exp,
+			`log`:  Log,
+			`sqrt`: Sqrt,
+
+			`\`: BitwiseComplement,
+
+			`+`:    Pos,
+			`asin`: Asin,
+			`acos`: Acos,
+			`tan`:  Tan,
+		},
+		Binary: map[Atom]func(Number, Number) (Number, error){
+			`+`:   Add,
+			`-`:   Sub,
+			`*`:   Mul,
+			`//`:  IntDiv,
+			`/`:   Div,
+			`rem`: Rem,
+			`mod`: Mod,
+
+			`**`: Power,
+
+			`>>`: BitwiseRightShift,
+			`<<`: BitwiseLeftShift,
+			`/\`: BitwiseAnd,
+			`\/`: BitwiseOr,
+
+			`div`:   IntFloorDiv,
+			`max`:   Max,
+			`min`:   Min,
+			`^`:     IntegerPower,
+			`atan2`: Atan2,
+			`xor`:   Xor,
+		},
+		Function: map[ProcedureIndicator]func(...Number) (Number, error){},
+	}
 }
 
 // Number is a prolog number, either Integer or Float.
@@ -64,7 +74,7 @@ type Number interface {
 	number()
 }
 
-// EvaluableFunctors is a set of unary/binary functions.
+// EvaluableFunctors is a set of evaluable functors.
 type EvaluableFunctors struct {
 	// Constant is a set of constants.
 	Constant map[Atom]Number
@@ -74,6 +84,9 @@ type EvaluableFunctors struct {
 
 	// Binary is a set of functions of arity 2.
 	Binary map[Atom]func(x, y Number) (Number, error)
+
+	// Function is a set of functions of any arity. It takes precedence over Constant, Unary, and Binary.
+	Function map[ProcedureIndicator]func(args ...Number) (Number, error)
 }
 
 // Is evaluates expression and unifies the result with result.
@@ -300,6 +313,9 @@ func (e EvaluableFunctors) eval(expression Term, env *Env) (Number, error) {
 	case Variable:
 		return nil, ErrInstantiation
 	case Atom:
+		if f, ok := e.Function[ProcedureIndicator{Name: t, Arity: 0}]; ok {
+			return f()
+		}
 		c, ok := e.Constant[t]
 		if !ok {
 			return nil, TypeErrorEvaluable(&Compound{
@@ -311,6 +327,17 @@ func (e EvaluableFunctors) eval(expression Term, env *Env) (Number, error) {
 	case Number:
 		return t, nil
 	case *Compound:
+		if f, ok := e.Function[ProcedureIndicator{Name: t.Functor, Arity: Integer(len(t.Args))}]; ok {
+			args := make([]Number, len(t.Args))
+			for i, a := range t.Args {
+				x, err := e.eval(a, env)
+				if err != nil {
+					return nil, err
+				}
+				args[i] = x
+			}
+			return f(args...)
+		}
 		switch arity := len(t.Args); arity {
 		case 1:
 			f, ok := e.Unary[t.Functor]
@@ -52,7 +52,7 @@ func TestEvaluableFunctors_Is(t *testing.T) {
 		})
 
 		t.Run("invalid argument", func(t *testing.T) {
-			_, err := efs.Is(Integer(1), Atom("foo").Apply(Variable("X")), Success, nil).Force(context.Background())
+			_, err := efs.Is(Integer(1), Atom("foo").Apply(testVar("X")), Success, nil).Force(context.Background())
 			assert.Error(t, err)
 		})
 	})
@@ -70,9 +70,9 @@ func TestEvaluableFunctors_Is(t *testing.T) {
 		})
 
 		t.Run("invalid argument", func(t *testing.T) {
-			_, err := efs.Is(Integer(1), Atom("foo").Apply(Variable("X"), Integer(0)), Success, nil).Force(context.Background())
+			_, err := efs.Is(Integer(1), Atom("foo").Apply(testVar("X"), Integer(0)), Success, nil).Force(context.Background())
 			assert.Error(t, err)
-			_, err = efs.Is(Integer(1), Atom("foo").Apply(Integer(0), Variable("X")), Success, nil).Force(context.Background())
+			_, err = efs.Is(Integer(1), Atom("foo").Apply(Integer(0), testVar("X")), Success, nil).Force(context.Background())
 			assert.Error(t, err)
 		})
 	})
@@ -82,6 +82,33 @@ func TestEvaluableFunctors_Is(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("function", func(t *testing.T) {
+		efs := EvaluableFunctors{
+			Function: map[ProcedureIndicator]func(...Number) (Number, error){
+				{Name: "foo", Arity: 0}: func(...Number) (Number, error) {
+					return Integer(2), nil
+				},
+				{Name: "foo", Arity: 3}: func(args ...Number) (Number, error) {
+					return args[2], nil
+				},
+			},
+		}
+
+		t.Run("ok", func(t *testing.T) {
+			ok, err := efs.Is(Integer(2), Atom("foo"), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = efs.Is(Integer(3), Atom("foo").Apply(Integer(1), Integer(2), Integer(3)), Success, nil).Force(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+
+		t.Run("invalid argument", func(t *testing.T) {
+			_, err := efs.Is(Integer(3), Atom("foo").Apply(Integer(1), testVar("X"), Integer(3)), Success, nil).Force(context.Background())
+			assert.Error(t, err)
+		})
+	})
+
 	t.Run("other", func(t *testing.T) {
 		_, err := efs.Is(Integer(1), &Stream{}, Success, nil).Force(context.Background())
 		assert.Error(t, err)
@@ -120,12 +147,12 @@ func TestEvaluableFunctors_Equal(t *testing.T) {
 	})
 
 	t.Run("e1 is a variable", func(t *testing.T) {
-		_, err := efs.Equal(Integer(1), Variable("X"), Success, nil).Force(context.Background())
+		_, err := efs.Equal(Integer(1), testVar("X"), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("e2 is a variable", func(t *testing.T) {
-		_, err := efs.Equal(Variable("X"), Integer(1), Success, nil).Force(context.Background())
+		_, err := efs.Equal(testVar("X"), Integer(1), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
@@ -168,12 +195,12 @@ func TestEvaluableFunctors_NotEqual(t *testing.T) {
 	})
 
 	t.Run("e1 is a variable", func(t *testing.T) {
-		_, err := efs.NotEqual(Integer(1), Variable("X"), Success, nil).Force(context.Background())
+		_, err := efs.NotEqual(Integer(1), testVar("X"), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("e2 is a variable", func(t *testing.T) {
-		_, err := efs.NotEqual(Variable("X"), Integer(1), Success, nil).Force(context.Background())
+		_, err := efs.NotEqual(testVar("X"), Integer(1), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
@@ -216,12 +243,12 @@ func TestEvaluableFunctors_LessThan(t *testing.T) {
 	})
 
 	t.Run("e1 is a variable", func(t *testing.T) {
-		_, err := efs.LessThan(Integer(1), Variable("X"), Success, nil).Force(context.Background())
+		_, err := efs.LessThan(Integer(1), testVar("X"), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("e2 is a variable", func(t *testing.T) {
-		_, err := efs.LessThan(Variable("X"), Integer(1), Success, nil).Force(context.Background())
+		_, err := efs.LessThan(testVar("X"), Integer(1), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
@@ -264,12 +291,12 @@ func TestEvaluableFunctors_GreaterThan(t *testing.T) {
 	})
 
 	t.Run("e1 is a variable", func(t *testing.T) {
-		_, err := efs.GreaterThan(Integer(1), Variable("X"), Success, nil).Force(context.Background())
+		_, err := efs.GreaterThan(Integer(1), testVar("X"), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("e2 is a variable", func(t *testing.T) {
-		_, err := efs.GreaterThan(Variable("X"), Integer(1), Success, nil).Force(context.Background())
+		_, err := efs.GreaterThan(testVar("X"), Integer(1), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
@@ -312,12 +339,12 @@ func TestEvaluableFunctors_LessThanOrEqual(t *testing.T) {
 	})
 
 	t.Run("e1 is a variable", func(t *testing.T) {
-		_, err := efs.LessThanOrEqual(Integer(1), Variable("X"), Success, nil).Force(context.Background())
+		_, err := efs.LessThanOrEqual(Integer(1), testVar("X"), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("e2 is a variable", func(t *testing.T) {
-		_, err := efs.LessThanOrEqual(Variable("X"), Integer(1), Success, nil).Force(context.Background())
+		_, err := efs.LessThanOrEqual(testVar("X"), Integer(1), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
@@ -360,12 +387,12 @@ func TestEvaluableFunctors_GreaterThanOrEqual(t *testing.T) {
 	})
 
 	t.Run("e1 is a variable", func(t *testing.T) {
-		_, err := efs.GreaterThanOrEqual(Integer(1), Variable("X"), Success, nil).Force(context.Background())
+		_, err := efs.GreaterThanOrEqual(Integer(1), testVar("X"), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
 	t.Run("e2 is a variable", func(t *testing.T) {
-		_, err := efs.GreaterThanOrEqual(Variable("X"), Integer(1), Success, nil).Force(context.Background())
+		_, err := efs.GreaterThanOrEqual(testVar("X"), Integer(1), Success, nil).Force(context.Background())
 		assert.Error(t, err)
 	})
 
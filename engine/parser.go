@@ -21,6 +21,7 @@ type Parser struct {
 	args         []Term
 	doubleQuotes doubleQuotes
 	vars         *[]ParsedVariable
+	line         int
 }
 
 // ParsedVariable is a set of information regarding a variable in a parsed term.
@@ -37,6 +38,9 @@ func newParser(input *bufio.Reader, charConversions map[rune]rune, opts ...parse
 	for _, o := range opts {
 		o(&p)
 	}
+	if p.vars == nil {
+		p.vars = &[]ParsedVariable{}
+	}
 	return &p
 }
 
@@ -75,6 +79,11 @@ func (p *Parser) Replace(placeholder Atom, args ...interface{}) error {
 	return nil
 }
 
+// TermOf converts a Go value into a term in the same way as the arguments of Replace.
+func TermOf(v interface{}) (Term, error) {
+	return termOf(reflect.ValueOf(v))
+}
+
 func termOf(o reflect.Value) (Term, error) {
 	if t, ok := o.Interface().(Term); ok {
 		return t, nil
@@ -222,14 +231,13 @@ func (p *Parser) Term() (Term, error) {
 	if _, err := p.accept(TokenEOF); err == nil {
 		return nil, io.EOF
 	}
+	p.line = p.lexer.tokenLine + 1
 
-	if p.vars != nil {
-		// reset vars
-		for i := range *p.vars {
-			(*p.vars)[i] = ParsedVariable{}
-		}
-		*p.vars = (*p.vars)[:0]
+	// reset vars
+	for i := range *p.vars {
+		(*p.vars)[i] = ParsedVariable{}
 	}
+	*p.vars = (*p.vars)[:0]
 
 	t, err := p.expr(1, true, true)
 	if err != nil {
@@ -247,6 +255,11 @@ func (p *Parser) Term() (Term, error) {
 	return t, nil
 }
 
+// Line returns the line number, starting from 1, where the last term returned by Term starts.
+func (p *Parser) Line() int {
+	return p.line
+}
+
 var errNotANumber = errors.New("not a number")
 
 // Number parses a number term.
@@ -436,11 +449,6 @@ func (p *Parser) variable() (Term, error) {
 		return NewVariable(), nil
 	}
 
-	if p.vars == nil {
-		n := Variable(v)
-		return n, nil
-	}
-
 	n := Atom(v)
 	for i, v := range *p.vars {
 		if v.Name == n {
@@ -152,10 +152,11 @@ a quoted ident'.`)), nil)
 
 	t.Run("variable", func(t *testing.T) {
 		t.Run("named", func(t *testing.T) {
+			varCounter = 0
 			p := newParser(bufio.NewReader(strings.NewReader(`X.`)), nil)
 			v, err := p.Term()
 			assert.NoError(t, err)
-			assert.Equal(t, Variable("X"), v)
+			assert.Equal(t, Variable(1), v)
 		})
 
 		t.Run("anonymous", func(t *testing.T) {
@@ -169,6 +170,7 @@ a quoted ident'.`)), nil)
 	})
 
 	t.Run("fact", func(t *testing.T) {
+		varCounter = 0
 		p := newParser(bufio.NewReader(strings.NewReader(`
 append(nil,L,L).
 `)), nil)
@@ -179,8 +181,8 @@ append(nil,L,L).
 			Functor: "append",
 			Args: []Term{
 				Atom("nil"),
-				Variable("L"),
-				Variable("L"),
+				Variable(1),
+				Variable(1),
 			},
 		}, c)
 	})
@@ -189,6 +191,7 @@ append(nil,L,L).
 		ops := operators{
 			{priority: 1200, specifier: operatorSpecifierXFX, name: `:-`},
 		}
+		varCounter = 0
 		p := newParser(bufio.NewReader(strings.NewReader(`
 append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 `)), nil, withOperators(&ops))
@@ -204,16 +207,16 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 						&Compound{
 							Functor: "cons",
 							Args: []Term{
-								Variable("X"),
-								Variable("L1"),
+								Variable(1),
+								Variable(2),
 							},
 						},
-						Variable("L2"),
+						Variable(3),
 						&Compound{
 							Functor: "cons",
 							Args: []Term{
-								Variable("X"),
-								Variable("L3"),
+								Variable(1),
+								Variable(4),
 							},
 						},
 					},
@@ -221,9 +224,9 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 				&Compound{
 					Functor: "append",
 					Args: []Term{
-						Variable("L1"),
-						Variable("L2"),
-						Variable("L3"),
+						Variable(2),
+						Variable(3),
+						Variable(4),
 					},
 				},
 			},
@@ -235,6 +238,7 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 			{priority: 1200, specifier: operatorSpecifierXFX, name: `:-`},
 			{priority: 1000, specifier: operatorSpecifierXFY, name: `,`},
 		}
+		varCounter = 0
 		p := newParser(bufio.NewReader(strings.NewReader(`P, Q :- P, Q.`)), nil, withOperators(&ops))
 		c, err := p.Term()
 		assert.NoError(t, err)
@@ -244,15 +248,15 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 				&Compound{
 					Functor: ",",
 					Args: []Term{
-						Variable("P"),
-						Variable("Q"),
+						Variable(1),
+						Variable(2),
 					},
 				},
 				&Compound{
 					Functor: ",",
 					Args: []Term{
-						Variable("P"),
-						Variable("Q"),
+						Variable(1),
+						Variable(2),
 					},
 				},
 			},
@@ -264,28 +268,29 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 			{priority: 1000, specifier: operatorSpecifierXFY, name: `,`},
 			{priority: 200, specifier: operatorSpecifierXFY, name: `^`},
 		}
+		varCounter = 0
 		p := newParser(bufio.NewReader(strings.NewReader(`bagof(C, A^foo(A, B, C), Cs).`)), nil, withOperators(&ops))
 		c, err := p.Term()
 		assert.NoError(t, err)
 		assert.Equal(t, &Compound{
 			Functor: "bagof",
 			Args: []Term{
-				Variable("C"),
+				Variable(1),
 				&Compound{
 					Functor: "^",
 					Args: []Term{
-						Variable("A"),
+						Variable(2),
 						&Compound{
 							Functor: "foo",
 							Args: []Term{
-								Variable("A"),
-								Variable("B"),
-								Variable("C"),
+								Variable(2),
+								Variable(3),
+								Variable(1),
 							},
 						},
 					},
 				},
-				Variable("Cs"),
+				Variable(4),
 			},
 		}, c)
 	})
@@ -295,34 +300,35 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 			{priority: 1000, specifier: operatorSpecifierXFY, name: `,`},
 			{priority: 200, specifier: operatorSpecifierXFY, name: `^`},
 		}
+		varCounter = 0
 		p := newParser(bufio.NewReader(strings.NewReader(`bagof(C, (A, B)^foo(A, B, C), Cs).`)), nil, withOperators(&ops))
 		c, err := p.Term()
 		assert.NoError(t, err)
 		assert.Equal(t, &Compound{
 			Functor: "bagof",
 			Args: []Term{
-				Variable("C"),
+				Variable(1),
 				&Compound{
 					Functor: "^",
 					Args: []Term{
 						&Compound{
 							Functor: ",",
 							Args: []Term{
-								Variable("A"),
-								Variable("B"),
+								Variable(2),
+								Variable(3),
 							},
 						},
 						&Compound{
 							Functor: "foo",
 							Args: []Term{
-								Variable("A"),
-								Variable("B"),
-								Variable("C"),
+								Variable(2),
+								Variable(3),
+								Variable(1),
 							},
 						},
 					},
 				},
-				Variable("Cs"),
+				Variable(4),
 			},
 		}, c)
 	})
@@ -420,12 +426,13 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 			})
 
 			t.Run("with bar", func(t *testing.T) {
+				varCounter = 0
 				p := newParser(bufio.NewReader(strings.NewReader(`[a, b, c|X].`)), nil, withOperators(&ops))
 				term, err := p.Term()
 				assert.NoError(t, err)
 
 				// A bar in list is not an operator but a separator.
-				assert.Equal(t, ListRest(Variable("X"), Atom("a"), Atom("b"), Atom("c")), term)
+				assert.Equal(t, ListRest(Variable(1), Atom("a"), Atom("b"), Atom("c")), term)
 			})
 		})
 
@@ -552,13 +559,14 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 			{priority: 700, specifier: operatorSpecifierXFX, name: `is`},
 			{priority: 500, specifier: operatorSpecifierYFX, name: `+`},
 		}
+		varCounter = 0
 		p := newParser(bufio.NewReader(strings.NewReader(`X is +1 +1.`)), nil, withOperators(&ops))
 		term, err := p.Term()
 		assert.NoError(t, err)
 		assert.Equal(t, &Compound{
 			Functor: "is",
 			Args: []Term{
-				Variable("X"),
+				Variable(1),
 				&Compound{
 					Functor: "+",
 					Args: []Term{
@@ -576,39 +584,42 @@ append(cons(X,L1),L2,cons(X,L3)) :- append(L1,L2,L3).
 		}
 
 		t.Run("codes", func(t *testing.T) {
+			varCounter = 0
 			p := newParser(bufio.NewReader(strings.NewReader(`X = "abc".`)), nil, withOperators(&ops))
 			term, err := p.Term()
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{
 				Functor: "=",
 				Args: []Term{
-					Variable("X"),
+					Variable(1),
 					List(Integer(97), Integer(98), Integer(99)),
 				},
 			}, term)
 		})
 
 		t.Run("chars", func(t *testing.T) {
+			varCounter = 0
 			p := newParser(bufio.NewReader(strings.NewReader(`X = "abc".`)), nil, withOperators(&ops), withDoubleQuotes(doubleQuotesChars))
 			term, err := p.Term()
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{
 				Functor: "=",
 				Args: []Term{
-					Variable("X"),
+					Variable(1),
 					List(Atom("a"), Atom("b"), Atom("c")),
 				},
 			}, term)
 		})
 
 		t.Run("atom", func(t *testing.T) {
+			varCounter = 0
 			p := newParser(bufio.NewReader(strings.NewReader(`X = "abc".`)), nil, withOperators(&ops), withDoubleQuotes(doubleQuotesAtom))
 			term, err := p.Term()
 			assert.NoError(t, err)
 			assert.Equal(t, &Compound{
 				Functor: "=",
 				Args: []Term{
-					Variable("X"),
+					Variable(1),
 					Atom("abc"),
 				},
 			}, term)
@@ -859,3 +870,18 @@ func TestParser_More(t *testing.T) {
 	assert.Equal(t, Atom("bar"), term)
 	assert.False(t, p.More())
 }
+
+func TestParser_Line(t *testing.T) {
+	p := newParser(bufio.NewReader(strings.NewReader(`foo.
+% comment
+bar(
+  baz).
+
+/* comment
+*/ qux.`)), nil)
+	for _, line := range []int{1, 3, 7} {
+		_, err := p.Term()
+		assert.NoError(t, err)
+		assert.Equal(t, line, p.Line())
+	}
+}
@@ -0,0 +1,407 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Test is a unit test defined by test/1 or test/2 clauses between begin_tests/1 and end_tests/1.
+type Test struct {
+	Unit    Atom
+	Name    Term
+	Options Term
+	Body    Term
+	Pos     SourcePos
+}
+
+// TestResult is the outcome of a test.
+type TestResult struct {
+	Test Test
+
+	// Err is the reason why the test failed or nil if it passed.
+	Err error
+
+	// Choicepoint is true if the test passed leaving choicepoints without nondet option.
+	Choicepoint bool
+}
+
+// BeginTests starts the unit of tests. The test/1 and test/2 clauses loaded until end_tests/1 belong to the unit.
+// The tests previously defined for the unit are discarded.
+func (state *State) BeginTests(unit Term, k func(*Env) *Promise, env *Env) *Promise {
+	switch u := env.Resolve(unit).(type) {
+	case Variable:
+		return Error(ErrInstantiation)
+	case Atom:
+		tests := state.tests[:0]
+		for _, t := range state.tests {
+			if t.Unit != u {
+				tests = append(tests, t)
+			}
+		}
+		state.tests = tests
+		state.testUnit = u
+		return k(env)
+	default:
+		return Error(TypeErrorAtom(u))
+	}
+}
+
+// EndTests ends the unit of tests.
+func (state *State) EndTests(unit Term, k func(*Env) *Promise, env *Env) *Promise {
+	switch u := env.Resolve(unit).(type) {
+	case Variable:
+		return Error(ErrInstantiation)
+	case Atom:
+		if u != state.testUnit {
+			return Error(ExistenceError("unit_test", u))
+		}
+		state.testUnit = ""
+		return k(env)
+	default:
+		return Error(TypeErrorAtom(u))
+	}
+}
+
+// testClause converts a clause of test/1 or test/2 into a test.
+func (state *State) testClause(t Term, pos SourcePos, env *Env) (Test, bool) {
+	var head, body Term = env.Resolve(t), Atom("true")
+	if c, ok := head.(*Compound); ok && c.Functor == ":-" && len(c.Args) == 2 {
+		head, body = env.Resolve(c.Args[0]), c.Args[1]
+	}
+	c, ok := head.(*Compound)
+	if !ok || c.Functor != "test" {
+		return Test{}, false
+	}
+	test := Test{Unit: state.testUnit, Name: c.Args[0], Options: Atom("[]"), Body: body, Pos: pos}
+	switch len(c.Args) {
+	case 1:
+		return test, true
+	case 2:
+		test.Options = c.Args[1]
+		return test, true
+	default:
+		return Test{}, false
+	}
+}
+
+// Tests returns the tests defined so far.
+func (state *State) Tests() []Test {
+	return append([]Test(nil), state.tests...)
+}
+
+type testOptions struct {
+	conditions     []Term
+	fail           bool
+	throws         Term
+	all            *Compound
+	nondet         bool
+	setup, cleanup Term
+}
+
+// comparisons are the operators that can be used as a shorthand of true(Cond) and in all(Cmp).
+var comparisons = map[Atom]struct{}{
+	"=": {}, "==": {}, "=@=": {}, "\\=": {}, "\\==": {},
+	"=:=": {}, "=\\=": {}, "<": {}, ">": {}, "=<": {}, ">=": {},
+	"@<": {}, "@>": {}, "@=<": {}, "@>=": {},
+}
+
+func parseTestOptions(options Term, env *Env) (testOptions, error) {
+	var opts testOptions
+	var list []Term
+	switch o := env.Resolve(options).(type) {
+	case Variable:
+		return opts, ErrInstantiation
+	case *Compound:
+		if o.Functor != "." || len(o.Args) != 2 {
+			list = []Term{o}
+			break
+		}
+		iter := ListIterator{List: o, Env: env}
+		for iter.Next() {
+			list = append(list, iter.Current())
+		}
+		if err := iter.Err(); err != nil {
+			return opts, err
+		}
+	default:
+		if o != Atom("[]") {
+			list = []Term{o}
+		}
+	}
+
+	for _, o := range list {
+		switch o := env.Resolve(o).(type) {
+		case Atom:
+			switch o {
+			case "fail", "false":
+				opts.fail = true
+			case "nondet":
+				opts.nondet = true
+			default:
+				return opts, DomainError("test_option", o)
+			}
+		case *Compound:
+			if _, ok := comparisons[o.Functor]; ok && len(o.Args) == 2 {
+				opts.conditions = append(opts.conditions, o)
+				continue
+			}
+			if len(o.Args) != 1 {
+				return opts, DomainError("test_option", o)
+			}
+			switch o.Functor {
+			case "true":
+				opts.conditions = append(opts.conditions, o.Args[0])
+			case "throws":
+				opts.throws = o.Args[0]
+			case "error":
+				opts.throws = &Compound{Functor: "error", Args: []Term{o.Args[0], NewVariable()}}
+			case "all":
+				c, ok := env.Resolve(o.Args[0]).(*Compound)
+				if !ok || len(c.Args) != 2 {
+					return opts, DomainError("test_option", o)
+				}
+				if _, ok := comparisons[c.Functor]; !ok {
+					return opts, DomainError("test_option", o)
+				}
+				opts.all = c
+			case "setup":
+				opts.setup = o.Args[0]
+			case "cleanup":
+				opts.cleanup = o.Args[0]
+			default:
+				return opts, DomainError("test_option", o)
+			}
+		default:
+			return opts, DomainError("test_option", o)
+		}
+	}
+	return opts, nil
+}
+
+// RunTest runs the test.
+func (state *State) RunTest(ctx context.Context, test Test) *TestResult {
+	r := TestResult{Test: test}
+
+	opts, err := parseTestOptions(test.Options, nil)
+	if err != nil {
+		r.Err = state.testError(err, nil)
+		return &r
+	}
+
+	var env *Env
+	if opts.setup != nil {
+		ok, err := state.Call(opts.setup, func(e *Env) *Promise {
+			env = e
+			return Bool(true)
+		}, nil).Force(ctx)
+		if err != nil {
+			r.Err = fmt.Errorf("setup: %w", state.testError(err, nil))
+			return &r
+		}
+		if !ok {
+			r.Err = errors.New("setup failed")
+			return &r
+		}
+	}
+	if opts.cleanup != nil {
+		defer func() {
+			_, _ = state.Call(opts.cleanup, Success, env).Force(ctx)
+		}()
+	}
+
+	r.Choicepoint, r.Err = state.runTestBody(ctx, test.Body, opts, env)
+	return &r
+}
+
+func (state *State) runTestBody(ctx context.Context, body Term, opts testOptions, env *Env) (bool, error) {
+	switch {
+	case opts.fail:
+		ok, err := state.Call(body, Success, env).Force(ctx)
+		if err != nil {
+			return false, state.testError(err, env)
+		}
+		if ok {
+			return false, errors.New("succeeded, but should have failed")
+		}
+		return false, nil
+	case opts.throws != nil:
+		ok, err := state.Call(body, Success, env).Force(ctx)
+		var e *Exception
+		switch {
+		case errors.As(err, &e):
+			if _, ok := opts.throws.Unify(e.Term, false, env); !ok {
+				return false, fmt.Errorf("wrong exception: expected %s, but got %s", state.testTerm(opts.throws, env), state.testTerm(e.Term, env))
+			}
+			return false, nil
+		case err != nil:
+			return false, err
+		case ok:
+			return false, fmt.Errorf("succeeded, but should have raised %s", state.testTerm(opts.throws, env))
+		default:
+			return false, fmt.Errorf("failed, but should have raised %s", state.testTerm(opts.throws, env))
+		}
+	case opts.all != nil:
+		var answers []Term
+		if _, err := state.Call(body, func(env *Env) *Promise {
+			answers = append(answers, env.Simplify(opts.all.Args[0]))
+			return Bool(false)
+		}, env).Force(ctx); err != nil {
+			return false, state.testError(err, env)
+		}
+		got := List(answers...)
+		ok, err := state.Call(&Compound{Functor: opts.all.Functor, Args: []Term{got, opts.all.Args[1]}}, Success, env).Force(ctx)
+		if err != nil {
+			return false, state.testError(err, env)
+		}
+		if !ok {
+			return false, fmt.Errorf("wrong answer: expected %s, but got %s", state.testTerm(opts.all.Args[1], env), state.testTerm(got, env))
+		}
+		return false, nil
+	default:
+		var (
+			det    bool
+			result *Env
+			p      *Promise
+		)
+		p = Delay(func(context.Context) *Promise {
+			return state.Call(body, func(env *Env) *Promise {
+				return detect(p, func(d bool) *Promise {
+					det, result = d, env
+					return Bool(true)
+				})
+			}, env)
+		}, func(context.Context) *Promise {
+			return Bool(false)
+		})
+		ok, err := p.Force(ctx)
+		if err != nil {
+			return false, state.testError(err, env)
+		}
+		if !ok {
+			return false, errors.New("failed")
+		}
+		for _, c := range opts.conditions {
+			ok, err := state.Call(c, Success, result).Force(ctx)
+			if err != nil {
+				return false, state.testError(err, result)
+			}
+			if !ok {
+				return false, fmt.Errorf("wrong answer: %s", state.testTerm(c, result))
+			}
+		}
+		return !det && !opts.nondet, nil
+	}
+}
+
+func (state *State) testError(err error, env *Env) error {
+	var e *Exception
+	if errors.As(err, &e) {
+		return fmt.Errorf("raised %s", state.testTerm(e.Term, env))
+	}
+	return err
+}
+
+func (state *State) testTerm(t Term, env *Env) string {
+	var sb strings.Builder
+	_ = state.Write(&sb, t, env, WithQuoted(true))
+	return sb.String()
+}
+
+// RunTests runs the tests specified by spec which is all, a unit, Unit:Test, or a list of them, and prints the results
+// to the current output. It fails if any of the tests failed.
+func (state *State) RunTests(spec Term, k func(*Env) *Promise, env *Env) *Promise {
+	tests, err := state.testSpec(spec, env)
+	if err != nil {
+		return Error(err)
+	}
+
+	return Delay(func(ctx context.Context) *Promise {
+		var sb strings.Builder
+		var failed int
+		for _, t := range tests {
+			r := state.RunTest(ctx, t)
+			switch {
+			case r.Err != nil:
+				failed++
+				_, _ = fmt.Fprintf(&sb, "ERROR: %stest %s:%s: %v\n", testPos(t.Pos), t.Unit, state.testTerm(t.Name, nil), r.Err)
+			case r.Choicepoint:
+				_, _ = fmt.Fprintf(&sb, "Warning: %stest %s:%s: succeeded with choicepoint\n", testPos(t.Pos), t.Unit, state.testTerm(t.Name, nil))
+			}
+		}
+		if failed == 0 {
+			_, _ = fmt.Fprintf(&sb, "%% All %d tests passed\n", len(tests))
+		} else {
+			_, _ = fmt.Fprintf(&sb, "%% %d tests failed\n%% %d tests passed\n", failed, len(tests)-failed)
+		}
+		if _, err := fmt.Fprint(state.output.file, sb.String()); err != nil {
+			return Error(err)
+		}
+
+		if failed > 0 {
+			return Bool(false)
+		}
+		return k(env)
+	})
+}
+
+func testPos(pos SourcePos) string {
+	if pos.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d: ", pos.File, pos.Line)
+}
+
+func (state *State) testSpec(spec Term, env *Env) ([]Test, error) {
+	switch s := env.Resolve(spec).(type) {
+	case Variable:
+		return nil, ErrInstantiation
+	case Atom:
+		switch s {
+		case "all":
+			return state.Tests(), nil
+		case "[]":
+			return nil, nil
+		}
+		var tests []Test
+		for _, t := range state.tests {
+			if t.Unit == s {
+				tests = append(tests, t)
+			}
+		}
+		if len(tests) == 0 {
+			return nil, ExistenceError("unit_test", s)
+		}
+		return tests, nil
+	case *Compound:
+		switch {
+		case s.Functor == "." && len(s.Args) == 2:
+			var tests []Test
+			iter := ListIterator{List: s, Env: env}
+			for iter.Next() {
+				ts, err := state.testSpec(iter.Current(), env)
+				if err != nil {
+					return nil, err
+				}
+				tests = append(tests, ts...)
+			}
+			return tests, iter.Err()
+		case s.Functor == ":" && len(s.Args) == 2:
+			var tests []Test
+			for _, t := range state.tests {
+				if _, ok := s.Unify(&Compound{Functor: ":", Args: []Term{t.Unit, t.Name}}, false, env); ok {
+					tests = append(tests, t)
+				}
+			}
+			if len(tests) == 0 {
+				return nil, ExistenceError("unit_test", s)
+			}
+			return tests, nil
+		default:
+			return nil, DomainError("test_spec", s)
+		}
+	default:
+		return nil, DomainError("test_spec", s)
+	}
+}
@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_BeginTests(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var state State
+		ok, err := state.BeginTests(Atom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.NoError(t, state.AssertAt(&Compound{Functor: "test", Args: []Term{Atom("a")}}, SourcePos{File: "foo.pl", Line: 2}, nil))
+		assert.NoError(t, state.AssertAt(&Compound{Functor: ":-", Args: []Term{
+			&Compound{Functor: "test", Args: []Term{Atom("b"), Atom("fail")}},
+			Atom("false"),
+		}}, SourcePos{File: "foo.pl", Line: 3}, nil))
+		assert.NoError(t, state.AssertAt(&Compound{Functor: "bar", Args: []Term{Atom("a")}}, SourcePos{File: "foo.pl", Line: 4}, nil))
+
+		ok, err = state.EndTests(Atom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.NoError(t, state.AssertAt(&Compound{Functor: "test", Args: []Term{Atom("c")}}, SourcePos{File: "foo.pl", Line: 6}, nil))
+
+		assert.Equal(t, []Test{
+			{Unit: "foo", Name: Atom("a"), Options: Atom("[]"), Body: Atom("true"), Pos: SourcePos{File: "foo.pl", Line: 2}},
+			{Unit: "foo", Name: Atom("b"), Options: Atom("fail"), Body: Atom("false"), Pos: SourcePos{File: "foo.pl", Line: 3}},
+		}, state.Tests())
+		assert.Contains(t, state.procedures, ProcedureIndicator{Name: "bar", Arity: 1})
+		assert.Contains(t, state.procedures, ProcedureIndicator{Name: "test", Arity: 1})
+	})
+
+	t.Run("redefine", func(t *testing.T) {
+		state := State{tests: []Test{{Unit: "foo"}, {Unit: "bar"}}}
+		ok, err := state.BeginTests(Atom("foo"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Test{{Unit: "bar"}}, state.Tests())
+	})
+
+	t.Run("variable", func(t *testing.T) {
+		var state State
+		_, err := state.BeginTests(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("not an atom", func(t *testing.T) {
+		var state State
+		_, err := state.BeginTests(Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorAtom(Integer(0)), err)
+	})
+}
+
+func TestState_EndTests(t *testing.T) {
+	t.Run("not open", func(t *testing.T) {
+		state := State{testUnit: "foo"}
+		_, err := state.EndTests(Atom("bar"), Success, nil).Force(context.Background())
+		assert.Equal(t, ExistenceError("unit_test", Atom("bar")), err)
+	})
+
+	t.Run("variable", func(t *testing.T) {
+		var state State
+		_, err := state.EndTests(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("not an atom", func(t *testing.T) {
+		var state State
+		_, err := state.EndTests(Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorAtom(Integer(0)), err)
+	})
+}
+
+func TestState_RunTest(t *testing.T) {
+	var state State
+	state.Register2("=", Unify)
+	state.Register1("throw", Throw)
+	state.Register3("compare", Compare)
+	for _, c := range []Term{
+		Atom("true"),
+		&Compound{Functor: ":-", Args: []Term{Atom("fail"), &Compound{Functor: "\\+", Args: []Term{Atom("true")}}}},
+		&Compound{Functor: ":-", Args: []Term{
+			&Compound{Functor: "==", Args: []Term{testVar("X"), testVar("Y")}},
+			&Compound{Functor: "compare", Args: []Term{Atom("="), testVar("X"), testVar("Y")}},
+		}},
+		&Compound{Functor: "foo", Args: []Term{Atom("a")}},
+		&Compound{Functor: "foo", Args: []Term{Atom("b")}},
+	} {
+		assert.NoError(t, state.Assert(c, nil))
+	}
+	state.Register1("\\+", state.Negation)
+
+	foo := func(x Term) Term {
+		return &Compound{Functor: "foo", Args: []Term{x}}
+	}
+	eq := func(x, y Term) Term {
+		return &Compound{Functor: "==", Args: []Term{x, y}}
+	}
+	x := NewVariable()
+
+	for _, tt := range []struct {
+		title       string
+		options     Term
+		body        Term
+		err         string
+		choicepoint bool
+	}{
+		{title: "pass", options: Atom("[]"), body: foo(Atom("a"))},
+		{title: "fail", options: Atom("[]"), body: foo(Atom("c")), err: "failed"},
+		{title: "choicepoint", options: Atom("[]"), body: foo(x), choicepoint: true},
+		{title: "nondet", options: List(Atom("nondet")), body: foo(x)},
+		{title: "true", options: &Compound{Functor: "true", Args: []Term{eq(x, Atom("a"))}}, body: foo(x), choicepoint: true},
+		{title: "shorthand", options: List(eq(x, Atom("b")), Atom("nondet")), body: foo(x), err: "wrong answer: ==(a, b)"},
+		{title: "expected failure", options: Atom("fail"), body: foo(Atom("c"))},
+		{title: "unexpected success", options: Atom("fail"), body: foo(Atom("a")), err: "succeeded, but should have failed"},
+		{title: "throws", options: &Compound{Functor: "throws", Args: []Term{Atom("e")}}, body: &Compound{Functor: "throw", Args: []Term{Atom("e")}}},
+		{title: "wrong exception", options: &Compound{Functor: "throws", Args: []Term{Atom("e")}}, body: &Compound{Functor: "throw", Args: []Term{Atom("f")}}, err: "wrong exception: expected e, but got f"},
+		{title: "no exception", options: &Compound{Functor: "throws", Args: []Term{Atom("e")}}, body: foo(Atom("a")), err: "succeeded, but should have raised e"},
+		{title: "exception", options: Atom("[]"), body: &Compound{Functor: "throw", Args: []Term{Atom("e")}}, err: "raised e"},
+		{title: "all", options: &Compound{Functor: "all", Args: []Term{eq(x, List(Atom("a"), Atom("b")))}}, body: foo(x)},
+		{title: "wrong all", options: &Compound{Functor: "all", Args: []Term{eq(x, List(Atom("a")))}}, body: foo(x), err: "wrong answer: expected [a], but got [a, b]"},
+		{title: "setup", options: &Compound{Functor: "setup", Args: []Term{&Compound{Functor: "=", Args: []Term{x, Atom("b")}}}}, body: foo(x)},
+		{title: "setup failed", options: &Compound{Functor: "setup", Args: []Term{Atom("fail")}}, body: foo(x), err: "setup failed"},
+		{title: "unknown option", options: Atom("foo"), body: foo(x), err: "raised error(domain_error(test_option, foo)"},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			r := state.RunTest(context.Background(), Test{Unit: "foo", Name: Atom(tt.title), Options: tt.options, Body: tt.body})
+			if tt.err == "" {
+				assert.NoError(t, r.Err)
+			} else if assert.Error(t, r.Err) {
+				assert.Contains(t, r.Err.Error(), tt.err)
+			}
+			assert.Equal(t, tt.choicepoint, r.Choicepoint)
+		})
+	}
+
+	t.Run("cleanup", func(t *testing.T) {
+		var cleaned bool
+		state.Register0("clean", func(k func(*Env) *Promise, env *Env) *Promise {
+			cleaned = true
+			return k(env)
+		})
+		r := state.RunTest(context.Background(), Test{Unit: "foo", Name: Atom("cleanup"), Options: &Compound{Functor: "cleanup", Args: []Term{Atom("clean")}}, Body: foo(Atom("c"))})
+		assert.Error(t, r.Err)
+		assert.True(t, cleaned)
+	})
+}
+
+func TestState_RunTests(t *testing.T) {
+	newState := func() (*State, *bytes.Buffer) {
+		var buf bytes.Buffer
+		var state State
+		state.SetUserOutput(&buf)
+		state.tests = []Test{
+			{Unit: "foo", Name: Atom("a"), Options: Atom("[]"), Body: Atom("true")},
+			{Unit: "foo", Name: Atom("b"), Options: Atom("[]"), Body: Atom("fail"), Pos: SourcePos{File: "foo.pl", Line: 3}},
+			{Unit: "bar", Name: Atom("c"), Options: Atom("[]"), Body: Atom("true")},
+		}
+		state.Register0("true", func(k func(*Env) *Promise, env *Env) *Promise {
+			return k(env)
+		})
+		state.Register0("fail", func(func(*Env) *Promise, *Env) *Promise {
+			return Bool(false)
+		})
+		return &state, &buf
+	}
+
+	t.Run("all", func(t *testing.T) {
+		state, buf := newState()
+		ok, err := state.RunTests(Atom("all"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, `ERROR: foo.pl:3: test foo:b: failed
+% 1 tests failed
+% 2 tests passed
+`, buf.String())
+	})
+
+	t.Run("unit", func(t *testing.T) {
+		state, buf := newState()
+		ok, err := state.RunTests(Atom("bar"), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "% All 1 tests passed\n", buf.String())
+	})
+
+	t.Run("test", func(t *testing.T) {
+		state, buf := newState()
+		ok, err := state.RunTests(List(&Compound{Functor: ":", Args: []Term{Atom("foo"), Atom("a")}}), Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "% All 1 tests passed\n", buf.String())
+	})
+
+	t.Run("unknown unit", func(t *testing.T) {
+		state, _ := newState()
+		_, err := state.RunTests(Atom("baz"), Success, nil).Force(context.Background())
+		assert.Equal(t, ExistenceError("unit_test", Atom("baz")), err)
+	})
+
+	t.Run("variable", func(t *testing.T) {
+		state, _ := newState()
+		_, err := state.RunTests(NewVariable(), Success, nil).Force(context.Background())
+		assert.Equal(t, ErrInstantiation, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		state, _ := newState()
+		_, err := state.RunTests(Integer(0), Success, nil).Force(context.Background())
+		assert.Equal(t, DomainError("test_spec", Integer(0)), err)
+	})
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// WritePprof writes the profile in the gzipped protobuf format of pprof so that we can analyze it with `go tool pprof`.
+// Each procedure is reported as a function. The sample values are calls and time in nanoseconds.
+func (p *Profile) WritePprof(w io.Writer) error {
+	var b protobuf
+
+	strings := map[string]int64{}
+	stringIndex := func(s string) int64 {
+		i, ok := strings[s]
+		if !ok {
+			i = int64(len(strings))
+			strings[s] = i
+			b.string(6, s) // Profile.string_table
+		}
+		return i
+	}
+	_ = stringIndex("")
+
+	valueType := func(field int, typ, unit string) {
+		t, u := stringIndex(typ), stringIndex(unit)
+		b.message(field, func(b *protobuf) {
+			b.int64(1, t) // ValueType.type
+			b.int64(2, u) // ValueType.unit
+		})
+	}
+	valueType(1, "calls", "count")      // Profile.sample_type
+	valueType(1, "time", "nanoseconds") // Profile.sample_type
+
+	ids := map[ProcedureIndicator]uint64{}
+	for _, s := range p.Samples {
+		locs := make([]uint64, len(s.Stack))
+		for i, pi := range s.Stack {
+			id, ok := ids[pi]
+			if !ok {
+				id = uint64(len(ids) + 1)
+				ids[pi] = id
+
+				name := stringIndex(pi.String())
+				b.message(5, func(b *protobuf) { // Profile.function
+					b.uint64(1, id)  // Function.id
+					b.int64(2, name) // Function.name
+					b.int64(3, name) // Function.system_name
+				})
+				b.message(4, func(b *protobuf) { // Profile.location
+					b.uint64(1, id)                  // Location.id
+					b.message(4, func(b *protobuf) { // Location.line
+						b.uint64(1, id) // Line.function_id
+					})
+				})
+			}
+			locs[i] = id
+		}
+		b.message(2, func(b *protobuf) { // Profile.sample
+			b.packed(1, locs)                                      // Sample.location_id
+			b.packed(2, []uint64{uint64(s.Calls), uint64(s.Time)}) // Sample.value
+		})
+	}
+
+	b.int64(9, p.Start.UnixNano())       // Profile.time_nanos
+	b.int64(10, int64(p.Duration))       // Profile.duration_nanos
+	valueType(11, "time", "nanoseconds") // Profile.period_type
+	b.int64(12, 1)                       // Profile.period
+	b.int64(14, stringIndex("time"))     // Profile.default_sample_type
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protobuf is a minimal encoder of protocol buffers.
+type protobuf struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+func (b *protobuf) tag(field int, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	b.tag(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) string(field int, s string) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	b.message(field, func(b *protobuf) {
+		for _, x := range xs {
+			b.varint(x)
+		}
+	})
+}
+
+func (b *protobuf) message(field int, f func(b *protobuf)) {
+	var m protobuf
+	f(&m)
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(m.buf)))
+	b.buf = append(b.buf, m.buf...)
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Profiler is a Tracer that records statistics of procedures.
+// The zero value for Profiler is not valid. Use NewProfiler instead.
+type Profiler struct {
+	now   func() time.Time
+	start time.Time
+	last  time.Time
+
+	stack []profileFrame // the goals we're in, indexed by depth.
+	depth int
+
+	procedures map[ProcedureIndicator]*ProcedureProfile
+	samples    map[string]*ProfileSample
+}
+
+type profileFrame struct {
+	pi     ProcedureIndicator
+	hidden bool
+}
+
+// NewProfiler creates a new Profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		now:        time.Now,
+		procedures: map[ProcedureIndicator]*ProcedureProfile{},
+		samples:    map[string]*ProfileSample{},
+	}
+}
+
+// Trace records the event.
+func (p *Profiler) Trace(e *Event) {
+	now := p.now()
+	p.elapse(now)
+
+	switch e.Port {
+	case PortCall, PortRedo:
+		// The profiler may start in the middle of an execution. Then, we don't know about the outer goals.
+		for len(p.stack) < e.Depth-1 {
+			p.stack = append(p.stack, profileFrame{hidden: true})
+		}
+		p.stack = append(p.stack[:e.Depth-1], profileFrame{pi: e.PI, hidden: !debuggable(e.PI)})
+		p.depth = e.Depth
+	default:
+		p.depth = e.Depth - 1
+	}
+
+	if debuggable(e.PI) {
+		proc, ok := p.procedures[e.PI]
+		if !ok {
+			proc = &ProcedureProfile{PI: e.PI}
+			p.procedures[e.PI] = proc
+		}
+		switch e.Port {
+		case PortCall:
+			proc.Calls++
+			if s := p.sample(); s != nil {
+				s.Calls++
+			}
+		case PortExit:
+			proc.Exits++
+			if !e.Deterministic {
+				proc.Choicepoints++
+			}
+		case PortRedo:
+			proc.Redos++
+		case PortFail:
+			proc.Fails++
+		case PortException:
+			proc.Exceptions++
+		}
+	}
+}
+
+// elapse attributes the time since the last event to the goals we're in.
+func (p *Profiler) elapse(now time.Time) {
+	if p.start.IsZero() {
+		p.start = now
+	}
+	if !p.last.IsZero() {
+		if s := p.sample(); s != nil {
+			s.Time += now.Sub(p.last)
+		}
+	}
+	p.last = now
+}
+
+// sample returns the sample for the current stack of goals or nil if we're not in any visible goals.
+func (p *Profiler) sample() *ProfileSample {
+	var (
+		sb    strings.Builder
+		stack []ProcedureIndicator
+	)
+	for i := p.depth - 1; i >= 0; i-- {
+		f := p.stack[i]
+		if f.hidden {
+			continue
+		}
+		_, _ = fmt.Fprintf(&sb, "%s\n", f.pi)
+		stack = append(stack, f.pi)
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+	s, ok := p.samples[sb.String()]
+	if !ok {
+		s = &ProfileSample{Stack: stack}
+		p.samples[sb.String()] = s
+	}
+	return s
+}
+
+// Profile returns the statistics recorded so far.
+func (p *Profiler) Profile() *Profile {
+	p.elapse(p.now())
+
+	prof := Profile{
+		Start:    p.start,
+		Duration: p.last.Sub(p.start),
+	}
+
+	procs := make(map[ProcedureIndicator]*ProcedureProfile, len(p.procedures))
+	for pi, proc := range p.procedures {
+		proc := *proc
+		procs[pi] = &proc
+	}
+
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := *p.samples[k]
+		s.Stack = append([]ProcedureIndicator(nil), s.Stack...)
+		prof.Samples = append(prof.Samples, s)
+
+		procs[s.Stack[0]].Exclusive += s.Time
+		// Recursive calls are counted once.
+		seen := map[ProcedureIndicator]struct{}{}
+		for _, pi := range s.Stack {
+			if _, ok := seen[pi]; ok {
+				continue
+			}
+			seen[pi] = struct{}{}
+			procs[pi].Inclusive += s.Time
+		}
+	}
+
+	for _, proc := range procs {
+		prof.Procedures = append(prof.Procedures, *proc)
+	}
+	sort.Slice(prof.Procedures, func(i, j int) bool {
+		pi, pj := prof.Procedures[i], prof.Procedures[j]
+		if pi.Inclusive != pj.Inclusive {
+			return pi.Inclusive > pj.Inclusive
+		}
+		return pi.PI.String() < pj.PI.String()
+	})
+
+	return &prof
+}
+
+// Profile is the statistics of an execution.
+type Profile struct {
+	Start    time.Time
+	Duration time.Duration
+
+	// Procedures are sorted by inclusive time in descending order.
+	Procedures []ProcedureProfile
+
+	// Samples are the calls and time spent for each stack of goals.
+	Samples []ProfileSample
+}
+
+// ProcedureProfile is the statistics of a procedure.
+type ProcedureProfile struct {
+	PI ProcedureIndicator
+
+	Calls      int64
+	Redos      int64
+	Exits      int64
+	Fails      int64
+	Exceptions int64
+
+	// Choicepoints is the number of exits which left choicepoints.
+	Choicepoints int64
+
+	// Inclusive is the time spent in the procedure including the procedures it called.
+	Inclusive time.Duration
+	// Exclusive is the time spent in the procedure itself.
+	Exclusive time.Duration
+}
+
+// ProfileSample is the statistics of a stack of goals.
+type ProfileSample struct {
+	// Stack is the procedures of the goals from the innermost to the outermost.
+	Stack []ProcedureIndicator
+
+	Calls int64
+	Time  time.Duration
+}
+
+// WriteText writes the statistics of procedures in a human-readable table.
+func (p *Profile) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Predicate\tCalls\tRedos\tExits\tFails\tExceptions\tChoicepoints\tInclusive\tExclusive\t")
+	for _, proc := range p.Procedures {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t\n", proc.PI, proc.Calls, proc.Redos, proc.Exits, proc.Fails, proc.Exceptions, proc.Choicepoints, proc.Inclusive, proc.Exclusive)
+	}
+	return tw.Flush()
+}
+
+// Profile executes goal once with the profiler and prints the statistics to the current output.
+func (state *State) Profile(goal Term, k func(*Env) *Promise, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		p := NewProfiler()
+		outer := state.profiler
+		state.profiler = p
+		var result *Env
+		ok, err := state.Call(goal, func(env *Env) *Promise {
+			result = env
+			return Bool(true)
+		}, env).Force(ctx)
+		state.profiler = outer
+
+		if err := p.Profile().WriteText(state.output.file); err != nil {
+			return Error(err)
+		}
+
+		if err != nil {
+			return Error(err)
+		}
+		if !ok {
+			return Bool(false)
+		}
+		return k(result)
+	})
+}
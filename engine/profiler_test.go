@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProfiler() *Profiler {
+	p := NewProfiler()
+	var now time.Time
+	p.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	return p
+}
+
+func TestProfiler_Profile(t *testing.T) {
+	foo := ProcedureIndicator{Name: "foo", Arity: 1}
+	bar := ProcedureIndicator{Name: "bar", Arity: 1}
+
+	t.Run("deterministic", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		p := newTestProfiler()
+		state.Tracer = p
+		ok, err := state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.Equal(t, &Profile{
+			Start:    time.Time{}.Add(time.Millisecond),
+			Duration: 4 * time.Millisecond,
+			Procedures: []ProcedureProfile{
+				{PI: foo, Calls: 1, Exits: 1, Inclusive: 3 * time.Millisecond, Exclusive: 2 * time.Millisecond},
+				{PI: bar, Calls: 1, Exits: 1, Inclusive: time.Millisecond, Exclusive: time.Millisecond},
+			},
+			Samples: []ProfileSample{
+				{Stack: []ProcedureIndicator{bar, foo}, Calls: 1, Time: time.Millisecond},
+				{Stack: []ProcedureIndicator{foo}, Calls: 1, Time: 2 * time.Millisecond},
+			},
+		}, p.Profile())
+	})
+
+	t.Run("nondeterministic", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		p := newTestProfiler()
+		state.Tracer = p
+		ok, err := state.Call(&Compound{Functor: "foo", Args: []Term{NewVariable()}}, Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		prof := p.Profile()
+		assert.Equal(t, []ProcedureProfile{
+			{PI: foo, Calls: 1, Redos: 1, Exits: 2, Choicepoints: 1, Inclusive: 6 * time.Millisecond, Exclusive: 4 * time.Millisecond},
+			{PI: bar, Calls: 1, Redos: 1, Exits: 2, Choicepoints: 1, Inclusive: 2 * time.Millisecond, Exclusive: 2 * time.Millisecond},
+		}, prof.Procedures)
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		state, _ := newDebugState(t)
+		p := newTestProfiler()
+		state.Tracer = p
+		_, err := state.Call(Atom("baz"), Success, nil).Force(context.Background())
+		assert.Error(t, err)
+
+		prof := p.Profile()
+		assert.Equal(t, []ProcedureProfile{
+			{PI: ProcedureIndicator{Name: "baz", Arity: 0}, Calls: 1, Exceptions: 1, Inclusive: 3 * time.Millisecond, Exclusive: 2 * time.Millisecond},
+			{PI: ProcedureIndicator{Name: "throw", Arity: 1}, Calls: 1, Exceptions: 1, Inclusive: time.Millisecond, Exclusive: time.Millisecond},
+		}, prof.Procedures)
+	})
+}
+
+func TestProfile_WritePprof(t *testing.T) {
+	foo := ProcedureIndicator{Name: "foo", Arity: 1}
+	bar := ProcedureIndicator{Name: "bar", Arity: 1}
+	prof := Profile{
+		Duration: 3 * time.Millisecond,
+		Samples: []ProfileSample{
+			{Stack: []ProcedureIndicator{bar, foo}, Calls: 1, Time: time.Millisecond},
+			{Stack: []ProcedureIndicator{foo}, Calls: 1, Time: 2 * time.Millisecond},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, prof.WritePprof(&buf))
+
+	r, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+
+	// sample_type: calls/count
+	assert.Equal(t, []byte{0x32, 0x00, 0x32, 0x05, 'c', 'a', 'l', 'l', 's', 0x32, 0x05, 'c', 'o', 'u', 'n', 't', 0x0a, 0x04, 0x08, 0x01, 0x10, 0x02}, b[:22])
+	assert.Contains(t, string(b), "bar/1")
+	assert.Contains(t, string(b), "foo/1")
+}
+
+func TestState_Profile(t *testing.T) {
+	state, _ := newDebugState(t)
+	var buf bytes.Buffer
+	state.SetUserOutput(&buf)
+
+	ok, err := state.Profile(&Compound{Functor: "foo", Args: []Term{NewVariable()}}, Success, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, state.profiler)
+	assert.Regexp(t, `\APredicate +Calls +Redos +Exits +Fails +Exceptions +Choicepoints +Inclusive +Exclusive *
+foo/1 +1 +0 +1 +0 +0 +1 +.+
+bar/1 +1 +0 +1 +0 +0 +1 +.+
+\z`, buf.String())
+}
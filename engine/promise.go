@@ -18,6 +18,19 @@ type Promise struct {
 	cutParent *Promise
 	repeat    bool
 	recover   func(error) *Promise
+	detect    *detection
+
+	// discard is called if the remaining choices are discarded by a cut, an exception, or the end of the execution.
+	discard func(ctx context.Context)
+
+	// delimiter is the frame of reset/3 or call_continuation/1 which the promise delimits.
+	delimiter *resetFrame
+
+	// delimited is called with the stack so that it can find the delimiters.
+	delimited func(s promiseStack) *Promise
+
+	// the position in the stack when it was last visited.
+	depth int
 }
 
 // Delay delays an execution of k.
@@ -48,6 +61,27 @@ func Cut(parent *Promise, k func(context.Context) *Promise) *Promise {
 	}
 }
 
+type detection struct {
+	parent *Promise
+	k      func(det bool) *Promise
+}
+
+// detect returns a promise that calls k with whether there's no choicepoints above parent once the execution reaches it.
+func detect(parent *Promise, k func(det bool) *Promise) *Promise {
+	return &Promise{
+		detect: &detection{parent: parent, k: k},
+	}
+}
+
+// discardable returns a promise with the choices ks that calls discard once the remaining choices are discarded
+// without being tried, i.e. by a cut, an exception, or the end of the execution.
+func discardable(discard func(context.Context), ks ...func(context.Context) *Promise) *Promise {
+	return &Promise{
+		delayed: ks,
+		discard: discard,
+	}
+}
+
 // Repeat returns a promise that repeats k.
 func Repeat(k func(context.Context) *Promise) *Promise {
 	return &Promise{
@@ -69,17 +103,50 @@ func Catch(recover func(error) *Promise, k func(context.Context) *Promise) *Prom
 // Force enforces the delayed execution and returns the result. (i.e. trampoline)
 func (p *Promise) Force(ctx context.Context) (bool, error) {
 	stack := promiseStack{p}
-	for len(stack) > 0 {
+	ok, err := stack.force(ctx)
+	if ok {
+		// The choicepoints left are never tried.
+		stack.truncate(ctx, 0)
+	}
+	return ok, err
+}
+
+func (p *Promise) child(ctx context.Context) *Promise {
+	q := p.delayed[0](ctx)
+	if !p.repeat {
+		p.delayed, p.delayed[0] = p.delayed[1:], nil
+	}
+	return q
+}
+
+type promiseStack []*Promise
+
+// force runs the execution until it succeeds, fails, or results in error.
+// If it succeeds, the choicepoints are left in the stack so that the execution can be resumed by calling force again.
+func (s *promiseStack) force(ctx context.Context) (bool, error) {
+	for len(*s) > 0 {
 		select {
 		case <-ctx.Done():
+			s.truncate(ctx, 0)
 			return false, errors.New("canceled")
 		default:
-			p := stack.pop()
+			p := s.pop()
+			p.depth = len(*s)
+
+			if d := p.detect; d != nil {
+				*s = append(*s, d.k(s.deterministic(d.parent.depth)))
+				continue
+			}
+
+			if d := p.delimited; d != nil {
+				*s = append(*s, d(*s))
+				continue
+			}
 
 			if len(p.delayed) == 0 {
 				switch {
 				case p.err != nil:
-					if err := stack.recover(p.err); err != nil {
+					if err := s.recover(ctx, p.err); err != nil {
 						return false, err
 					}
 					continue
@@ -92,46 +159,99 @@ func (p *Promise) Force(ctx context.Context) (bool, error) {
 
 			// If cut, we eliminate other possibilities.
 			if p.cutParent != nil {
-				stack.popUntil(p.cutParent)
+				s.cut(ctx, p.cutParent)
 				p.cutParent = nil // we don't have to do this again when we revisit.
 			}
 
 			// Try the child promises from left to right.
 			q := p.child(ctx)
-			stack = append(stack, p, q)
+
+			// If there's no other possibilities, we don't have to revisit p unless it has a recovering function.
+			// This keeps the stack from growing in deterministic executions. (i.e. last call optimization)
+			if len(p.delayed) > 0 || p.recover != nil {
+				*s = append(*s, p)
+			}
+			*s = append(*s, q)
 		}
 	}
 	return false, nil
 }
 
-func (p *Promise) child(ctx context.Context) *Promise {
-	q := p.delayed[0](ctx)
-	if !p.repeat {
-		p.delayed, p.delayed[0] = p.delayed[1:], nil
-	}
-	return q
-}
-
-type promiseStack []*Promise
-
 func (s *promiseStack) pop() *Promise {
 	var p *Promise
 	p, *s, (*s)[len(*s)-1] = (*s)[len(*s)-1], (*s)[:len(*s)-1], nil
 	return p
 }
 
-func (s *promiseStack) popUntil(p *Promise) {
-	for len(*s) > 0 {
-		if pop := s.pop(); pop == p {
+// drop removes the promise on the top without trying the remaining choices.
+func (s *promiseStack) drop(ctx context.Context) *Promise {
+	p := s.pop()
+	if p.discard != nil {
+		p.discard(ctx)
+	}
+	return p
+}
+
+// truncate removes the promise at depth and everything above it.
+// Since promises above depth are descendants of the promise at depth, this works even if the promise at depth was
+// already removed from the stack.
+func (s *promiseStack) truncate(ctx context.Context, depth int) {
+	for len(*s) > depth {
+		_ = s.drop(ctx)
+	}
+}
+
+// cut removes the promises above parent.
+// A cut doesn't go beyond an active delimiter since a cut in a continuation is local to the continuation.
+func (s *promiseStack) cut(ctx context.Context, parent *Promise) {
+	depth := parent.depth
+	for i := len(*s) - 1; i >= depth; i-- {
+		if f := (*s)[i].delimiter; f != nil && f.active {
+			depth = i + 1
 			break
 		}
 	}
+	s.truncate(ctx, depth)
+}
+
+// delimiters returns the active delimiters from the innermost to the outermost.
+func (s promiseStack) delimiters() []*resetFrame {
+	var fs []*resetFrame
+	for i := len(s) - 1; i >= 0; i-- {
+		if f := s[i].delimiter; f != nil && f.active {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// innermost returns the innermost active delimiter or nil if there's none.
+func (s promiseStack) innermost() *resetFrame {
+	for i := len(s) - 1; i >= 0; i-- {
+		if f := s[i].delimiter; f != nil && f.active {
+			return f
+		}
+	}
+	return nil
+}
+
+// deterministic checks if there's no choicepoints above the promise at depth.
+func (s promiseStack) deterministic(depth int) bool {
+	if depth >= len(s) {
+		return true
+	}
+	for _, p := range s[depth+1:] {
+		if len(p.delayed) > 0 {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *promiseStack) recover(err error) error {
+func (s *promiseStack) recover(ctx context.Context, err error) error {
 	// look for an ancestor promise with a recovering function that is applicable to the error.
 	for len(*s) > 0 {
-		pop := s.pop()
+		pop := s.drop(ctx)
 		if pop.recover == nil {
 			continue
 		}
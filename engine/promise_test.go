@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -73,3 +74,65 @@ func TestPromise_Force(t *testing.T) {
 		assert.Equal(t, 10, count)
 	})
 }
+
+func TestDiscardable(t *testing.T) {
+	newPromise := func(discarded *int, k func(context.Context) *Promise) *Promise {
+		return discardable(func(context.Context) {
+			*discarded++
+		}, k, func(context.Context) *Promise {
+			return Bool(false)
+		})
+	}
+
+	t.Run("exhausted", func(t *testing.T) {
+		var discarded int
+		ok, err := newPromise(&discarded, func(context.Context) *Promise {
+			return Bool(false)
+		}).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 0, discarded)
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		var discarded int
+		var p *Promise
+		p = Delay(func(context.Context) *Promise {
+			return newPromise(&discarded, func(context.Context) *Promise {
+				return Cut(p, func(context.Context) *Promise {
+					assert.Equal(t, 1, discarded)
+					return Bool(false)
+				})
+			})
+		})
+		ok, err := p.Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 1, discarded)
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		var discarded int
+		ok, err := Catch(func(err error) *Promise {
+			assert.Equal(t, 1, discarded)
+			return Bool(true)
+		}, func(context.Context) *Promise {
+			return newPromise(&discarded, func(context.Context) *Promise {
+				return Error(errors.New("failed"))
+			})
+		}).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, discarded)
+	})
+
+	t.Run("end of execution", func(t *testing.T) {
+		var discarded int
+		ok, err := newPromise(&discarded, func(context.Context) *Promise {
+			return Bool(true)
+		}).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, discarded)
+	})
+}
@@ -154,7 +154,7 @@ func TestStream_Unify(t *testing.T) {
 	t.Run("stream", func(t *testing.T) {
 		t.Run("same", func(t *testing.T) {
 			var s Stream
-			env := NewEnv().Bind("Foo", Atom("foo"))
+			env := NewEnv().Bind(testVar("Foo"), Atom("foo"))
 			e, ok := s.Unify(&s, false, env)
 			assert.True(t, ok)
 			assert.Equal(t, env, e)
@@ -162,7 +162,7 @@ func TestStream_Unify(t *testing.T) {
 
 		t.Run("different", func(t *testing.T) {
 			var s1, s2 Stream
-			env := NewEnv().Bind("Foo", Atom("foo"))
+			env := NewEnv().Bind(testVar("Foo"), Atom("foo"))
 			e, ok := s1.Unify(&s2, false, env)
 			assert.False(t, ok)
 			assert.Equal(t, env, e)
@@ -172,25 +172,25 @@ func TestStream_Unify(t *testing.T) {
 	t.Run("variable", func(t *testing.T) {
 		t.Run("free", func(t *testing.T) {
 			var s Stream
-			env := NewEnv().Bind("Foo", Atom("foo"))
-			env, ok := s.Unify(Variable("Bar"), false, env)
+			env := NewEnv().Bind(testVar("Foo"), Atom("foo"))
+			env, ok := s.Unify(testVar("Bar"), false, env)
 			assert.True(t, ok)
-			assert.Equal(t, &s, env.Resolve(Variable("Bar")))
+			assert.Equal(t, &s, env.Resolve(testVar("Bar")))
 		})
 
 		t.Run("bound", func(t *testing.T) {
 			t.Run("same", func(t *testing.T) {
 				var s Stream
-				env := NewEnv().Bind("Foo", &s)
-				env, ok := s.Unify(Variable("Foo"), false, env)
+				env := NewEnv().Bind(testVar("Foo"), &s)
+				env, ok := s.Unify(testVar("Foo"), false, env)
 				assert.True(t, ok)
-				assert.Equal(t, &s, env.Resolve(Variable("Foo")))
+				assert.Equal(t, &s, env.Resolve(testVar("Foo")))
 			})
 
 			t.Run("different", func(t *testing.T) {
 				var s Stream
-				env := NewEnv().Bind("Foo", Atom("foo"))
-				e, ok := s.Unify(Variable("Foo"), false, env)
+				env := NewEnv().Bind(testVar("Foo"), Atom("foo"))
+				e, ok := s.Unify(testVar("Foo"), false, env)
 				assert.False(t, ok)
 				assert.Equal(t, e, env)
 			})
@@ -50,10 +50,14 @@ func Rulify(t Term, env *Env) Term {
 }
 
 type writeTermOptions struct {
-	quoted     bool
-	ops        operators
-	numberVars bool
-	priority   int
+	quoted        bool
+	ops           operators
+	numberVars    bool
+	priority      int
+	maxDepth      int
+	depth         int
+	variableNames map[Variable]Atom
+	portray       func(Term, *Env) (string, bool)
 }
 
 var defaultWriteTermOptions = writeTermOptions{
@@ -105,6 +109,41 @@ func WithPriority(p int) WriteOption {
 	}
 }
 
+// WithMaxDepth sets the depth of compounds to which the term is written. The deeper compounds and the rest of
+// longer lists are written as '...'. If n is 0, the whole term is written.
+func WithMaxDepth(n int) WriteOption {
+	return func(options *writeTermOptions) {
+		options.maxDepth = n
+	}
+}
+
+func withDepth(d int) WriteOption {
+	return func(options *writeTermOptions) {
+		options.depth = d
+	}
+}
+
+// WithVariableNames sets the names of variables which are written instead of _123.
+func WithVariableNames(names map[Variable]Atom) WriteOption {
+	return func(options *writeTermOptions) {
+		options.variableNames = names
+	}
+}
+
+// WithPortray sets if compounds are written by the user-defined portray/1 when it succeeds.
+func (state *State) WithPortray(b bool) WriteOption {
+	if !b {
+		return withPortray(nil)
+	}
+	return withPortray(state.portray)
+}
+
+func withPortray(portray func(Term, *Env) (string, bool)) WriteOption {
+	return func(options *writeTermOptions) {
+		options.portray = portray
+	}
+}
+
 // Write outputs one of the external representations of the term.
 func Write(w io.Writer, t Term, env *Env, opts ...WriteOption) error {
 	var (
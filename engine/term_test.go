@@ -13,7 +13,7 @@ func TestContains(t *testing.T) {
 	var env *Env
 	assert.True(t, Contains(Atom("a"), Atom("a"), env))
 	assert.False(t, Contains(NewVariable(), Atom("a"), env))
-	v := Variable("V")
+	v := testVar("V")
 	env = env.Bind(v, Atom("a"))
 	assert.True(t, Contains(v, Atom("a"), env))
 	assert.True(t, Contains(&Compound{Functor: "a"}, Atom("a"), env))
@@ -26,7 +26,7 @@ func TestRulify(t *testing.T) {
 		Functor: ":-",
 		Args:    []Term{Atom("a"), Atom("true")},
 	}, Rulify(Atom("a"), nil))
-	v := Variable("V")
+	v := testVar("V")
 	env := NewEnv().
 		Bind(v, Atom("a"))
 	assert.Equal(t, &Compound{
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"context"
+	"errors"
+)
+
+// Port is a point in the execution of a goal.
+// A goal is seen as a box with 4 ports (call, exit, redo, and fail) plus exception.
+type Port int8
+
+// Ports.
+const (
+	PortCall Port = iota
+	PortExit
+	PortRedo
+	PortFail
+	PortException
+	_portLen
+)
+
+func (p Port) String() string {
+	return [_portLen]string{
+		PortCall:      "call",
+		PortExit:      "exit",
+		PortRedo:      "redo",
+		PortFail:      "fail",
+		PortException: "exception",
+	}[p]
+}
+
+// Event is a goal passing through a port.
+type Event struct {
+	Port       Port
+	Invocation int64 // the serial number of the goal. It doesn't change on redo or retry.
+	Parent     int64 // the invocation of the goal which called this goal, or 0 for a goal called from Go.
+	Depth      int   // the depth of the goal in the proof tree.
+	PI         ProcedureIndicator
+	Goal       Term
+	Env        *Env
+
+	// Deterministic is true if the goal exited without leaving choicepoints. Only for exit port.
+	Deterministic bool
+
+	// Exception is the thrown term. Only for exception port.
+	Exception Term
+}
+
+// Tracer receives events from the VM. It's triggered for every goal including the ones defined in Go.
+type Tracer interface {
+	Trace(e *Event)
+}
+
+// TracerFunc is a function that works as Tracer.
+type TracerFunc func(e *Event)
+
+// Trace calls f(e).
+func (f TracerFunc) Trace(e *Event) {
+	f(e)
+}
+
+type traceState struct {
+	invocation int64
+	current    int64 // the invocation of the goal we're in.
+	depth      int
+}
+
+// traced checks if the procedure goes through the ports.
+func (vm *VM) traced(pi ProcedureIndicator) bool {
+	return vm.Tracer != nil || vm.profiler != nil || (vm.debugger.enabled && debuggable(pi))
+}
+
+// traceCall calls the procedure in a box so that we can observe the ports.
+func (vm *VM) traceCall(pi ProcedureIndicator, p procedure, args []Term, k func(*Env) *Promise, env *Env) *Promise {
+	s := &vm.traceState
+	s.invocation++
+	var (
+		invocation = s.invocation
+		parent     = s.current
+		depth      = s.depth + 1
+		inside     bool
+		box, inner *Promise
+	)
+
+	port := func(port Port, env *Env, det bool, ex Term) DebugAction {
+		if port == PortCall || port == PortRedo {
+			inside = true
+			s.current, s.depth = invocation, depth
+		} else {
+			inside = false
+			s.current, s.depth = parent, depth-1
+		}
+		goal, _ := pi.Apply(args...)
+		e := Event{
+			Port:          port,
+			Invocation:    invocation,
+			Parent:        parent,
+			Depth:         depth,
+			PI:            pi,
+			Goal:          goal,
+			Env:           env,
+			Deterministic: det,
+			Exception:     ex,
+		}
+		if vm.Tracer != nil {
+			vm.Tracer.Trace(&e)
+		}
+		if vm.profiler != nil {
+			vm.profiler.Trace(&e)
+		}
+		return vm.debugPort(&e)
+	}
+
+	var enter, call, fail func(context.Context) *Promise
+	retry := func(context.Context) *Promise {
+		return Cut(box, enter)
+	}
+	fail = func(context.Context) *Promise {
+		switch port(PortFail, env, false, nil) {
+		case DebugRetry:
+			return retry(context.Background())
+		case DebugAbort:
+			return Error(ErrAborted)
+		default:
+			return Bool(false)
+		}
+	}
+	exit := func(env *Env) *Promise {
+		return detect(inner, func(det bool) *Promise {
+			switch port(PortExit, env, det, nil) {
+			case DebugFail:
+				return Cut(box, fail)
+			case DebugRetry:
+				return retry(context.Background())
+			case DebugAbort:
+				return Error(ErrAborted)
+			}
+			if det {
+				// Since there's no way to redo, we leave the box for good.
+				return Cut(inner, func(context.Context) *Promise {
+					return k(env)
+				})
+			}
+			return Delay(func(context.Context) *Promise {
+				return k(env)
+			}, func(context.Context) *Promise {
+				switch port(PortRedo, env, false, nil) {
+				case DebugFail:
+					return Cut(box, fail)
+				case DebugRetry:
+					return retry(context.Background())
+				case DebugAbort:
+					return Error(ErrAborted)
+				default:
+					return Bool(false)
+				}
+			})
+		})
+	}
+	call = func(ctx context.Context) *Promise {
+		switch port(PortCall, env, false, nil) {
+		case DebugFail:
+			return fail(ctx)
+		case DebugAbort:
+			return Error(ErrAborted)
+		}
+		inner = Delay(func(context.Context) *Promise {
+			return p.Call(vm, args, exit, env)
+		}, fail)
+		return inner
+	}
+	recover := func(err error) *Promise {
+		var e *Exception
+		if !inside || !errors.As(err, &e) {
+			return nil
+		}
+		switch port(PortException, env, false, e.Term) {
+		case DebugFail:
+			return fail(context.Background())
+		case DebugRetry:
+			return Delay(enter)
+		case DebugAbort:
+			return Error(ErrAborted)
+		default:
+			return nil
+		}
+	}
+	enter = func(context.Context) *Promise {
+		box = Catch(recover, call)
+		return box
+	}
+	return enter(context.Background())
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVM_Tracer(t *testing.T) {
+	newState := func(t *testing.T) (*State, *[]Event) {
+		state, _ := newDebugState(t)
+		state.Register1("between_a_b", func(x Term, k func(*Env) *Promise, env *Env) *Promise {
+			return Delay(func(context.Context) *Promise {
+				return Unify(x, Atom("a"), k, env)
+			}, func(context.Context) *Promise {
+				return Unify(x, Atom("b"), k, env)
+			})
+		})
+		var events []Event
+		state.Tracer = TracerFunc(func(e *Event) {
+			e.Goal = e.Env.Simplify(e.Goal)
+			e.Env = nil
+			events = append(events, *e)
+		})
+		return state, &events
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		state, events := newState(t)
+		ok, err := state.Call(&Compound{Functor: "foo", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		foo := ProcedureIndicator{Name: "foo", Arity: 1}
+		bar := ProcedureIndicator{Name: "bar", Arity: 1}
+		assert.Equal(t, []Event{
+			{Port: PortCall, Invocation: 1, Parent: 0, Depth: 1, PI: foo, Goal: &Compound{Functor: "foo", Args: []Term{Atom("b")}}},
+			{Port: PortCall, Invocation: 2, Parent: 1, Depth: 2, PI: bar, Goal: &Compound{Functor: "bar", Args: []Term{Atom("b")}}},
+			{Port: PortExit, Invocation: 2, Parent: 1, Depth: 2, PI: bar, Goal: &Compound{Functor: "bar", Args: []Term{Atom("b")}}, Deterministic: true},
+			{Port: PortExit, Invocation: 1, Parent: 0, Depth: 1, PI: foo, Goal: &Compound{Functor: "foo", Args: []Term{Atom("b")}}, Deterministic: true},
+		}, *events)
+	})
+
+	t.Run("nondeterministic", func(t *testing.T) {
+		state, events := newState(t)
+		ok, err := state.Call(&Compound{Functor: "foo", Args: []Term{NewVariable()}}, Failure, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		var ports []Port
+		var dets []bool
+		for _, e := range *events {
+			ports = append(ports, e.Port)
+			if e.Port == PortExit {
+				dets = append(dets, e.Deterministic)
+			}
+		}
+		assert.Equal(t, []Port{
+			PortCall, PortCall, PortExit, PortExit,
+			PortRedo, PortRedo, PortExit, PortExit,
+		}, ports)
+		assert.Equal(t, []bool{false, false, true, true}, dets)
+	})
+
+	t.Run("native", func(t *testing.T) {
+		state, events := newState(t)
+		ok, err := state.Call(&Compound{Functor: "between_a_b", Args: []Term{Atom("b")}}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		pi := ProcedureIndicator{Name: "between_a_b", Arity: 1}
+		assert.Equal(t, []Event{
+			{Port: PortCall, Invocation: 1, Depth: 1, PI: pi, Goal: &Compound{Functor: "between_a_b", Args: []Term{Atom("b")}}},
+			{Port: PortExit, Invocation: 1, Depth: 1, PI: pi, Goal: &Compound{Functor: "between_a_b", Args: []Term{Atom("b")}}, Deterministic: true},
+		}, *events)
+	})
+
+	t.Run("exception", func(t *testing.T) {
+		state, events := newState(t)
+		_, err := state.Call(Atom("baz"), Success, nil).Force(context.Background())
+		assert.Equal(t, &Exception{Term: Atom("oops")}, err)
+
+		baz := ProcedureIndicator{Name: "baz", Arity: 0}
+		throw := ProcedureIndicator{Name: "throw", Arity: 1}
+		assert.Equal(t, []Event{
+			{Port: PortCall, Invocation: 1, Depth: 1, PI: baz, Goal: Atom("baz")},
+			{Port: PortCall, Invocation: 2, Parent: 1, Depth: 2, PI: throw, Goal: &Compound{Functor: "throw", Args: []Term{Atom("oops")}}},
+			{Port: PortException, Invocation: 2, Parent: 1, Depth: 2, PI: throw, Goal: &Compound{Functor: "throw", Args: []Term{Atom("oops")}}, Exception: Atom("oops")},
+			{Port: PortException, Invocation: 1, Depth: 1, PI: baz, Goal: Atom("baz"), Exception: Atom("oops")},
+		}, *events)
+	})
+}
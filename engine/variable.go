@@ -2,27 +2,38 @@ package engine
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
 	"sync/atomic"
 )
 
 // Variable is a prolog variable.
-type Variable string
+// It's identified by an integer and has no name. Names only exist in the source text, see ParsedVariable.
+//
+// Variable used to be a string of its name. A conversion from a string like Variable("X") doesn't compile anymore.
+// Use NewVariable to create a variable and ParsedVariable to find the names in the source text.
+type Variable int64
 
-var varCounter uint64
+var varCounter int64
 
-// NewVariable creates a new generated variable.
+// NewVariable creates a new variable.
 func NewVariable() Variable {
-	atomic.AddUint64(&varCounter, 1)
-	return Variable(fmt.Sprintf("_%d", varCounter))
+	return Variable(atomic.AddInt64(&varCounter, 1))
 }
 
-var generatedPattern = regexp.MustCompile(`\A_\d+\z`)
+// lastVariable returns the variable created last.
+func lastVariable() Variable {
+	return Variable(atomic.LoadInt64(&varCounter))
+}
 
 // Generated checks if the variable is generated.
+//
+// Deprecated: Every variable is generated by NewVariable now. It always returns true.
 func (v Variable) Generated() bool {
-	return generatedPattern.MatchString(string(v))
+	return true
+}
+
+// String returns the text representation of the variable, e.g. _123.
+func (v Variable) String() string {
+	return fmt.Sprintf("_%d", int64(v))
 }
 
 // Unify unifies the variable with t.
@@ -46,7 +57,15 @@ func (v Variable) Unify(t Term, occursCheck bool, env *Env) (*Env, bool) {
 func (v Variable) Unparse(emit func(token Token), env *Env, opts ...WriteOption) {
 	switch v := env.Resolve(v).(type) {
 	case Variable:
-		emit(Token{Kind: TokenVariable, Val: string(v)})
+		wto := defaultWriteTermOptions
+		for _, o := range opts {
+			o(&wto)
+		}
+		if n, ok := wto.variableNames[v]; ok {
+			emit(Token{Kind: TokenVariable, Val: string(n)})
+			return
+		}
+		emit(Token{Kind: TokenVariable, Val: v.String()})
 	default:
 		v.Unparse(emit, env, opts...)
 	}
@@ -58,7 +77,14 @@ func (v Variable) Compare(t Term, env *Env) int64 {
 	case Variable:
 		switch t := env.Resolve(t).(type) {
 		case Variable:
-			return int64(strings.Compare(string(v), string(t)))
+			switch {
+			case v < t:
+				return -1
+			case v > t:
+				return 1
+			default:
+				return 0
+			}
 		default:
 			return -1
 		}
@@ -8,8 +8,25 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var testVars = map[string]Variable{}
+
+// testVar returns the same variable for the same name so that tests can refer to variables by name.
+// It never collides with variables from NewVariable since it counts down from -1.
+func testVar(name string) Variable {
+	v, ok := testVars[name]
+	if !ok {
+		v = Variable(-1 - len(testVars))
+		testVars[name] = v
+	}
+	return v
+}
+
+func TestVariable_Generated(t *testing.T) {
+	assert.True(t, NewVariable().Generated())
+}
+
 func TestVariable_Unify(t *testing.T) {
-	v1, v2 := Variable("V1"), Variable("V2")
+	v1, v2 := testVar("V1"), testVar("V2")
 	env, ok := v1.Unify(v2, false, nil)
 	assert.True(t, ok)
 	env, ok = v1.Unify(Atom("foo"), false, env)
@@ -17,7 +34,7 @@ func TestVariable_Unify(t *testing.T) {
 	assert.Equal(t, Atom("foo"), env.Resolve(v1))
 	assert.Equal(t, Atom("foo"), env.Resolve(v2))
 
-	v3, v4 := Variable("V3"), Variable("V4")
+	v3, v4 := testVar("V3"), testVar("V4")
 	env, ok = v3.Unify(v4, false, env)
 	assert.True(t, ok)
 	env, ok = v4.Unify(Atom("bar"), false, env)
@@ -27,18 +44,18 @@ func TestVariable_Unify(t *testing.T) {
 }
 
 func TestVariable_Unparse(t *testing.T) {
-	t.Run("named", func(t *testing.T) {
-		v := Variable("X")
+	t.Run("free", func(t *testing.T) {
+		v := Variable(123)
 		var tokens []Token
 		v.Unparse(func(token Token) {
 			tokens = append(tokens, token)
 		}, nil)
 		assert.Equal(t, []Token{
-			{Kind: TokenVariable, Val: "X"},
+			{Kind: TokenVariable, Val: "_123"},
 		}, tokens)
 	})
 
-	t.Run("unnamed", func(t *testing.T) {
+	t.Run("new", func(t *testing.T) {
 		v := NewVariable()
 		var tokens []Token
 		v.Unparse(func(token Token) {
@@ -54,7 +71,7 @@ func TestVariable_Unparse(t *testing.T) {
 		m.On("Unparse", mock.Anything, mock.Anything, mock.Anything).Return().Once()
 		defer m.AssertExpectations(t)
 
-		v := Variable("X")
+		v := testVar("X")
 		v.Unparse(func(token Token) {}, NewEnv().Bind(v, &m))
 	})
 }
@@ -64,10 +81,10 @@ func TestVariable_Compare(t *testing.T) {
 		var m mockTerm
 		defer m.AssertExpectations(t)
 
-		assert.Equal(t, int64(-1), Variable("Y").Compare(&m, nil))
-		assert.Equal(t, int64(-1), Variable("X").Compare(Variable("Y"), nil))
-		assert.Equal(t, int64(0), Variable("X").Compare(Variable("X"), nil))
-		assert.Equal(t, int64(1), Variable("Y").Compare(Variable("X"), nil))
+		assert.Equal(t, int64(-1), Variable(2).Compare(&m, nil))
+		assert.Equal(t, int64(-1), Variable(1).Compare(Variable(2), nil))
+		assert.Equal(t, int64(0), Variable(1).Compare(Variable(1), nil))
+		assert.Equal(t, int64(1), Variable(2).Compare(Variable(1), nil))
 	})
 
 	t.Run("bound", func(t *testing.T) {
@@ -75,7 +92,7 @@ func TestVariable_Compare(t *testing.T) {
 		m.On("Compare", mock.Anything, mock.Anything).Return(int64(123))
 		defer m.AssertExpectations(t)
 
-		env := NewEnv().Bind("X", &m)
-		assert.Equal(t, int64(123), Variable("X").Compare(Variable("Y"), env))
+		env := NewEnv().Bind(testVar("X"), &m)
+		assert.Equal(t, int64(123), testVar("X").Compare(testVar("Y"), env))
 	})
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -11,7 +12,7 @@ type bytecode []instruction
 
 type instruction struct {
 	opcode  opcode
-	operand byte
+	operand uint32
 }
 
 type opcode byte
@@ -20,14 +21,24 @@ const (
 	opEnter opcode = iota
 	opCall
 	opExit
-	opConst
-	opVar
-	opFunctor
+	opGetConst
+	opPutConst
+	opGetVar
+	opPutVar
+	opGetFunctor
+	opPutFunctor
 	opPop
 
 	opCut
 
-	_opLen
+	// control constructs
+	opTry
+	opJump
+	opIf
+	opThen
+	opSoftThen
+	opCutLocal
+	opFail
 )
 
 var (
@@ -45,23 +56,27 @@ var (
 // VM is the core of a Prolog interpreter. The zero value for VM is a valid VM without any builtin predicates.
 type VM struct {
 
-	// OnCall is a callback that is triggered when the VM reaches to the predicate.
-	OnCall func(pi ProcedureIndicator, args []Term, env *Env)
-
-	// OnExit is a callback that is triggered when the predicate succeeds and the VM continues.
-	OnExit func(pi ProcedureIndicator, args []Term, env *Env)
-
-	// OnFail is a callback that is triggered when the predicate fails and the VM backtracks.
-	OnFail func(pi ProcedureIndicator, args []Term, env *Env)
-
-	// OnRedo is a callback that is triggered when the VM retries the predicate as a result of backtrack.
-	OnRedo func(pi ProcedureIndicator, args []Term, env *Env)
+	// Tracer receives the events of goals passing through ports if non-nil.
+	Tracer Tracer
 
 	// OnUnknown is a callback that is triggered when the VM reaches to an unknown predicate and also current_prolog_flag(unknown, warning).
 	OnUnknown func(pi ProcedureIndicator, args []Term, env *Env)
 
+	// OnDebug is a callback that is triggered when the debugger shows a port.
+	// If the port is leashed, the debugger takes the returned action.
+	OnDebug func(f *DebugFrame) DebugAction
+
+	// ResidualGoals returns the goals which constrain the variables but aren't expressed as their bindings, e.g.
+	// delayed goals. The toplevel shows them after the bindings of an answer.
+	ResidualGoals func(vars []Variable, env *Env) []Term
+
 	procedures map[ProcedureIndicator]procedure
+	loaded     []string // the absolute paths of the loaded source files in order.
 	unknown    unknownAction
+	debugger   debugger
+	profiler   *Profiler
+	coverage   coverage
+	traceState traceState
 }
 
 // Register0 registers a predicate of arity 0.
@@ -136,6 +151,23 @@ func (vm *VM) Register8(name string, p func(Term, Term, Term, Term, Term, Term,
 	vm.procedures[ProcedureIndicator{Name: Atom(name), Arity: 8}] = predicate8(p)
 }
 
+// RegisterN registers a predicate of arbitrary arity. The arguments are given to p as a slice.
+// Together with call/N for any N, this lifts the limit of Register0..Register8.
+func (vm *VM) RegisterN(name string, arity int, p func([]Term, func(*Env) *Promise, *Env) *Promise) {
+	if vm.procedures == nil {
+		vm.procedures = map[ProcedureIndicator]procedure{}
+	}
+	vm.procedures[ProcedureIndicator{Name: Atom(name), Arity: Integer(arity)}] = predicateN{arity: arity, p: p}
+}
+
+// RegisterNondet registers a nondeterministic predicate of arity whose solutions are lazily produced by f.
+func (vm *VM) RegisterNondet(name string, arity int, f NondetFunc) {
+	if vm.procedures == nil {
+		vm.procedures = map[ProcedureIndicator]procedure{}
+	}
+	vm.procedures[ProcedureIndicator{Name: Atom(name), Arity: Integer(arity)}] = f
+}
+
 type unknownAction int
 
 const (
@@ -164,6 +196,17 @@ func (vm *VM) Arrive(pi ProcedureIndicator, args []Term, k func(*Env) *Promise,
 	}
 
 	p, ok := vm.procedures[pi]
+	if !ok && pi.Name == "call" && pi.Arity > 1 {
+		// call/N for any N is call/1 of the goal made of the closure and the additional arguments.
+		call := ProcedureIndicator{Name: "call", Arity: 1}
+		if _, ok := vm.procedures[call]; ok {
+			goal, err := closureGoal(args[0], args[1:], env)
+			if err != nil {
+				return Error(err)
+			}
+			return vm.Arrive(call, []Term{goal}, k, env)
+		}
+	}
 	if !ok {
 		switch vm.unknown {
 		case unknownError:
@@ -178,37 +221,214 @@ func (vm *VM) Arrive(pi ProcedureIndicator, args []Term, k func(*Env) *Promise,
 		}
 	}
 
+	if vm.traced(pi) {
+		return Delay(func(context.Context) *Promise {
+			return vm.traceCall(pi, p, args, k, env)
+		})
+	}
+
 	return Delay(func(context.Context) *Promise {
 		return p.Call(vm, args, k, env)
 	})
 }
 
+// Atoms returns the atoms known to the VM, i.e. the names of the procedures and the atoms in their clauses, in order.
+func (vm *VM) Atoms() []Atom {
+	set := map[Atom]struct{}{}
+	for pi, p := range vm.procedures {
+		set[pi.Name] = struct{}{}
+		var cs clauses
+		switch p := p.(type) {
+		case clauses:
+			cs = p
+		case static:
+			cs = p.clauses
+		case builtin:
+			cs = p.clauses
+		}
+		for _, c := range cs {
+			for _, t := range c.xrTable {
+				if a, ok := t.(Atom); ok {
+					set[a] = struct{}{}
+				}
+			}
+			for _, pi := range c.piTable {
+				set[pi.Name] = struct{}{}
+			}
+		}
+	}
+
+	atoms := make([]Atom, 0, len(set))
+	for a := range set {
+		atoms = append(atoms, a)
+	}
+	sort.Slice(atoms, func(i, j int) bool {
+		return atoms[i] < atoms[j]
+	})
+	return atoms
+}
+
+// AddSourceFile records path as the absolute path of a loaded source file unless it's already recorded.
+func (vm *VM) AddSourceFile(path string) {
+	if !vm.HasSourceFile(path) {
+		vm.loaded = append(vm.loaded, path)
+	}
+}
+
+// HasSourceFile checks if path is recorded as the absolute path of a loaded source file.
+func (vm *VM) HasSourceFile(path string) bool {
+	for _, l := range vm.loaded {
+		if l == path {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadedSourceFile succeeds iff file is the absolute path of a loaded source file.
+func (vm *VM) LoadedSourceFile(file Term, k func(*Env) *Promise, env *Env) *Promise {
+	switch f := env.Resolve(file).(type) {
+	case Variable, Atom:
+		break
+	default:
+		return Error(TypeErrorAtom(f))
+	}
+
+	ks := make([]func(context.Context) *Promise, len(vm.loaded))
+	for i, l := range vm.loaded {
+		l := Atom(l)
+		ks[i] = func(context.Context) *Promise {
+			return Unify(file, l, k, env)
+		}
+	}
+	return Delay(ks...)
+}
+
+// SourceFile succeeds iff pred is the most general head of a user-defined procedure and file is the file which defines
+// the procedure.
+func (vm *VM) SourceFile(pred, file Term, k func(*Env) *Promise, env *Env) *Promise {
+	pis := make([]ProcedureIndicator, 0, len(vm.procedures))
+	for pi := range vm.procedures {
+		pis = append(pis, pi)
+	}
+	sort.Slice(pis, func(i, j int) bool {
+		return pis[i].String() < pis[j].String()
+	})
+
+	var ks []func(context.Context) *Promise
+	for _, pi := range pis {
+		var cs clauses
+		switch p := vm.procedures[pi].(type) {
+		case clauses:
+			cs = p
+		case static:
+			cs = p.clauses
+		default:
+			continue
+		}
+		if len(cs) == 0 || cs[0].pos.File == "" {
+			continue
+		}
+
+		args := make([]Term, pi.Arity)
+		for i := range args {
+			args[i] = NewVariable()
+		}
+		pattern := Compound{Args: []Term{pred, file}}
+		c := Compound{Args: []Term{pi.Name.Apply(args...), Atom(cs[0].pos.File)}}
+		ks = append(ks, func(context.Context) *Promise {
+			return Unify(&pattern, &c, k, env)
+		})
+	}
+	return Delay(ks...)
+}
+
 type registers struct {
-	pc           bytecode
-	xr           []Term
-	vars         []Variable
-	cont         func(*Env) *Promise
-	args, astack Term
+	pc   bytecode
+	xr   []Term
+	pi   []ProcedureIndicator
+	vars []Term
+	cont func(*Env) *Promise
+
+	// args holds the arguments to match in the head or the arguments to pass in the body.
+	args   []Term
+	astack []frame
 
-	pi        []ProcedureIndicator
 	env       *Env
 	cutParent *Promise
+
+	// base is the environment when the clause was entered and last is the variable created then.
+	base *Env
+	last Variable
+
+	// inputs holds the compounds given to the clause. They were made before last.
+	inputs []*Compound
+
+	// ites holds the if-then-else constructs whose conditions are being executed.
+	ites []*ite
+}
+
+// ite is an execution of if-then-else, soft-cut, or negation.
+type ite struct {
+	// choice is the choicepoint of the else branch.
+	choice *Promise
+
+	// cond is the cut barrier for the cuts local to the condition.
+	cond *Promise
+
+	// succeeded is true if the condition succeeded once.
+	succeeded bool
+}
+
+// frame is a saved argument register while the VM is working on the arguments of a compound.
+type frame struct {
+	args     []Term
+	compound *Compound // non-nil if the VM is constructing the compound.
 }
 
 func (vm *VM) exec(r registers) *Promise {
-	jumpTable := [_opLen]func(r *registers) *Promise{
-		opConst:   vm.execConst,
-		opVar:     vm.execVar,
-		opFunctor: vm.execFunctor,
-		opPop:     vm.execPop,
-		opEnter:   vm.execEnter,
-		opCall:    vm.execCall,
-		opExit:    vm.execExit,
-		opCut:     vm.execCut,
-	}
 	for len(r.pc) != 0 {
-		op := jumpTable[r.pc[0].opcode]
-		p := op(&r)
+		var p *Promise
+		switch r.pc[0].opcode {
+		case opGetConst:
+			p = vm.execGetConst(&r)
+		case opPutConst:
+			p = vm.execPutConst(&r)
+		case opGetVar:
+			p = vm.execGetVar(&r)
+		case opPutVar:
+			p = vm.execPutVar(&r)
+		case opGetFunctor:
+			p = vm.execGetFunctor(&r)
+		case opPutFunctor:
+			p = vm.execPutFunctor(&r)
+		case opPop:
+			p = vm.execPop(&r)
+		case opEnter:
+			p = vm.execEnter(&r)
+		case opCall:
+			p = vm.execCall(&r)
+		case opExit:
+			p = vm.execExit(&r)
+		case opCut:
+			p = vm.execCut(&r)
+		case opTry:
+			p = vm.execTry(&r)
+		case opJump:
+			p = vm.execJump(&r)
+		case opIf:
+			p = vm.execIf(&r)
+		case opThen:
+			p = vm.execThen(&r)
+		case opSoftThen:
+			p = vm.execSoftThen(&r)
+		case opCutLocal:
+			p = vm.execCutLocal(&r)
+		case opFail:
+			p = vm.execFail(&r)
+		default:
+			return Error(fmt.Errorf("unknown opcode: %d", r.pc[0].opcode))
+		}
 		if p != nil {
 			return p
 		}
@@ -216,173 +436,231 @@ func (vm *VM) exec(r registers) *Promise {
 	return Error(errors.New("non-exit end of bytecode"))
 }
 
-func (*VM) execConst(r *registers) *Promise {
+func (*VM) execGetConst(r *registers) *Promise {
 	x := r.xr[r.pc[0].operand]
-	arest := NewVariable()
-	cons := Compound{
-		Functor: ".",
-		Args:    []Term{x, arest},
-	}
 	var ok bool
-	r.env, ok = r.args.Unify(&cons, false, r.env)
+	r.env, ok = x.Unify(r.args[0], false, r.env)
 	if !ok {
 		return Bool(false)
 	}
 	r.pc = r.pc[1:]
-	r.args = arest
+	r.args = r.args[1:]
+	return nil
+}
+
+func (*VM) execPutConst(r *registers) *Promise {
+	x := r.xr[r.pc[0].operand]
+	r.pc = r.pc[1:]
+	r.args = append(r.args, x)
 	return nil
 }
 
-func (*VM) execVar(r *registers) *Promise {
-	v := r.vars[r.pc[0].operand]
-	arest := NewVariable()
-	cons := Compound{
-		Functor: ".",
-		Args:    []Term{v, arest},
+func (*VM) execGetVar(r *registers) *Promise {
+	v := &r.vars[r.pc[0].operand]
+	if *v == nil {
+		// The first occurrence. We don't need to unify but simply remember the argument.
+		*v = r.args[0]
+		if c, ok := (*v).(*Compound); ok {
+			r.inputs = append(r.inputs, c)
+		}
+	} else {
+		var ok bool
+		r.env, ok = (*v).Unify(r.args[0], false, r.env)
+		if !ok {
+			return Bool(false)
+		}
 	}
-	var ok bool
-	r.env, ok = cons.Unify(r.args, false, r.env)
-	if !ok {
-		return Bool(false)
+	r.pc = r.pc[1:]
+	r.args = r.args[1:]
+	return nil
+}
+
+func (*VM) execPutVar(r *registers) *Promise {
+	v := &r.vars[r.pc[0].operand]
+	if *v == nil {
+		*v = NewVariable()
 	}
 	r.pc = r.pc[1:]
-	r.args = arest
+	r.args = append(r.args, *v)
 	return nil
 }
 
-func (*VM) execFunctor(r *registers) *Promise {
+func (*VM) execGetFunctor(r *registers) *Promise {
 	pi := r.pi[r.pc[0].operand]
-	arg, arest := NewVariable(), NewVariable()
-	cons1 := Compound{
-		Functor: ".",
-		Args:    []Term{arg, arest},
-	}
-	var ok bool
-	r.env, ok = r.args.Unify(&cons1, false, r.env)
-	if !ok {
-		return Bool(false)
-	}
-	ok, err := Functor(arg, pi.Name, pi.Arity, func(e *Env) *Promise {
-		r.env = e
-		return Bool(true)
-	}, r.env).Force(context.Background())
-	if err != nil {
-		return Error(err)
-	}
-	if !ok {
+	var args []Term
+	switch arg := r.env.Resolve(r.args[0]).(type) {
+	case Variable:
+		// Write mode. We construct the compound with fresh variables and bind it to the argument.
+		args = make([]Term, pi.Arity)
+		for i := range args {
+			args[i] = NewVariable()
+		}
+		r.env = r.env.Bind(arg, &Compound{Functor: pi.Name, Args: args})
+	case *Compound:
+		if arg.Functor != pi.Name || Integer(len(arg.Args)) != pi.Arity {
+			return Bool(false)
+		}
+		args = arg.Args
+	default:
 		return Bool(false)
 	}
 	r.pc = r.pc[1:]
-	r.args = NewVariable()
-	cons2 := Compound{
-		Functor: ".",
-		Args:    []Term{pi.Name, r.args},
-	}
-	ok, err = Univ(arg, &cons2, func(e *Env) *Promise {
-		r.env = e
-		return Bool(true)
-	}, r.env).Force(context.Background())
-	if err != nil {
-		return Error(err)
-	}
-	if !ok {
-		return Bool(false)
-	}
-	r.astack = Cons(arest, r.astack)
+	r.astack = append(r.astack, frame{args: r.args[1:]})
+	r.args = args
+	return nil
+}
+
+func (*VM) execPutFunctor(r *registers) *Promise {
+	pi := r.pi[r.pc[0].operand]
+	c := Compound{Functor: pi.Name, Args: make([]Term, 0, pi.Arity)}
+	r.pc = r.pc[1:]
+	r.astack = append(r.astack, frame{args: append(r.args, &c), compound: &c})
+	r.args = c.Args
 	return nil
 }
 
 func (*VM) execPop(r *registers) *Promise {
-	var ok bool
-	r.env, ok = r.args.Unify(List(), false, r.env)
-	if !ok {
-		return Bool(false)
+	f := r.astack[len(r.astack)-1]
+	if f.compound != nil {
+		f.compound.Args = r.args
 	}
 	r.pc = r.pc[1:]
-	a, arest := NewVariable(), NewVariable()
-	cons := Compound{
-		Functor: ".",
-		Args:    []Term{a, arest},
-	}
-	r.env, ok = r.astack.Unify(&cons, false, r.env)
-	if !ok {
-		return Bool(false)
-	}
-	r.args = a
-	r.astack = arest
+	r.args = f.args
+	r.astack = r.astack[:len(r.astack)-1]
 	return nil
 }
 
 func (*VM) execEnter(r *registers) *Promise {
-	var ok bool
-	r.env, ok = r.args.Unify(List(), false, r.env)
-	if !ok {
-		return Bool(false)
-	}
-	r.env, ok = r.astack.Unify(List(), false, r.env)
-	if !ok {
-		return Bool(false)
-	}
 	r.pc = r.pc[1:]
-	v := NewVariable()
-	r.args = v
-	r.astack = v
+	r.args = nil
+	r.astack = nil
 	return nil
 }
 
 func (vm *VM) execCall(r *registers) *Promise {
 	pi := r.pi[r.pc[0].operand]
-	var ok bool
-	r.env, ok = r.args.Unify(List(), false, r.env)
-	if !ok {
-		return Bool(false)
-	}
+	args := r.args
 	r.pc = r.pc[1:]
-	return Delay(func(context.Context) *Promise {
-		env := r.env
-		args, err := Slice(r.astack, env)
-		if err != nil {
-			return Error(err)
+	r.args = nil
+
+	// Last call optimization. If the call is followed by an exit, we don't have to come back to this clause.
+	next := r.pc
+	for next[0].opcode == opJump {
+		next = next[next[0].operand:]
+	}
+	if next[0].opcode == opExit {
+		// Nothing but the arguments refers to the variables created in this clause anymore.
+		// We pass their values instead and forget the bindings which are unreachable from the arguments.
+		for i, a := range args {
+			if v, ok := a.(Variable); ok && v > r.last {
+				args[i] = r.env.Resolve(v)
+			}
 		}
+		cont, env := r.cont, r.env.forget(r.base, r.last, args, r.input)
+		return Delay(func(context.Context) *Promise {
+			return vm.Arrive(pi, args, cont, env)
+		})
+	}
+
+	rest := *r
+	return Delay(func(context.Context) *Promise {
 		return vm.Arrive(pi, args, func(env *Env) *Promise {
-			v := NewVariable()
-			return vm.exec(registers{
-				pc:        r.pc,
-				xr:        r.xr,
-				vars:      r.vars,
-				cont:      r.cont,
-				args:      v,
-				astack:    v,
-				pi:        r.pi,
-				env:       env,
-				cutParent: r.cutParent,
-			})
-		}, env)
+			r := rest
+			r.env = env
+			return vm.exec(r)
+		}, rest.env)
 	})
 }
 
+// input checks if c is one of the compounds given to the clause.
+func (r *registers) input(c *Compound) bool {
+	for _, i := range r.inputs {
+		if i == c {
+			return true
+		}
+	}
+	return false
+}
+
 func (*VM) execExit(r *registers) *Promise {
 	return r.cont(r.env)
 }
 
 func (vm *VM) execCut(r *registers) *Promise {
 	r.pc = r.pc[1:]
+	rest := *r
 	return Cut(r.cutParent, func(context.Context) *Promise {
-		env := r.env
-		return vm.exec(registers{
-			pc:        r.pc,
-			xr:        r.xr,
-			vars:      r.vars,
-			cont:      r.cont,
-			args:      r.args,
-			astack:    r.astack,
-			pi:        r.pi,
-			env:       env,
-			cutParent: r.cutParent,
+		return vm.exec(rest)
+	})
+}
+
+func (vm *VM) execTry(r *registers) *Promise {
+	left, right := *r, *r
+	left.pc = r.pc[1:]
+	right.pc = r.pc[r.pc[0].operand:]
+	return Delay(func(context.Context) *Promise {
+		return vm.exec(left)
+	}, func(context.Context) *Promise {
+		return vm.exec(right)
+	})
+}
+
+func (*VM) execJump(r *registers) *Promise {
+	r.pc = r.pc[r.pc[0].operand:]
+	return nil
+}
+
+func (vm *VM) execIf(r *registers) *Promise {
+	var f ite
+	cond, els := *r, *r
+	cond.pc = r.pc[1:]
+	cond.ites = append(r.ites[:len(r.ites):len(r.ites)], &f)
+	els.pc = r.pc[r.pc[0].operand:]
+	f.choice = Delay(func(context.Context) *Promise {
+		f.cond = Delay(func(context.Context) *Promise {
+			return vm.exec(cond)
 		})
+		return f.cond
+	}, func(context.Context) *Promise {
+		if f.succeeded {
+			return Bool(false)
+		}
+		return vm.exec(els)
+	})
+	return f.choice
+}
+
+func (vm *VM) execThen(r *registers) *Promise {
+	f := r.ites[len(r.ites)-1]
+	r.pc = r.pc[1:]
+	r.ites = r.ites[:len(r.ites)-1]
+	rest := *r
+	return Cut(f.choice, func(context.Context) *Promise {
+		return vm.exec(rest)
+	})
+}
+
+func (*VM) execSoftThen(r *registers) *Promise {
+	f := r.ites[len(r.ites)-1]
+	f.succeeded = true
+	r.pc = r.pc[1:]
+	r.ites = r.ites[:len(r.ites)-1]
+	return nil
+}
+
+func (vm *VM) execCutLocal(r *registers) *Promise {
+	f := r.ites[len(r.ites)-1]
+	r.pc = r.pc[1:]
+	rest := *r
+	return Cut(f.cond, func(context.Context) *Promise {
+		return vm.exec(rest)
 	})
 }
 
+func (*VM) execFail(*registers) *Promise {
+	return Bool(false)
+}
+
 type predicate0 func(func(*Env) *Promise, *Env) *Promise
 
 func (p predicate0) Call(_ *VM, args []Term, k func(*Env) *Promise, env *Env) *Promise {
@@ -473,6 +751,79 @@ func (p predicate8) Call(_ *VM, args []Term, k func(*Env) *Promise, env *Env) *P
 	return p(args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7], k, env)
 }
 
+type predicateN struct {
+	arity int
+	p     func([]Term, func(*Env) *Promise, *Env) *Promise
+}
+
+func (p predicateN) Call(_ *VM, args []Term, k func(*Env) *Promise, env *Env) *Promise {
+	if len(args) != p.arity {
+		return Error(&wrongNumberOfArgumentsError{expected: p.arity, actual: args})
+	}
+
+	return p.p(args, k, env)
+}
+
+// NondetFunc is a nondeterministic predicate written in Go. It produces solutions by calling yield with terms which
+// are unified with the arguments. Since the next solution is requested only on backtracking, f can produce solutions
+// lazily e.g. from a database cursor. Once yield returns false, no more solutions are needed because of a cut, an
+// exception, or the end of the execution, and f should return as soon as possible. An error returned by f or a panic in
+// f is raised after the solutions produced so far.
+type NondetFunc func(ctx context.Context, args []Term, yield func(...Term) bool) error
+
+// Call calls f in another goroutine and unifies args with the solutions one by one.
+func (f NondetFunc) Call(_ *VM, args []Term, k func(*Env) *Promise, env *Env) *Promise {
+	return Delay(func(ctx context.Context) *Promise {
+		ts := make([]Term, len(args))
+		for i, a := range args {
+			ts[i] = env.Simplify(a)
+		}
+
+		var err error
+		more := make(chan bool)
+		next := make(chan []Term)
+		go func() {
+			defer close(next)
+			defer func() {
+				// A panic in the goroutine would crash the whole process. We return it as an error instead.
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			err = f(ctx, ts, func(ts ...Term) bool {
+				next <- ts
+				return <-more
+			})
+		}()
+
+		stop := func(context.Context) {
+			close(more)
+			for range next {
+			}
+		}
+		var iterate func(context.Context) *Promise
+		iterate = func(context.Context) *Promise {
+			ts, ok := <-next
+			if !ok {
+				if err != nil {
+					return Error(err)
+				}
+				return Bool(false)
+			}
+			return discardable(stop, func(context.Context) *Promise {
+				if len(ts) != len(args) {
+					return Error(&wrongNumberOfArgumentsError{expected: len(args), actual: ts})
+				}
+				return Unify(List(args...), List(ts...), k, env)
+			}, func(ctx context.Context) *Promise {
+				more <- true
+				return iterate(ctx)
+			})
+		}
+		return iterate(ctx)
+	})
+}
+
 // ProcedureIndicator identifies procedure e.g. (=)/2.
 type ProcedureIndicator struct {
 	Name  Atom
@@ -548,6 +899,15 @@ func piArgs(t Term, env *Env) (ProcedureIndicator, []Term, error) {
 	}
 }
 
+// closureGoal returns the goal made of closure and the additional arguments args.
+func closureGoal(closure Term, args []Term, env *Env) (Term, error) {
+	pi, cArgs, err := piArgs(closure, env)
+	if err != nil {
+		return nil, err
+	}
+	return pi.Name.Apply(append(cArgs[:len(cArgs):len(cArgs)], args...)...), nil
+}
+
 type wrongNumberOfArgumentsError struct {
 	expected int
 	actual   []Term
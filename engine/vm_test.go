@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -189,6 +190,204 @@ func TestVM_Register8(t *testing.T) {
 	})
 }
 
+func TestVM_RegisterN(t *testing.T) {
+	var vm VM
+	vm.RegisterN("foo", 9, func(args []Term, k func(*Env) *Promise, env *Env) *Promise {
+		return k(env)
+	})
+	p := vm.procedures[ProcedureIndicator{Name: "foo", Arity: 9}]
+
+	t.Run("ok", func(t *testing.T) {
+		ok, err := p.Call(&vm, []Term{Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("wrong number of arguments", func(t *testing.T) {
+		ok, err := p.Call(&vm, []Term{Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h")}, Success, nil).Force(context.Background())
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("call/N", func(t *testing.T) {
+		vm.Register1("call", func(g Term, k func(*Env) *Promise, env *Env) *Promise {
+			pi, args, err := piArgs(g, env)
+			if err != nil {
+				return Error(err)
+			}
+			return vm.Arrive(pi, args, k, env)
+		})
+		ok, err := vm.Arrive(ProcedureIndicator{Name: "call", Arity: 10}, []Term{Atom("foo"), Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestVM_RegisterNondet(t *testing.T) {
+	var (
+		vm       VM
+		produced []Term
+		stopped  bool
+	)
+	vm.RegisterNondet("foo", 2, func(ctx context.Context, args []Term, yield func(...Term) bool) error {
+		produced, stopped = nil, false
+		for i := Integer(1); i <= 3; i++ {
+			produced = append(produced, i)
+			if !yield(args[0], i) {
+				stopped = true
+				return nil
+			}
+		}
+		if args[0] == Atom("error") {
+			return errors.New("failed")
+		}
+		if args[0] == Atom("wrong") {
+			yield(args[0])
+		}
+		if args[0] == Atom("panic") {
+			panic("boom")
+		}
+		return nil
+	})
+	p := vm.procedures[ProcedureIndicator{Name: "foo", Arity: 2}]
+
+	t.Run("all", func(t *testing.T) {
+		var answers []Term
+		x := NewVariable()
+		ok, err := p.Call(&vm, []Term{Atom("a"), x}, func(env *Env) *Promise {
+			answers = append(answers, env.Resolve(x))
+			return Bool(false)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Term{Integer(1), Integer(2), Integer(3)}, answers)
+		assert.False(t, stopped)
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		x := NewVariable()
+		ok, err := p.Call(&vm, []Term{Atom("a"), x}, func(env *Env) *Promise {
+			assert.Equal(t, Integer(1), env.Resolve(x))
+			return Bool(true)
+		}, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{Integer(1)}, produced)
+		assert.True(t, stopped)
+	})
+
+	t.Run("cut", func(t *testing.T) {
+		var answers []Term
+		x := NewVariable()
+		var q *Promise
+		q = Delay(func(context.Context) *Promise {
+			return p.Call(&vm, []Term{Atom("a"), x}, func(env *Env) *Promise {
+				answers = append(answers, env.Resolve(x))
+				return Cut(q, func(context.Context) *Promise {
+					return Bool(false)
+				})
+			}, nil)
+		})
+		ok, err := q.Force(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []Term{Integer(1)}, answers)
+		assert.True(t, stopped)
+	})
+
+	t.Run("unification", func(t *testing.T) {
+		ok, err := p.Call(&vm, []Term{Atom("b"), Integer(2)}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []Term{Integer(1), Integer(2)}, produced)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ok, err := p.Call(&vm, []Term{Atom("error"), NewVariable()}, Failure, nil).Force(context.Background())
+		assert.EqualError(t, err, "failed")
+		assert.False(t, ok)
+	})
+
+	t.Run("wrong number of terms", func(t *testing.T) {
+		ok, err := p.Call(&vm, []Term{Atom("wrong"), NewVariable()}, Failure, nil).Force(context.Background())
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		ok, err := p.Call(&vm, []Term{Atom("panic"), NewVariable()}, Failure, nil).Force(context.Background())
+		assert.EqualError(t, err, "panic: boom")
+		assert.False(t, ok)
+	})
+}
+
+func TestVM_Atoms(t *testing.T) {
+	var state State
+	state.Register1("baz", func(t Term, k func(*Env) *Promise, env *Env) *Promise {
+		return k(env)
+	})
+	for _, c := range []Term{
+		&Compound{Functor: ":-", Args: []Term{
+			&Compound{Functor: "foo", Args: []Term{testVar("X")}},
+			&Compound{Functor: "bar", Args: []Term{testVar("X"), Atom("qux")}},
+		}},
+		&Compound{Functor: "bar", Args: []Term{&Compound{Functor: "f", Args: []Term{Atom("a")}}, Integer(1)}},
+	} {
+		assert.NoError(t, state.Assert(c, nil))
+	}
+	assert.Equal(t, []Atom{"a", "bar", "baz", "f", "foo", "qux"}, state.Atoms())
+}
+
+func TestVM_LoadedSourceFile(t *testing.T) {
+	var vm VM
+	vm.AddSourceFile("/foo.pl")
+	vm.AddSourceFile("/bar.pl")
+	vm.AddSourceFile("/foo.pl")
+	assert.True(t, vm.HasSourceFile("/bar.pl"))
+	assert.False(t, vm.HasSourceFile("/baz.pl"))
+
+	var files []Term
+	file := NewVariable()
+	ok, err := vm.LoadedSourceFile(file, func(env *Env) *Promise {
+		files = append(files, env.Resolve(file))
+		return Bool(false)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []Term{Atom("/foo.pl"), Atom("/bar.pl")}, files)
+
+	_, err = vm.LoadedSourceFile(Integer(0), Success, nil).Force(context.Background())
+	assert.Equal(t, TypeErrorAtom(Integer(0)), err)
+}
+
+func TestVM_SourceFile(t *testing.T) {
+	var state State
+	state.Register0("baz", func(k func(*Env) *Promise, env *Env) *Promise {
+		return k(env)
+	})
+	assert.NoError(t, state.AssertAt(&Compound{Functor: "foo", Args: []Term{Atom("a"), Atom("b")}}, SourcePos{File: "foo.pl", Line: 1}, nil))
+	assert.NoError(t, state.AssertAt(Atom("bar"), SourcePos{File: "bar.pl", Line: 1}, nil))
+	assert.NoError(t, state.Assert(Atom("qux"), nil))
+
+	var preds []Term
+	var files []Term
+	pred, file := NewVariable(), NewVariable()
+	ok, err := state.SourceFile(pred, file, func(env *Env) *Promise {
+		preds = append(preds, env.Resolve(pred))
+		files = append(files, env.Resolve(file))
+		return Bool(false)
+	}, nil).Force(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []Term{Atom("bar.pl"), Atom("foo.pl")}, files)
+	assert.Len(t, preds, 2)
+	assert.Equal(t, Atom("bar"), preds[0])
+	c, ok := preds[1].(*Compound)
+	assert.True(t, ok)
+	assert.Equal(t, Atom("foo"), c.Functor)
+	assert.Len(t, c.Args, 2)
+}
+
 func TestVM_Arrive(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		vm := VM{
@@ -203,6 +402,23 @@ func TestVM_Arrive(t *testing.T) {
 		assert.True(t, ok)
 	})
 
+	t.Run("call/N", func(t *testing.T) {
+		vm := VM{
+			procedures: map[ProcedureIndicator]procedure{
+				{Name: "call", Arity: 1}: predicate1(func(g Term, k func(*Env) *Promise, env *Env) *Promise {
+					assert.Equal(t, &Compound{Functor: "foo", Args: []Term{Atom("a"), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i")}}, g)
+					return k(env)
+				}),
+			},
+		}
+		ok, err := vm.Arrive(ProcedureIndicator{Name: "call", Arity: 9}, []Term{&Compound{Functor: "foo", Args: []Term{Atom("a")}}, Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i")}, Success, nil).Force(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = vm.Arrive(ProcedureIndicator{Name: "call", Arity: 9}, []Term{Integer(0), Atom("b"), Atom("c"), Atom("d"), Atom("e"), Atom("f"), Atom("g"), Atom("h"), Atom("i")}, Success, nil).Force(context.Background())
+		assert.Equal(t, TypeErrorCallable(Integer(0)), err)
+	})
+
 	t.Run("unknown procedure", func(t *testing.T) {
 		t.Run("error", func(t *testing.T) {
 			vm := VM{
@@ -255,7 +471,7 @@ func TestNewProcedureIndicator(t *testing.T) {
 	})
 
 	t.Run("variable", func(t *testing.T) {
-		pi, err := NewProcedureIndicator(Variable("PI"), nil)
+		pi, err := NewProcedureIndicator(testVar("PI"), nil)
 		assert.Equal(t, ErrInstantiation, err)
 		assert.Zero(t, pi)
 	})
@@ -281,7 +497,7 @@ func TestNewProcedureIndicator(t *testing.T) {
 	t.Run("variable functor", func(t *testing.T) {
 		pi, err := NewProcedureIndicator(&Compound{
 			Functor: "/",
-			Args:    []Term{Variable("Functor"), Integer(2)},
+			Args:    []Term{testVar("Functor"), Integer(2)},
 		}, nil)
 		assert.Equal(t, ErrInstantiation, err)
 		assert.Zero(t, pi)
@@ -302,7 +518,7 @@ func TestNewProcedureIndicator(t *testing.T) {
 	t.Run("variable arity", func(t *testing.T) {
 		pi, err := NewProcedureIndicator(&Compound{
 			Functor: "/",
-			Args:    []Term{Atom("foo"), Variable("Arity")},
+			Args:    []Term{Atom("foo"), testVar("Arity")},
 		}, nil)
 		assert.Equal(t, ErrInstantiation, err)
 		assert.Zero(t, pi)
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/ichiban/prolog/engine"
@@ -17,6 +19,34 @@ var bootstrap string
 // Interpreter is a Prolog interpreter. The zero value is a valid interpreter without any predicates/operators defined.
 type Interpreter struct {
 	engine.State
+
+	// main is the goal registered by initialization(Goal, main).
+	main engine.Term
+
+	// restore is the goals registered by initialization(Goal, restore).
+	restore []engine.Term
+
+	// loading is the stack of the sources being loaded.
+	loading []*loadContext
+
+	// TrailEnv makes queries run on engine.NewTrailEnv instead of the persistent engine.NewEnv.
+	// It's faster for queries which bind many variables, but a query must not be shared by goroutines.
+	TrailEnv bool
+}
+
+// loadContext is the state of loading a source.
+type loadContext struct {
+	// source is the file being loaded or "" if the source is not a file.
+	source string
+
+	// file is the file being read. It differs from source while the source is including another file.
+	file string
+
+	// line is the line of the term being loaded.
+	line int
+
+	// init is the goals to run after loading the source.
+	init []engine.Term
 }
 
 // New creates a new Prolog interpreter with predefined predicates/operators.
@@ -57,6 +87,20 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register3("setof", i.SetOf)
 	i.Register3("findall", i.FindAll)
 	i.Register3("catch", i.Catch)
+	i.Register2("call_cleanup", i.CallCleanup)
+	i.Register3("setup_call_cleanup", i.SetupCallCleanup)
+	i.Register3("reset", i.Reset)
+	i.Register1("shift", i.Shift)
+	i.Register1("call_continuation", i.CallContinuation)
+	i.Register3("engine_create", i.EngineCreate)
+	i.Register4("engine_create", i.EngineCreateWithOptions)
+	i.Register2("engine_next", i.EngineNext)
+	i.Register2("engine_next_reified", i.EngineNextReified)
+	i.Register2("engine_post", i.EnginePost)
+	i.Register3("engine_post", i.EnginePostWithReply)
+	i.Register1("engine_yield", i.EngineYield)
+	i.Register1("engine_fetch", i.EngineFetch)
+	i.Register1("engine_destroy", i.EngineDestroy)
 	i.Register3("functor", engine.Functor)
 	i.Register3("op", i.Op)
 	i.Register3("compare", engine.Compare)
@@ -88,13 +132,14 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register2("atom_codes", engine.AtomCodes)
 	i.Register2("number_chars", engine.NumberChars)
 	i.Register2("number_codes", engine.NumberCodes)
-	i.Register2("is", engine.DefaultEvaluableFunctors.Is)
-	i.Register2("=:=", engine.DefaultEvaluableFunctors.Equal)
-	i.Register2("=\\=", engine.DefaultEvaluableFunctors.NotEqual)
-	i.Register2("<", engine.DefaultEvaluableFunctors.LessThan)
-	i.Register2(">", engine.DefaultEvaluableFunctors.GreaterThan)
-	i.Register2("=<", engine.DefaultEvaluableFunctors.LessThanOrEqual)
-	i.Register2(">=", engine.DefaultEvaluableFunctors.GreaterThanOrEqual)
+	i.Register2("is", i.Is)
+	i.Register2("=:=", i.Equal)
+	i.Register2("=\\=", i.NotEqual)
+	i.Register2("<", i.LessThan)
+	i.Register2(">", i.GreaterThan)
+	i.Register2("=<", i.LessThanOrEqual)
+	i.Register2(">=", i.GreaterThanOrEqual)
+	i.Register1("arithmetic_function", i.ArithmeticFunction)
 	i.Register2("stream_property", i.StreamProperty)
 	i.Register2("set_stream_position", i.SetStreamPosition)
 	i.Register2("char_conversion", i.CharConversion)
@@ -104,9 +149,27 @@ func New(in io.Reader, out io.Writer) *Interpreter {
 	i.Register1("dynamic", i.Dynamic)
 	i.Register1("built_in", i.BuiltIn)
 	i.Register2("expand_term", i.ExpandTerm)
+	i.Register2("expand_goal", i.ExpandGoal)
 	i.Register1("consult", i.consult)
 	i.Register2("environ", engine.Environ)
 	i.Register3("phrase", i.Phrase)
+	i.Register0("trace", i.Trace)
+	i.Register0("notrace", i.Notrace)
+	i.Register1("spy", i.Spy)
+	i.Register1("nospy", i.Nospy)
+	i.Register1("leash", i.Leash)
+	i.Register0("debugging", i.Debugging)
+	i.Register1("profile", i.Profile)
+	i.Register1("begin_tests", i.BeginTests)
+	i.Register1("end_tests", i.EndTests)
+	i.Register1("run_tests", i.RunTests)
+	i.Register1("initialization", i.initialization1)
+	i.Register2("initialization", i.initialization)
+	i.Register1("ensure_loaded", i.ensureLoaded)
+	i.Register1("include", i.include)
+	i.Register1("source_file", i.LoadedSourceFile)
+	i.Register2("source_file", i.SourceFile)
+	i.Register2("prolog_load_context", i.prologLoadContext)
 	if err := i.Exec(bootstrap); err != nil {
 		panic(err)
 	}
@@ -121,6 +184,29 @@ func (i *Interpreter) Exec(query string, args ...interface{}) error {
 
 // ExecContext executes a prolog program with context.
 func (i *Interpreter) ExecContext(ctx context.Context, query string, args ...interface{}) error {
+	return i.exec(ctx, "", query, args...)
+}
+
+// exec executes a prolog program read from file.
+func (i *Interpreter) exec(ctx context.Context, file string, query string, args ...interface{}) error {
+	lc := loadContext{source: file, file: file}
+	i.loading = append(i.loading, &lc)
+	err := i.load(ctx, &lc, query, args...)
+	i.loading = i.loading[:len(i.loading)-1]
+	if err != nil {
+		return err
+	}
+
+	for _, g := range lc.init {
+		if _, err := i.Call(g, engine.Success, nil).Force(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the clauses and directives of the text of lc.file.
+func (i *Interpreter) load(ctx context.Context, lc *loadContext, query string, args ...interface{}) error {
 	// Ignore shebang line.
 	if len(query) > 2 && query[:2] == "#!" {
 		i := strings.Index(query, "\n")
@@ -134,30 +220,192 @@ func (i *Interpreter) ExecContext(ctx context.Context, query string, args ...int
 	if err := p.Replace("?", args...); err != nil {
 		return err
 	}
+	var (
+		conds conditionals
+		done  bool
+	)
 	for p.More() {
 		t, err := p.Term()
 		if err != nil {
 			return err
 		}
+		lc.line = p.Line()
 
-		et, err := i.Expand(t, nil)
+		// Conditional compilation
+		if ok, err := conds.directive(t, func(goal engine.Term) (bool, error) {
+			return i.Call(goal, engine.Success, nil).Force(ctx)
+		}); err != nil {
+			return err
+		} else if ok || !conds.active() {
+			continue
+		}
+
+		done, err = i.loadTerm(ctx, lc, t)
 		if err != nil {
 			return err
 		}
+		if done {
+			break
+		}
+	}
+	if len(conds) > 0 {
+		return engine.ExistenceError("directive", engine.Atom("endif"))
+	}
+	if done || lc.file == "" {
+		return nil
+	}
+
+	// Gives term_expansion/2 a chance to add clauses at the end of the file.
+	_, err := i.loadTerm(ctx, lc, engine.Atom("end_of_file"))
+	return err
+}
+
+// loadTerm expands t and then runs it if it's a directive or asserts it if it's a clause.
+// It returns true if it's the end of the source.
+func (i *Interpreter) loadTerm(ctx context.Context, lc *loadContext, t engine.Term) (bool, error) {
+	et, err := i.Expand(t, nil)
+	if err != nil {
+		return false, err
+	}
+
+	ts := []engine.Term{et}
+	if et == engine.Atom("[]") {
+		ts = nil
+	} else if c, ok := et.(*engine.Compound); ok && c.Functor == "." && len(c.Args) == 2 {
+		ts = nil
+		iter := engine.ListIterator{List: c}
+		for iter.Next() {
+			ts = append(ts, iter.Current())
+		}
+		if err := iter.Err(); err != nil {
+			return false, err
+		}
+	}
+
+	for _, t := range ts {
+		if t == engine.Atom("end_of_file") {
+			return true, nil
+		}
 
 		// Directive
-		if c, ok := et.(*engine.Compound); ok && c.Functor == ":-" && len(c.Args) == 1 {
+		if c, ok := t.(*engine.Compound); ok && c.Functor == ":-" && len(c.Args) == 1 {
 			if _, err := i.Call(c.Args[0], engine.Success, nil).Force(ctx); err != nil {
-				return err
+				return false, err
 			}
 			continue
 		}
 
-		if err := i.Assert(et, nil); err != nil {
-			return err
+		if err := i.AssertAt(t, engine.SourcePos{File: lc.file, Line: lc.line}, nil); err != nil {
+			return false, err
 		}
 	}
-	return nil
+	return false, nil
+}
+
+// conditionals is the stack of the conditional compilation directives if/1 ... endif/0.
+type conditionals []conditional
+
+type conditional struct {
+	// active is true if the clauses in the current branch are loaded.
+	active bool
+
+	// taken is true if any of the branches so far has been or will never be loaded.
+	taken bool
+
+	// inElse is true after else/0.
+	inElse bool
+}
+
+// active returns true if the clauses should be loaded.
+func (cs conditionals) active() bool {
+	return len(cs) == 0 || cs[len(cs)-1].active
+}
+
+// directive handles t if it's one of if/1, elif/1, else/0, and endif/0 and returns true. The conditions are evaluated
+// by eval only if they're in an active branch.
+func (cs *conditionals) directive(t engine.Term, eval func(engine.Term) (bool, error)) (bool, error) {
+	c, ok := t.(*engine.Compound)
+	if !ok || c.Functor != ":-" || len(c.Args) != 1 {
+		return false, nil
+	}
+
+	switch d := c.Args[0].(type) {
+	case *engine.Compound:
+		if len(d.Args) != 1 {
+			return false, nil
+		}
+		switch d.Functor {
+		case "if":
+			if !cs.active() {
+				*cs = append(*cs, conditional{taken: true})
+				return true, nil
+			}
+			ok, err := eval(d.Args[0])
+			if err != nil {
+				return true, err
+			}
+			*cs = append(*cs, conditional{active: ok, taken: ok})
+			return true, nil
+		case "elif":
+			top, err := cs.top()
+			if err != nil {
+				return true, err
+			}
+			if top.inElse {
+				return true, engine.ExistenceError("directive", engine.Atom("if"))
+			}
+			if top.taken {
+				top.active = false
+				return true, nil
+			}
+			ok, err := eval(d.Args[0])
+			if err != nil {
+				return true, err
+			}
+			top.active, top.taken = ok, ok
+			return true, nil
+		default:
+			return false, nil
+		}
+	case engine.Atom:
+		switch d {
+		case "else":
+			top, err := cs.top()
+			if err != nil {
+				return true, err
+			}
+			if top.inElse {
+				return true, engine.ExistenceError("directive", engine.Atom("if"))
+			}
+			top.active, top.taken, top.inElse = !top.taken, true, true
+			return true, nil
+		case "endif":
+			if _, err := cs.top(); err != nil {
+				return true, err
+			}
+			*cs = (*cs)[:len(*cs)-1]
+			return true, nil
+		default:
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+}
+
+func (cs conditionals) top() (*conditional, error) {
+	if len(cs) == 0 {
+		return nil, engine.ExistenceError("directive", engine.Atom("if"))
+	}
+	return &cs[len(cs)-1], nil
+}
+
+// currentLoad returns the context of the source being loaded or nil if it's not loading.
+func (i *Interpreter) currentLoad() *loadContext {
+	if len(i.loading) == 0 {
+		return nil
+	}
+	return i.loading[len(i.loading)-1]
 }
 
 // Query executes a prolog query and returns *Solutions.
@@ -167,7 +415,8 @@ func (i *Interpreter) Query(query string, args ...interface{}) (*Solutions, erro
 
 // QueryContext executes a prolog query and returns *Solutions with context.
 func (i *Interpreter) QueryContext(ctx context.Context, query string, args ...interface{}) (*Solutions, error) {
-	p := i.Parser(strings.NewReader(query), nil)
+	var vars []engine.ParsedVariable
+	p := i.Parser(strings.NewReader(query), &vars)
 	if err := p.Replace("?", args...); err != nil {
 		return nil, err
 	}
@@ -176,14 +425,18 @@ func (i *Interpreter) QueryContext(ctx context.Context, query string, args ...in
 		return nil, err
 	}
 
-	var env *engine.Env
+	env := engine.NewEnv()
+	if i.TrailEnv {
+		env = engine.NewTrailEnv()
+	}
 
 	more := make(chan bool, 1)
 	next := make(chan *engine.Env)
 	sols := Solutions{
-		vars: env.FreeVariables(t),
-		more: more,
-		next: next,
+		state: &i.State,
+		vars:  vars,
+		more:  more,
+		next:  next,
 	}
 
 	go func() {
@@ -228,6 +481,27 @@ func (i *Interpreter) QuerySolutionContext(ctx context.Context, query string, ar
 }
 
 func (i *Interpreter) consult(files engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	return engine.Delay(func(ctx context.Context) *engine.Promise {
+		return i.loadFiles(ctx, files, i.consultOne, k, env)
+	})
+}
+
+func (i *Interpreter) ensureLoaded(files engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	return engine.Delay(func(ctx context.Context) *engine.Promise {
+		return i.loadFiles(ctx, files, func(ctx context.Context, file engine.Term, env *engine.Env) error {
+			path, err := i.sourcePath(file, env)
+			if err != nil {
+				return err
+			}
+			if i.HasSourceFile(path) {
+				return nil
+			}
+			return i.consultOne(ctx, file, env)
+		}, k, env)
+	})
+}
+
+func (i *Interpreter) loadFiles(ctx context.Context, files engine.Term, load func(context.Context, engine.Term, *engine.Env) error, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
 	switch f := env.Resolve(files).(type) {
 	case engine.Variable:
 		return engine.Error(engine.ErrInstantiation)
@@ -237,7 +511,7 @@ func (i *Interpreter) consult(files engine.Term, k func(*engine.Env) *engine.Pro
 		}
 		iter := engine.ListIterator{List: f, Env: env}
 		for iter.Next() {
-			if err := i.consultOne(iter.Current(), env); err != nil {
+			if err := load(ctx, iter.Current(), env); err != nil {
 				return engine.Error(err)
 			}
 		}
@@ -246,32 +520,169 @@ func (i *Interpreter) consult(files engine.Term, k func(*engine.Env) *engine.Pro
 		}
 		return k(env)
 	default:
-		if err := i.consultOne(f, env); err != nil {
+		if err := load(ctx, f, env); err != nil {
 			return engine.Error(err)
 		}
 		return k(env)
 	}
 }
 
-func (i *Interpreter) consultOne(file engine.Term, env *engine.Env) error {
+func (i *Interpreter) consultOne(ctx context.Context, file engine.Term, env *engine.Env) error {
+	path, err := i.sourcePath(file, env)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	i.AddSourceFile(path)
+
+	return i.exec(ctx, path, string(b))
+}
+
+// sourcePath returns the absolute path of the source file. A relative path is resolved from the directory of the file
+// being loaded, if any. The extension .pl can be omitted.
+func (i *Interpreter) sourcePath(file engine.Term, env *engine.Env) (string, error) {
 	switch f := env.Resolve(file).(type) {
 	case engine.Variable:
-		return engine.ErrInstantiation
+		return "", engine.ErrInstantiation
 	case engine.Atom:
-		for _, f := range []string{string(f), string(f) + ".pl"} {
-			b, err := ioutil.ReadFile(f)
-			if err != nil {
+		name := string(f)
+		if lc := i.currentLoad(); lc != nil && lc.file != "" && !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(lc.file), name)
+		}
+		for _, n := range []string{name, name + ".pl"} {
+			fi, err := os.Stat(n)
+			if err != nil || fi.IsDir() {
 				continue
 			}
+			return filepath.Abs(n)
+		}
+		return "", engine.DomainError("source_sink", file)
+	default:
+		return "", engine.TypeError("atom", file)
+	}
+}
 
-			if err := i.Exec(string(b)); err != nil {
-				return err
-			}
+// include reads the clauses and directives in the file as if they appear in place of the directive.
+func (i *Interpreter) include(file engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	return engine.Delay(func(ctx context.Context) *engine.Promise {
+		return i.includeFile(ctx, file, k, env)
+	})
+}
+
+func (i *Interpreter) includeFile(ctx context.Context, file engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	path, err := i.sourcePath(file, env)
+	if err != nil {
+		return engine.Error(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return engine.Error(err)
+	}
+
+	lc := i.currentLoad()
+	if lc == nil {
+		if err := i.exec(ctx, path, string(b)); err != nil {
+			return engine.Error(err)
+		}
+		return k(env)
+	}
+
+	f, l := lc.file, lc.line
+	lc.file = path
+	err = i.load(ctx, lc, string(b))
+	lc.file, lc.line = f, l
+	if err != nil {
+		return engine.Error(err)
+	}
+	return k(env)
+}
+
+// initialization1 runs the goal after loading the source, or immediately if it's not loading.
+func (i *Interpreter) initialization1(goal engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	return i.initialization(goal, engine.Atom("after_load"), k, env)
+}
 
-			return nil
+func (i *Interpreter) initialization(goal, when engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	switch w := env.Resolve(when).(type) {
+	case engine.Variable:
+		return engine.Error(engine.ErrInstantiation)
+	case engine.Atom:
+		switch w {
+		case "after_load":
+			lc := i.currentLoad()
+			if lc == nil {
+				return i.initialization(goal, engine.Atom("now"), k, env)
+			}
+			lc.init = append(lc.init, env.Simplify(goal))
+			return k(env)
+		case "now":
+			return engine.Delay(func(ctx context.Context) *engine.Promise {
+				if _, err := i.Call(goal, engine.Success, env).Force(ctx); err != nil {
+					return engine.Error(err)
+				}
+				return k(env)
+			})
+		case "main":
+			i.main = env.Simplify(goal)
+			return k(env)
+		case "restore":
+			i.restore = append(i.restore, env.Simplify(goal))
+			return k(env)
+		default:
+			return engine.Error(engine.DomainError("initialization_type", w))
 		}
-		return engine.DomainError("source_sink", file)
 	default:
-		return engine.TypeError("atom", file)
+		return engine.Error(engine.TypeErrorAtom(w))
+	}
+}
+
+// RestoreGoals returns the goals registered by initialization(Goal, restore). They're not run while loading.
+// Since there are no saved states, the program is supposed to run them on startup after loading the files.
+func (i *Interpreter) RestoreGoals() []engine.Term {
+	return append([]engine.Term(nil), i.restore...)
+}
+
+// MainGoal returns the goal registered by initialization(Goal, main) and true, or false if there's none.
+// The program is supposed to run the goal after loading the files and exit.
+func (i *Interpreter) MainGoal() (engine.Term, bool) {
+	return i.main, i.main != nil
+}
+
+// prologLoadContext succeeds iff value is the value of key about the source being loaded.
+// The keys are source, file, directory, and term_position.
+func (i *Interpreter) prologLoadContext(key, value engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+	switch ky := env.Resolve(key).(type) {
+	case engine.Variable, engine.Atom:
+		break
+	default:
+		return engine.Error(engine.TypeErrorAtom(ky))
+	}
+
+	lc := i.currentLoad()
+	if lc == nil || lc.source == "" {
+		return engine.Bool(false)
+	}
+
+	pattern := engine.Compound{Args: []engine.Term{key, value}}
+	contexts := []engine.Term{
+		&engine.Compound{Args: []engine.Term{engine.Atom("source"), engine.Atom(lc.source)}},
+		&engine.Compound{Args: []engine.Term{engine.Atom("file"), engine.Atom(lc.file)}},
+		&engine.Compound{Args: []engine.Term{engine.Atom("directory"), engine.Atom(filepath.Dir(lc.file))}},
+		&engine.Compound{Args: []engine.Term{engine.Atom("term_position"), &engine.Compound{
+			Functor: "$stream_position",
+			Args:    []engine.Term{engine.Integer(0), engine.Integer(lc.line), engine.Integer(0), engine.Integer(0)},
+		}}},
+	}
+	ks := make([]func(context.Context) *engine.Promise, len(contexts))
+	for j := range contexts {
+		c := contexts[j]
+		ks[j] = func(context.Context) *engine.Promise {
+			return engine.Unify(&pattern, c, k, env)
+		}
 	}
+	return engine.Delay(ks...)
 }
@@ -1,9 +1,14 @@
 package prolog
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -99,6 +104,33 @@ append(nil, L, L).`))
 		t.Run("not an atom ", func(t *testing.T) {
 			assert.Error(t, i.Exec(":- consult(1)."))
 		})
+
+		t.Run("coverage", func(t *testing.T) {
+			i := New(nil, nil)
+			i.StartCoverage()
+			assert.NoError(t, i.Exec(":- consult(?).", "testdata/cover.pl"))
+			assert.NoError(t, i.QuerySolution(`foo(b).`).Err())
+
+			path, err := filepath.Abs("testdata/cover.pl")
+			assert.NoError(t, err)
+
+			var sb strings.Builder
+			assert.NoError(t, i.Coverage().WriteLCOV(&sb))
+			assert.Equal(t, `TN:
+SF:`+path+`
+BRDA:2,0,0,1
+BRDA:4,0,0,-
+BRDA:5,0,0,1
+DA:2,1
+DA:4,0
+DA:5,1
+BRF:3
+BRH:2
+LF:3
+LH:2
+end_of_record
+`, sb.String())
+		})
 	})
 
 	t.Run("term_expansion/2 throws an exception", func(t *testing.T) {
@@ -107,6 +139,570 @@ append(nil, L, L).`))
 
 		assert.Error(t, i.Exec("a."))
 	})
+
+	t.Run("term_expansion/2 returns a list", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`
+term_expansion(pair(X), [left(X), right(X)]).
+term_expansion(none, []).
+pair(a).
+none.
+`))
+
+		var s struct {
+			L, R string
+		}
+		assert.NoError(t, i.QuerySolution(`left(L), right(R).`).Scan(&s))
+		assert.Equal(t, "a", s.L)
+		assert.Equal(t, "a", s.R)
+		assert.Error(t, i.QuerySolution(`none.`).Err())
+	})
+
+	t.Run("term_expansion/2 of end_of_file", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`
+:- dynamic(item/1).
+term_expansion(end_of_file, [items(Xs), end_of_file]) :- findall(X, item(X), Xs).
+`))
+		assert.NoError(t, i.Exec(`:- consult(?).`, "testdata/items.pl"))
+
+		var s struct {
+			Xs []string
+		}
+		assert.NoError(t, i.QuerySolution(`items(Xs).`).Scan(&s))
+		assert.Equal(t, []string{"a", "b"}, s.Xs)
+	})
+
+	t.Run("end_of_file", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`
+foo(a).
+end_of_file.
+foo(b).
+`))
+
+		var s struct {
+			Xs []string
+		}
+		assert.NoError(t, i.QuerySolution(`findall(X, foo(X), Xs).`).Scan(&s))
+		assert.Equal(t, []string{"a"}, s.Xs)
+	})
+
+	t.Run("goal_expansion/2", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`
+:- dynamic(foo/2).
+goal_expansion(double(X, Y), Y is X * 2).
+goal_expansion(quadruple(X, Y), (double(X, Z), double(Z, Y))).
+foo(X, Y) :- X > 0 -> quadruple(X, Y) ; \+ double(X, Y), Y = 0.
+`))
+
+		var s struct {
+			Y int
+		}
+		assert.NoError(t, i.QuerySolution(`foo(3, Y).`).Scan(&s))
+		assert.Equal(t, 12, s.Y)
+
+		assert.NoError(t, i.QuerySolution(`clause(foo(_, _), (_ -> (_ is _, _ is _) ; \+ _ is _, _)).`).Err())
+
+		assert.NoError(t, i.QuerySolution(`expand_goal(findall(Y, quadruple(1, Y), Ys), findall(Y, (Z is 1*2, Y is Z*2), Ys)).`).Err())
+		assert.NoError(t, i.QuerySolution(`expand_goal(X, X), var(X).`).Err())
+		assert.NoError(t, i.QuerySolution(`expand_goal(forall(double(1, X), setof(Y, Z^quadruple(Z, Y), _)), forall(X is 1*2, setof(Y, Z^(W is Z*2, Y is W*2), _))).`).Err())
+	})
+
+	t.Run("goal_expansion/2 containing the goal", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`
+:- dynamic(foo/0).
+goal_expansion(a, (a, true)).
+a.
+foo :- a.
+`))
+		assert.NoError(t, i.QuerySolution(`clause(foo, (a, true)).`).Err())
+	})
+
+	t.Run("conditional compilation", func(t *testing.T) {
+		for _, tt := range []struct {
+			title string
+			text  string
+			foo   []string
+			err   error
+		}{
+			{title: "if", text: `
+:- if(true).
+foo(a).
+:- endif.
+:- if(fail).
+foo(b).
+:- endif.
+`, foo: []string{"a"}},
+			{title: "else", text: `
+:- if(fail).
+foo(a).
+:- else.
+foo(b).
+:- endif.
+`, foo: []string{"b"}},
+			{title: "elif", text: `
+:- if(fail).
+foo(a).
+:- elif(true).
+foo(b).
+:- elif(true).
+foo(c).
+:- else.
+foo(d).
+:- endif.
+`, foo: []string{"b"}},
+			{title: "nested", text: `
+:- if(true).
+  :- if(fail).
+  foo(a).
+  :- else.
+  foo(b).
+  :- endif.
+:- else.
+  :- if(true).
+  foo(c).
+  :- endif.
+:- endif.
+`, foo: []string{"b"}},
+			{title: "inactive conditions", text: `
+:- if(fail).
+  :- if(throw(foo)).
+  :- elif(throw(foo)).
+  :- endif.
+:- endif.
+:- if(true).
+foo(a).
+:- elif(throw(foo)).
+:- endif.
+`, foo: []string{"a"}},
+			{title: "no term expansion", text: `
+term_expansion(foo(x), foo(a)).
+:- if(fail).
+foo(x).
+:- endif.
+foo(x).
+`, foo: []string{"a"}},
+			{title: "existence", text: `
+:- if(current_predicate(bar/0)).
+foo(a).
+:- else.
+foo(b).
+:- endif.
+`, foo: []string{"b"}},
+			{title: "unterminated", text: `
+:- if(true).
+foo(a).
+`, err: engine.ExistenceError("directive", engine.Atom("endif"))},
+			{title: "endif without if", text: `
+:- endif.
+`, err: engine.ExistenceError("directive", engine.Atom("if"))},
+			{title: "elif after else", text: `
+:- if(true).
+:- else.
+:- elif(true).
+:- endif.
+`, err: engine.ExistenceError("directive", engine.Atom("if"))},
+		} {
+			t.Run(tt.title, func(t *testing.T) {
+				i := New(nil, nil)
+				assert.NoError(t, i.Exec(`:- dynamic(foo/1).`))
+				assert.Equal(t, tt.err, i.Exec(tt.text))
+				if tt.err != nil {
+					return
+				}
+
+				var s struct {
+					Xs []string
+				}
+				assert.NoError(t, i.QuerySolution(`findall(X, foo(X), Xs).`).Scan(&s))
+				assert.Equal(t, tt.foo, s.Xs)
+			})
+		}
+	})
+}
+
+func TestInterpreter_MainGoal(t *testing.T) {
+	t.Run("main", func(t *testing.T) {
+		i := New(nil, nil)
+		_, ok := i.MainGoal()
+		assert.False(t, ok)
+
+		assert.NoError(t, i.Exec(`:- initialization(main, main).`))
+		g, ok := i.MainGoal()
+		assert.True(t, ok)
+		assert.Equal(t, engine.Atom("main"), g)
+	})
+
+	t.Run("unknown when", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.Equal(t, engine.DomainError("initialization_type", engine.Atom("foo")), i.Exec(`:- initialization(main, foo).`))
+	})
+
+	t.Run("when is a variable", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.Equal(t, engine.ErrInstantiation, i.Exec(`:- initialization(main, _).`))
+	})
+}
+
+func TestInterpreter_Load(t *testing.T) {
+	load, err := filepath.Abs("testdata/load.pl")
+	assert.NoError(t, err)
+	included, err := filepath.Abs("testdata/included.pl")
+	assert.NoError(t, err)
+
+	t.Run("initialization", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`:- consult(?).`, "testdata/load"))
+
+		var s struct {
+			Inits []string
+		}
+		assert.NoError(t, i.QuerySolution(`findall(X, initialized(X), Inits).`).Scan(&s))
+		assert.Equal(t, []string{"now", "after_load"}, s.Inits)
+	})
+
+	t.Run("include", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`:- consult(?).`, "testdata/load"))
+
+		var s struct {
+			S, F string
+			L    int
+		}
+		assert.NoError(t, i.QuerySolution(`context(S, F, L).`).Scan(&s))
+		assert.Equal(t, load, s.S)
+		assert.Equal(t, included, s.F)
+		assert.Equal(t, 2, s.L)
+
+		assert.NoError(t, i.QuerySolution(`context(S, F).`).Scan(&s))
+		assert.Equal(t, load, s.S)
+		assert.Equal(t, load, s.F)
+
+		var p struct {
+			File string
+		}
+		assert.NoError(t, i.QuerySolution(`source_file(bar(_), File).`).Scan(&p))
+		assert.Equal(t, included, p.File)
+		assert.NoError(t, i.QuerySolution(`source_file(foo(_), File).`).Scan(&p))
+		assert.Equal(t, load, p.File)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		for _, q := range []string{
+			`consult(?).`,
+			`ensure_loaded(?).`,
+			`include(?).`,
+			`initialization((repeat, fail), now).`,
+		} {
+			t.Run(q, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+				i := New(nil, nil)
+				assert.Error(t, i.QuerySolutionContext(ctx, q, "testdata/loop").Err())
+			})
+		}
+	})
+
+	t.Run("ensure_loaded", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`:- ensure_loaded(?).`, "testdata/load"))
+		assert.NoError(t, i.Exec(`:- ensure_loaded(?).`, "testdata/load.pl"))
+
+		var s struct {
+			N int
+		}
+		assert.NoError(t, i.QuerySolution(`findall(X, foo(X), Xs), length(Xs, N).`).Scan(&s))
+		assert.Equal(t, 1, s.N)
+
+		assert.NoError(t, i.Exec(`:- consult(?).`, "testdata/load"))
+		assert.NoError(t, i.QuerySolution(`findall(X, foo(X), Xs), length(Xs, N).`).Scan(&s))
+		assert.Equal(t, 2, s.N)
+	})
+
+	t.Run("source_file", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`:- consult(?).`, "testdata/load"))
+
+		var s struct {
+			Files []string
+		}
+		assert.NoError(t, i.QuerySolution(`findall(F, source_file(F), Files).`).Scan(&s))
+		assert.Equal(t, []string{load}, s.Files)
+	})
+
+	t.Run("not loading", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`foo.`))
+		assert.Equal(t, ErrNoSolutions, i.QuerySolution(`prolog_load_context(source, _).`).Err())
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		i := New(nil, nil)
+		assert.NoError(t, i.Exec(`:- initialization(foo, restore).`))
+		assert.Equal(t, []engine.Term{engine.Atom("foo")}, i.RestoreGoals())
+	})
+}
+
+func TestInterpreter_Control(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+p(1).
+p(2).
+p(3).
+
+disj(X) :- (p(X), X > 1, ! ; X = 0).
+ite(X, Y) :- (p(X) -> Y = then ; Y = else).
+ite_cut(X) :- (p(X), ! -> true ; true).
+ite_local_cut(X) :- ((p(X), !, X > 1) -> true ; X = else).
+nested(X, Y) :- p(X), (X =:= 1 -> Y = one ; X =:= 2 -> Y = two ; Y = many).
+no_else(X) :- (X > 1 -> true).
+soft(X, Y) :- (p(X) *-> Y = then ; Y = else).
+soft_fail(Y) :- (fail *-> Y = then ; Y = else).
+neg(X) :- \+ (p(X), !, X > 1).
+conj(X, Y) :- ((p(X), p(Y)), X > Y), !.
+var_goal(G) :- (G ; true).
+sum(A, B, C, D, E, F, G, H, I, J, S) :- S is A + B + C + D + E + F + G + H + I + J.
+`))
+
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{query: `findall(X, disj(X), Xs).`, want: "[2]"},
+		{query: `findall(X-Y, ite(X, Y), Xs).`, want: "[1-then]"},
+		{query: `findall(X, ite_cut(X), Xs).`, want: "[1]"},
+		{query: `findall(X, ite_local_cut(X), Xs).`, want: "[else]"},
+		{query: `findall(X-Y, nested(X, Y), Xs).`, want: "[1-one,2-two,3-many]"},
+		{query: `findall(X, (p(X), no_else(X)), Xs).`, want: "[2,3]"},
+		{query: `findall(X-Y, soft(X, Y), Xs).`, want: "[1-then,2-then,3-then]"},
+		{query: `findall(Y, soft_fail(Y), Xs).`, want: "[else]"},
+		{query: `findall(x, neg(_), Xs).`, want: "[x]"},
+		{query: `findall(X-Y, conj(X, Y), Xs).`, want: "[2-1]"},
+		{query: `findall(Y, (var_goal(X = a), (var(X) -> Y = var ; Y = X)), Xs).`, want: "[a,var]"},
+		{query: `findall(X-Y, call((p(X) *-> Y = then ; Y = else)), Xs).`, want: "[1-then,2-then,3-then]"},
+		{query: `findall(X, (p(X) *-> true), Xs).`, want: "[1,2,3]"},
+		{query: `findall(X, '*->'(p(X), X > 1), Xs).`, want: "[2,3]"},
+		{query: `findall(x, forall(p(X), X > 0), Xs).`, want: "[x]"},
+		{query: `findall(x, forall(p(X), X > 1), Xs).`, want: "[]"},
+		{query: `findall(X, ignore(p(X)), Xs).`, want: "[1]"},
+		{query: `findall(x, ignore(fail), Xs).`, want: "[x]"},
+		{query: `findall(S, call(sum(1, 2), 3, 4, 5, 6, 7, 8, 9, 10, S), Xs).`, want: "[55]"},
+		{query: `findall(S, call(sum, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, S), Xs).`, want: "[55]"},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			var s struct {
+				Xs engine.Term
+			}
+			assert.NoError(t, i.QuerySolution(tt.query).Scan(&s))
+			var sb strings.Builder
+			assert.NoError(t, i.Write(&sb, s.Xs, nil))
+			assert.Equal(t, tt.want, strings.ReplaceAll(sb.String(), " ", ""))
+		})
+	}
+}
+
+func TestInterpreter_CallCleanup(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+:- dynamic(log/1).
+p(1).
+p(2).
+logged(Xs) :- findall(X, retract(log(X)), Xs).
+`))
+
+	for _, tt := range []struct {
+		title string
+		query string
+		err   bool
+		log   string
+	}{
+		{title: "deterministic exit", query: `setup_call_cleanup(assertz(log(setup)), assertz(log(goal)), assertz(log(cleanup))).`, log: "[setup,goal,cleanup]"},
+		{title: "nondeterministic exit", query: `call_cleanup(p(X), assertz(log(cleanup))), assertz(log(X)).`, log: "[1,cleanup]"},
+		{title: "cut", query: `once(call_cleanup(p(X), assertz(log(cleanup)))), assertz(log(X)).`, log: "[cleanup,1]"},
+		{title: "exception after exit", query: `catch((call_cleanup(p(X), assertz(log(cleanup))), throw(e)), e, assertz(log(caught))).`, log: "[cleanup,caught]"},
+		{title: "deterministic on backtracking", query: `call_cleanup(p(X), assertz(log(cleanup))), assertz(log(X)), X = 2.`, log: "[1,cleanup,2]"},
+		{title: "failure", query: `\+ call_cleanup(fail, assertz(log(cleanup))).`, log: "[cleanup]"},
+		{title: "exception", query: `catch(call_cleanup(throw(e), assertz(log(cleanup))), e, assertz(log(caught))).`, log: "[cleanup,caught]"},
+		{title: "cleanup fails", query: `call_cleanup(assertz(log(goal)), fail).`, log: "[goal]"},
+		{title: "setup fails", query: `\+ setup_call_cleanup(fail, assertz(log(goal)), assertz(log(cleanup))).`, log: "[]"},
+		{title: "setup is once", query: `setup_call_cleanup(p(X), assertz(log(X)), true), fail ; true.`, log: "[1]"},
+		{title: "bindings", query: `call_cleanup(X = a, assertz(log(X))).`, log: "[a]"},
+		{title: "cut through", query: `setup_call_cleanup(true, member(X, [1,2]), assertz(log(X))), !, assertz(log(after)).`, log: "[1,after]"},
+		{title: "nested deterministic exit", query: `setup_call_cleanup(true, setup_call_cleanup(true, true, assertz(log(inner))), assertz(log(outer))), assertz(log(after)).`, log: "[inner,outer,after]"},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.NoError(t, i.QuerySolution(tt.query).Err())
+
+			var s struct {
+				Xs engine.Term
+			}
+			assert.NoError(t, i.QuerySolution(`logged(Xs).`).Scan(&s))
+			var sb strings.Builder
+			assert.NoError(t, i.Write(&sb, s.Xs, nil))
+			assert.Equal(t, tt.log, strings.ReplaceAll(sb.String(), " ", ""))
+		})
+	}
+
+	t.Run("closed solutions", func(t *testing.T) {
+		sols, err := i.Query(`call_cleanup(p(X), assertz(log(cleanup))).`)
+		assert.NoError(t, err)
+		assert.True(t, sols.Next())
+		assert.NoError(t, i.QuerySolution(`\+ log(_).`).Err())
+		assert.NoError(t, sols.Close())
+		assert.NoError(t, i.QuerySolution(`log(cleanup).`).Err())
+	})
+
+	t.Run("cut through on trail env", func(t *testing.T) {
+		i := New(nil, nil)
+		i.TrailEnv = true
+		assert.NoError(t, i.Exec(`:- dynamic(log/1).`))
+		assert.NoError(t, i.QuerySolution(`setup_call_cleanup(true, member(X, [1,2]), assertz(log(X))), !, assertz(log(after)).`).Err())
+		assert.NoError(t, i.QuerySolution(`findall(X, log(X), [1, after]).`).Err())
+	})
+}
+
+func TestInterpreter_Reset(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+yield(X) :- shift(yield(X)).
+from_list([]).
+from_list([X|Xs]) :- yield(X), from_list(Xs).
+enumerate(G, Xs) :-
+	reset(G, yield(X), Cont),
+	(Cont == 0 -> Xs = []; Xs = [X|Rest], enumerate(Cont, Rest)).
+
+get(S) :- shift(get(S)).
+put(S) :- shift(put(S)).
+run_state(G, S0, S) :-
+	reset(G, Cmd, Cont),
+	(	Cont == 0 -> S = S0
+	;	Cmd = get(S0) -> run_state(Cont, S0, S)
+	;	Cmd = put(S1) -> run_state(Cont, S1, S)
+	).
+incr :- get(S0), S is S0 + 1, put(S).
+
+shift_once :- shift(a), !.
+
+:- dynamic(seen/1).
+`))
+
+	for _, tt := range []struct {
+		title string
+		query string
+	}{
+		{title: "no shift", query: `reset(X = a, _, Cont), X == a, Cont == 0.`},
+		{title: "shift", query: `reset((shift(a), X = b), Ball, Cont), Ball == a, var(X), call(Cont), X == b.`},
+		{title: "generator", query: `enumerate(from_list([a,b,c]), Xs), Xs == [a,b,c].`},
+		{title: "state", query: `run_state((incr, incr, get(X)), 0, S), X == 2, S == 2.`},
+		{title: "outer reset", query: `reset((reset(shift(outer), inner, C1), X = C1), outer, Cont), var(X), call(Cont), X == 0.`},
+		{title: "shift in continuation", query: `reset((shift(a), shift(b)), a, C1), reset(C1, Ball, C2), Ball == b, call(C2).`},
+		{title: "backtracking", query: `findall(X-Y, (reset((member(X, [1,2]), shift(X)), Y, Cont), call(Cont)), L), L == [1-1,2-2].`},
+		{title: "backtracking into continuation", query: `reset(shift(a), a, Cont), findall(X, (call(Cont), member(X, [1,2])), L), L == [1,2].`},
+		{title: "continuation called twice", query: `reset((shift(a), member(X, [1,2])), a, Cont), findall(X, (call(Cont); call(Cont)), L), L == [1,2,1,2].`},
+		{title: "continuation with cut called twice", query: `reset(shift_once, _, Cont), findall(x, (call(Cont); call(Cont)), L), L == [x,x].`},
+		{title: "continuation keeps bindings", query: `findall(C, reset((member(X, [1,2]), shift(a), assertz(seen(X))), _, C), [C1,C2]), call(C1), call(C2), findall(Y, retract(seen(Y)), L), L == [1,2].`},
+		{title: "ball mismatch", query: `catch(reset(shift(a), b, _), error(existence_error(reset, a), _), true).`},
+		{title: "no reset", query: `catch(shift(a), error(existence_error(reset, a), _), true).`},
+		{title: "no reset after exception", query: `catch(reset(throw(e), _, _), e, true), catch(shift(a), error(existence_error(reset, a), _), true).`},
+		{title: "no reset after exit", query: `reset(true, _, _), catch(shift(a), error(existence_error(reset, a), _), true).`},
+		{title: "not a continuation", query: `catch(call_continuation(foo), error(type_error(continuation, foo), _), true).`},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.NoError(t, i.QuerySolution(tt.query).Err())
+		})
+	}
+}
+
+func TestInterpreter_Engine(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.Exec(`
+:- dynamic(done/0).
+sum(S0) :- engine_fetch(N), S is S0 + N, engine_yield(S), sum(S).
+`))
+
+	for _, tt := range []struct {
+		title string
+		query string
+	}{
+		{title: "answers", query: `engine_create(X, member(X, [a,b]), E), engine_next(E, A), engine_next(E, B), \+ engine_next(E, _), A == a, B == b.`},
+		{title: "interleaving", query: `engine_create(X, member(X, [1,2]), E1), engine_create(X, member(X, [a,b]), E2), engine_next(E1, A), engine_next(E2, B), engine_next(E1, C), engine_next(E2, D), [A,B,C,D] == [1,a,2,b].`},
+		{title: "copies", query: `engine_create(X-Y, X = a, E), engine_next(E, A-B), var(X), var(Y), A == a, var(B).`},
+		{title: "reified", query: `engine_create(X, member(X, [a]), E), engine_next_reified(E, R1), engine_next_reified(E, R2), R1 == the(a), R2 == no.`},
+		{title: "reified exception", query: `engine_create(_, throw(e), E), engine_next_reified(E, R), R == throw(e).`},
+		{title: "exception", query: `engine_create(_, throw(e), E), catch(engine_next(E, _), e, true).`},
+		{title: "yield", query: `engine_create(X, (engine_yield(a), X = b), E), engine_next(E, A), engine_next(E, B), A == a, B == b.`},
+		{title: "post", query: `engine_create(X, (engine_fetch(Y), X is Y * 2), E), engine_post(E, 3, R), R == 6.`},
+		{title: "accumulator", query: `engine_create(_, sum(0), E), engine_post(E, 1, A), engine_post(E, 2, B), A == 1, B == 3.`},
+		{title: "nothing posted", query: `engine_create(X, engine_fetch(X), E), catch(engine_next(E, _), error(existence_error(term, delivery), _), true).`},
+		{title: "alias", query: `engine_create(X, member(X, [a]), E, [alias(foo)]), E == foo, engine_next(foo, A), A == a, engine_destroy(foo).`},
+		{title: "destroyed", query: `engine_create(_, true, E), engine_destroy(E), catch(engine_next(E, _), error(existence_error(engine, _), _), true).`},
+		{title: "destroy runs cleanup", query: `engine_create(X, setup_call_cleanup(true, member(X, [1,2]), assertz(done)), E), engine_next(E, _), \+ done, engine_destroy(E), retract(done).`},
+		{title: "reset inside engine", query: `engine_create(X, reset((engine_yield(a), shift(b), X = c), b, _), E), engine_next(E, _), catch(shift(b), error(existence_error(reset, b), _), true), engine_next(E, R), var(R).`},
+		{title: "yield outside engine", query: `catch(engine_yield(a), error(permission_error(yield, engine, main), _), true).`},
+		{title: "not callable", query: `catch(engine_create(_, 1, _), error(type_error(callable, 1), _), true).`},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.NoError(t, i.QuerySolution(tt.query).Err())
+		})
+	}
+}
+
+func TestInterpreter_ArithmeticFunction(t *testing.T) {
+	i := New(nil, nil)
+	i.RegisterFunction("hypot", 2, func(args ...engine.Number) (engine.Number, error) {
+		x, err := engine.AsFloat(args[0])
+		if err != nil {
+			return nil, err
+		}
+		y, err := engine.AsFloat(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return engine.Float(math.Hypot(float64(x.(engine.Float)), float64(y.(engine.Float)))), nil
+	})
+	assert.NoError(t, i.Exec(`
+:- arithmetic_function(clamp/3).
+clamp(X, Lo, Hi, Y) :- Y is max(Lo, min(X, Hi)).
+`))
+
+	for _, tt := range []struct {
+		title string
+		query string
+	}{
+		{title: "go", query: `X is hypot(3, 4), X =:= 5.`},
+		{title: "prolog", query: `X is clamp(7, 0, 5) + 1, X == 6.`},
+		{title: "nested", query: `X is clamp(hypot(3, 4) * 2, 0, 5.5), X =:= 5.5.`},
+		{title: "comparison", query: `clamp(-1, 0, 5) < 1.`},
+		{title: "unknown", query: `catch(_ is clamp(1, 2), error(type_error(evaluable, clamp/2), _), true).`},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.NoError(t, i.QuerySolution(tt.query).Err())
+		})
+	}
+
+	t.Run("per interpreter", func(t *testing.T) {
+		assert.Error(t, New(nil, nil).QuerySolution(`_ is hypot(3, 4).`).Err())
+	})
+}
+
+func TestInterpreter_TrailEnv(t *testing.T) {
+	i := New(nil, nil)
+	i.TrailEnv = true
+	sols, err := i.Query(`member(X, [a, b]), atom(X).`)
+	assert.NoError(t, err)
+	defer sols.Close()
+
+	var xs []string
+	for sols.Next() {
+		var s struct{ X string }
+		assert.NoError(t, sols.Scan(&s))
+		xs = append(xs, s.X)
+	}
+	assert.NoError(t, sols.Err())
+	assert.Equal(t, []string{"a", "b"}, xs)
 }
 
 func TestInterpreter_Query(t *testing.T) {
@@ -129,8 +725,8 @@ func TestInterpreter_Query(t *testing.T) {
 		assert.NoError(t, sols.Scan(m))
 		assert.Len(t, m, 3)
 		assert.Equal(t, engine.Atom("nil"), m["X"])
-		assert.Equal(t, engine.Variable("Z"), m["Y"])
-		assert.Equal(t, engine.Variable("Z"), m["Z"])
+		assert.IsType(t, engine.Variable(0), m["Y"])
+		assert.Equal(t, m["Y"], m["Z"])
 	})
 
 	t.Run("rule", func(t *testing.T) {
@@ -705,3 +1301,105 @@ func ExampleInterpreter_QuerySolution_sort() {
 	// true
 	// true
 }
+
+func BenchmarkInterpreter_NaiveReverse(b *testing.B) {
+	i := New(nil, nil)
+	if err := i.Exec(`
+nrev([], []).
+nrev([X|Xs], Ys) :- nrev(Xs, Zs), append(Zs, [X], Ys).
+
+range(N, N, [N]) :- !.
+range(M, N, [M|Ns]) :- M < N, M1 is M + 1, range(M1, N, Ns).
+`); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := i.QuerySolution(`range(1, 30, L), nrev(L, _).`).Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInterpreter_Queens(b *testing.B) {
+	i := New(nil, nil)
+	if err := i.Exec(`
+queens(N, Qs) :- range(1, N, Ns), queens(Ns, [], Qs).
+
+queens([], Qs, Qs).
+queens(UnplacedQs, SafeQs, Qs) :-
+  select(Q, UnplacedQs, UnplacedQs1),
+  \+attack(Q, SafeQs),
+  queens(UnplacedQs1, [Q|SafeQs], Qs).
+
+attack(X, Xs) :- attack(X, 1, Xs).
+
+attack(X, N, [Y|_]) :- X is Y + N.
+attack(X, N, [Y|_]) :- X is Y - N.
+attack(X, N, [_|Ys]) :- N1 is N + 1, attack(X, N1, Ys).
+
+range(N, N, [N]) :- !.
+range(M, N, [M|Ns]) :- M < N, M1 is M + 1, range(M1, N, Ns).
+
+select(X, [X|Xs], Xs).
+select(X, [Y|Ys], [Y|Zs]) :- select(X, Ys, Zs).
+`); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := i.QuerySolution(`queens(6, _).`).Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInterpreter_Hanoi(b *testing.B) {
+	i := New(nil, nil)
+	if err := i.Exec(`
+hanoi(N) :- move(N, left, right, center).
+
+move(0, _, _, _) :- !.
+move(N, X, Y, Z) :-
+  M is N - 1,
+  move(M, X, Z, Y),
+  actuate(X, Y),
+  move(M, Z, Y, X).
+`); err != nil {
+		b.Fatal(err)
+	}
+	i.Register2("actuate", func(_, _ engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+		return k(env)
+	})
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := i.QuerySolution(`hanoi(8).`).Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInterpreter_Control(b *testing.B) {
+	i := New(nil, nil)
+	if err := i.Exec(`
+count(N, N) :- !.
+count(M, N) :-
+  (M mod 3 =:= 0 -> true ; M mod 3 =:= 1 -> true ; true),
+  (M < 0 ; M >= 0),
+  \+ M < 0,
+  M1 is M + 1,
+  count(M1, N).
+`); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := i.QuerySolution(`count(0, 1000).`).Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
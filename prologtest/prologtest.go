@@ -0,0 +1,49 @@
+// Package prologtest runs unit tests written in Prolog as Go tests.
+package prologtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ichiban/prolog"
+	"github.com/ichiban/prolog/engine"
+)
+
+// Run runs the tests defined between begin_tests/1 and end_tests/1 in i as subtests of t.
+// Each unit becomes a subtest of t and each test becomes a subtest of its unit.
+func Run(t *testing.T, i *prolog.Interpreter) {
+	t.Helper()
+
+	var units []engine.Atom
+	tests := map[engine.Atom][]engine.Test{}
+	for _, test := range i.Tests() {
+		if _, ok := tests[test.Unit]; !ok {
+			units = append(units, test.Unit)
+		}
+		tests[test.Unit] = append(tests[test.Unit], test)
+	}
+
+	for _, u := range units {
+		t.Run(string(u), func(t *testing.T) {
+			for _, test := range tests[u] {
+				test := test
+				var name strings.Builder
+				_ = i.Write(&name, test.Name, nil)
+				t.Run(name.String(), func(t *testing.T) {
+					r := i.RunTest(context.Background(), test)
+					if r.Err != nil {
+						if test.Pos.File == "" {
+							t.Error(r.Err)
+						} else {
+							t.Errorf("%s:%d: %v", test.Pos.File, test.Pos.Line, r.Err)
+						}
+					}
+					if r.Choicepoint {
+						t.Log("succeeded with choicepoint")
+					}
+				})
+			}
+		})
+	}
+}
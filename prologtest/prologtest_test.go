@@ -0,0 +1,16 @@
+package prologtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ichiban/prolog"
+)
+
+func TestRun(t *testing.T) {
+	i := prolog.New(nil, nil)
+	assert.NoError(t, i.Exec(`:- consult(?).`, "testdata/lists.pl"))
+	assert.Len(t, i.Tests(), 8)
+	Run(t, i)
+}
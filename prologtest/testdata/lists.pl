@@ -0,0 +1,12 @@
+:- begin_tests(lists).
+
+test(append) :- append([a], [b], [a, b]).
+test(member, [nondet]) :- member(b, [a, b, c]).
+test(length, N == 3) :- length([a, b, c], N).
+test(nth0, true(E == b)) :- nth0(1, [a, b, c], E).
+test(empty, fail) :- member(_, []).
+test(error, throws(error(instantiation_error, _))) :- atom_length(_, _).
+test(all, all(X == [a, b, c])) :- member(X, [a, b, c]).
+test(setup, [setup(X = a), cleanup(true)]) :- X == a.
+
+:- end_tests(lists).
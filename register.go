@@ -0,0 +1,119 @@
+package prolog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	termType  = reflect.TypeOf((*engine.Term)(nil)).Elem()
+	anyType   = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// RegisterFunc registers a Go function fn as a predicate of the name.
+// The leading arguments of the predicate are converted into the parameters of fn by the same rules as Solutions.Scan.
+// The results of fn are converted into terms and unified with the trailing arguments of the predicate, except for
+// the last result of type error, which is raised if non-nil, and the last result of type bool before it, which makes
+// the predicate fail if false. Thus, the arity of the predicate is the number of the parameters plus the number of the
+// other results.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		return fmt.Errorf("not a function: %T", fn)
+	}
+	typ := f.Type()
+	if typ.IsVariadic() {
+		return fmt.Errorf("variadic function: %s", typ)
+	}
+
+	ins, outs := typ.NumIn(), typ.NumOut()
+	hasErr := outs > 0 && typ.Out(outs-1) == errorType
+	if hasErr {
+		outs--
+	}
+	hasOK := outs > 0 && typ.Out(outs-1).Kind() == reflect.Bool
+	if hasOK {
+		outs--
+	}
+
+	for j := 0; j < ins; j++ {
+		if !convertible(typ.In(j)) {
+			return fmt.Errorf("unsupported parameter type: %s", typ.In(j))
+		}
+	}
+	for j := 0; j < outs; j++ {
+		if !termable(typ.Out(j)) {
+			return fmt.Errorf("unsupported result type: %s", typ.Out(j))
+		}
+	}
+
+	i.RegisterN(name, ins+outs, func(args []engine.Term, k func(*engine.Env) *engine.Promise, env *engine.Env) *engine.Promise {
+		in := make([]reflect.Value, ins)
+		for j := range in {
+			v, err := convert(env.Simplify(args[j]), typ.In(j), env)
+			if err != nil {
+				return engine.Error(err)
+			}
+			in[j] = v
+		}
+
+		out := f.Call(in)
+		if hasErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return engine.Error(err)
+			}
+		}
+		if hasOK && !out[outs].Bool() {
+			return engine.Bool(false)
+		}
+
+		results := make([]engine.Term, outs)
+		for j := range results {
+			if out[j].Kind() == reflect.Interface && out[j].IsNil() {
+				return engine.Error(errors.New("nil result"))
+			}
+			t, err := engine.TermOf(out[j].Interface())
+			if err != nil {
+				return engine.Error(err)
+			}
+			results[j] = t
+		}
+		return engine.Unify(engine.List(args[ins:]...), engine.List(results...), k, env)
+	})
+	return nil
+}
+
+// convertible checks if a term can be converted into a value of typ.
+func convertible(typ reflect.Type) bool {
+	switch typ {
+	case anyType, termType:
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.String:
+		return true
+	case reflect.Slice:
+		return convertible(typ.Elem())
+	default:
+		return false
+	}
+}
+
+// termable checks if a value of typ can be converted into a term.
+func termable(typ reflect.Type) bool {
+	if typ == anyType || typ.Implements(termType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.String:
+		return true
+	case reflect.Array, reflect.Slice:
+		return termable(typ.Elem())
+	default:
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+package prolog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ichiban/prolog/engine"
+)
+
+func TestInterpreter_RegisterFunc(t *testing.T) {
+	i := New(nil, nil)
+	assert.NoError(t, i.RegisterFunc("repeat_atom", func(n int, s string) string {
+		return strings.Repeat(s, n)
+	}))
+	assert.NoError(t, i.RegisterFunc("even", func(n int) bool {
+		return n%2 == 0
+	}))
+	assert.NoError(t, i.RegisterFunc("divide", func(x, y int) (int, int, error) {
+		if y == 0 {
+			return 0, 0, engine.DomainError("not_less_than_one", engine.Integer(y))
+		}
+		return x / y, x % y, nil
+	}))
+	assert.NoError(t, i.RegisterFunc("lookup", func(key string) (float64, bool) {
+		v, ok := map[string]float64{"pi": 3.14}[key]
+		return v, ok
+	}))
+	assert.NoError(t, i.RegisterFunc("sum", func(ns []int) int {
+		var s int
+		for _, n := range ns {
+			s += n
+		}
+		return s
+	}))
+	assert.NoError(t, i.RegisterFunc("wrap", func(t engine.Term) []engine.Term {
+		return []engine.Term{t, t}
+	}))
+	assert.NoError(t, i.RegisterFunc("wide", func(a, b, c, d, e, f, g, h, j int) int {
+		return a + b + c + d + e + f + g + h + j
+	}))
+	assert.NoError(t, i.RegisterFunc("small", func(n int8, f float32) (int8, float32) {
+		return n, f
+	}))
+	assert.NoError(t, i.RegisterFunc("broken", func() error {
+		return errors.New("broken")
+	}))
+
+	for _, tt := range []struct {
+		title string
+		query string
+		err   error
+	}{
+		{title: "output", query: `repeat_atom(3, ab, X), X == ababab.`},
+		{title: "output mismatch", query: `\+ repeat_atom(3, ab, abab).`},
+		{title: "success", query: `even(2), \+ even(3).`},
+		{title: "multiple outputs", query: `divide(7, 2, Q, R), Q == 3, R == 1.`},
+		{title: "exception", query: `catch(divide(1, 0, _, _), error(domain_error(not_less_than_one, 0), _), true).`},
+		{title: "outputs with success", query: `lookup(pi, X), X == 3.14, \+ lookup(e, _).`},
+		{title: "list", query: `sum([1,2,3], X), X == 6.`},
+		{title: "term", query: `wrap(f(Y), X), X == [f(Y), f(Y)].`},
+		{title: "wide", query: `wide(1, 2, 3, 4, 5, 6, 7, 8, 9, X), X == 45.`},
+		{title: "instantiation error", query: `catch(even(_), error(instantiation_error, _), true).`},
+		{title: "type error", query: `catch(even(a), error(type_error(integer, a), _), true).`},
+		{title: "type error in list", query: `catch(sum([1,a], _), error(type_error(integer, a), _), true).`},
+		{title: "partial list", query: `catch(sum([1|_], _), error(instantiation_error, _), true).`},
+		{title: "representation error", query: `catch(small(300, 1.0, _, _), error(representation_error(int8), _), true).`},
+		{title: "float representation error", query: `catch(small(1, 1.0e300, _, _), error(representation_error(float32), _), true).`},
+		{title: "error", query: `broken.`, err: errors.New("broken")},
+	} {
+		t.Run(tt.title, func(t *testing.T) {
+			err := i.QuerySolution(tt.query).Err()
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, tt.err, err)
+			}
+		})
+	}
+
+	t.Run("not a function", func(t *testing.T) {
+		assert.Error(t, i.RegisterFunc("foo", 1))
+	})
+
+	t.Run("variadic", func(t *testing.T) {
+		assert.Error(t, i.RegisterFunc("foo", func(...int) {}))
+	})
+
+	t.Run("unsupported parameter", func(t *testing.T) {
+		assert.Error(t, i.RegisterFunc("foo", func(bool) {}))
+	})
+
+	t.Run("unsupported result", func(t *testing.T) {
+		assert.Error(t, i.RegisterFunc("foo", func() map[string]int { return nil }))
+	})
+}
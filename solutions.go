@@ -3,6 +3,7 @@ package prolog
 import (
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 
 	"github.com/ichiban/prolog/engine"
@@ -11,8 +12,9 @@ import (
 // Solutions is the result of a query. Everytime the Next method is called, it searches for the next solution.
 // By calling the Scan method, you can retrieve the content of the solution.
 type Solutions struct {
+	state  *engine.State
 	env    *engine.Env
-	vars   []engine.Variable
+	vars   []engine.ParsedVariable
 	more   chan<- bool
 	next   <-chan *engine.Env
 	err    error
@@ -29,6 +31,12 @@ func (s *Solutions) Close() error {
 	}
 	close(s.more)
 	s.closed = true
+
+	// Waits for the execution to finish so that the cleanups of the discarded choicepoints are done.
+	if s.next != nil {
+		for range s.next {
+		}
+	}
 	return nil
 }
 
@@ -45,6 +53,7 @@ func (s *Solutions) Next() bool {
 }
 
 // Scan copies the variable values of the current solution into the specified struct/map.
+// If a value doesn't fit in the destination, it returns the same error as Prolog, e.g. type_error(integer, a).
 func (s *Solutions) Scan(dest interface{}) error {
 	o := reflect.ValueOf(dest)
 	switch o.Kind() {
@@ -65,16 +74,16 @@ func (s *Solutions) Scan(dest interface{}) error {
 			}
 
 			for _, v := range s.vars {
-				f, ok := fields[string(v)]
+				f, ok := fields[string(v.Name)]
 				if !ok {
 					continue
 				}
 
-				val, err := convert(s.env.Simplify(v), f.Type(), s.env)
+				val, err := convert(s.env.Simplify(v.Variable), f.Type(), s.env)
 				if err != nil {
 					return err
 				}
-				fields[string(v)].Set(val)
+				f.Set(val)
 			}
 		}
 		return nil
@@ -85,11 +94,11 @@ func (s *Solutions) Scan(dest interface{}) error {
 		}
 
 		for _, v := range s.vars {
-			val, err := convert(s.env.Simplify(v), t.Elem(), s.env)
+			val, err := convert(s.env.Simplify(v.Variable), t.Elem(), s.env)
 			if err != nil {
 				return err
 			}
-			o.SetMapIndex(reflect.ValueOf(string(v)), val)
+			o.SetMapIndex(reflect.ValueOf(string(v.Name)), val)
 		}
 		return nil
 	default:
@@ -99,22 +108,43 @@ func (s *Solutions) Scan(dest interface{}) error {
 
 func convert(t engine.Term, typ reflect.Type, env *engine.Env) (reflect.Value, error) {
 	switch typ {
-	case reflect.TypeOf((*interface{})(nil)).Elem(), reflect.TypeOf((*engine.Term)(nil)).Elem():
+	case anyType, termType:
 		return reflect.ValueOf(t), nil
 	}
 
 	switch typ.Kind() {
 	case reflect.Float32, reflect.Float64:
-		if f, ok := t.(engine.Float); ok {
-			return reflect.ValueOf(f).Convert(typ), nil
+		switch t := env.Resolve(t).(type) {
+		case engine.Variable:
+			return reflect.Value{}, engine.ErrInstantiation
+		case engine.Float:
+			if reflect.Zero(typ).OverflowFloat(float64(t)) {
+				return reflect.Value{}, engine.RepresentationError(engine.Atom(typ.Kind().String()))
+			}
+			return reflect.ValueOf(t).Convert(typ), nil
+		default:
+			return reflect.Value{}, engine.TypeErrorFloat(t)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if i, ok := t.(engine.Integer); ok {
-			return reflect.ValueOf(i).Convert(typ), nil
+		switch t := env.Resolve(t).(type) {
+		case engine.Variable:
+			return reflect.Value{}, engine.ErrInstantiation
+		case engine.Integer:
+			if reflect.Zero(typ).OverflowInt(int64(t)) {
+				return reflect.Value{}, engine.RepresentationError(engine.Atom(typ.Kind().String()))
+			}
+			return reflect.ValueOf(t).Convert(typ), nil
+		default:
+			return reflect.Value{}, engine.TypeErrorInteger(t)
 		}
 	case reflect.String:
-		if a, ok := t.(engine.Atom); ok {
-			return reflect.ValueOf(string(a)), nil
+		switch t := env.Resolve(t).(type) {
+		case engine.Variable:
+			return reflect.Value{}, engine.ErrInstantiation
+		case engine.Atom:
+			return reflect.ValueOf(string(t)), nil
+		default:
+			return reflect.Value{}, engine.TypeErrorAtom(t)
 		}
 	case reflect.Slice:
 		r := reflect.MakeSlice(reflect.SliceOf(typ.Elem()), 0, 0)
@@ -131,6 +161,11 @@ func convert(t engine.Term, typ reflect.Type, env *engine.Env) (reflect.Value, e
 	return reflect.Value{}, fmt.Errorf("failed to convert: %s", typ)
 }
 
+// WriteAnswer writes the current solution as the toplevel shows it, e.g. X = Y, Y = f(_A).
+func (s *Solutions) WriteAnswer(w io.Writer, opts ...engine.WriteOption) error {
+	return s.state.WriteAnswer(w, s.vars, s.env, opts...)
+}
+
 // Err returns the error if exists.
 func (s *Solutions) Err() error {
 	return s.err
@@ -140,10 +175,7 @@ func (s *Solutions) Err() error {
 func (s *Solutions) Vars() []string {
 	ns := make([]string, 0, len(s.vars))
 	for _, v := range s.vars {
-		if v.Generated() {
-			continue
-		}
-		ns = append(ns, string(v))
+		ns = append(ns, string(v.Name))
 	}
 	return ns
 }
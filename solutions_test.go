@@ -2,6 +2,7 @@ package prolog
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/ichiban/prolog/engine"
@@ -18,7 +19,8 @@ func TestSolutions_Close(t *testing.T) {
 
 func TestSolutions_Next(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		env := engine.NewEnv().Bind("Foo", engine.Atom("bar"))
+		foo := engine.NewVariable()
+		env := engine.NewEnv().Bind(foo, engine.Atom("bar"))
 		more := make(chan bool, 1)
 		defer close(more)
 		next := make(chan *engine.Env, 1)
@@ -26,7 +28,7 @@ func TestSolutions_Next(t *testing.T) {
 		next <- env
 		sols := Solutions{more: more, next: next}
 		assert.True(t, sols.Next())
-		assert.Equal(t, engine.Atom("bar"), sols.env.Resolve(engine.Variable("Foo")))
+		assert.Equal(t, engine.Atom("bar"), sols.env.Resolve(foo))
 	})
 
 	t.Run("closed", func(t *testing.T) {
@@ -36,33 +38,35 @@ func TestSolutions_Next(t *testing.T) {
 }
 
 func TestSolutions_Scan(t *testing.T) {
-	env := engine.NewEnv()
-	for k, v := range map[engine.Variable]engine.Term{
-		"Float32": engine.Float(32),
-		"Float64": engine.Float(64),
-		"Int":     engine.Integer(1),
-		"Int8":    engine.Integer(8),
-		"Int16":   engine.Integer(16),
-		"Int32":   engine.Integer(32),
-		"Int64":   engine.Integer(64),
-		"String":  engine.Atom("string"),
-		"Slice":   engine.List(engine.Atom("a"), engine.Atom("b"), engine.Atom("c")),
-		"Foo":     engine.Atom("foo"),
-		"Bar":     engine.Atom("bar"),
-		"Baz":     engine.Atom("baz"),
+	var (
+		env  = engine.NewEnv()
+		vars []engine.ParsedVariable
+	)
+	for _, b := range []struct {
+		name  engine.Atom
+		value engine.Term
+	}{
+		{name: "Float32", value: engine.Float(32)},
+		{name: "Float64", value: engine.Float(64)},
+		{name: "Int", value: engine.Integer(1)},
+		{name: "Int8", value: engine.Integer(8)},
+		{name: "Int16", value: engine.Integer(16)},
+		{name: "Int32", value: engine.Integer(32)},
+		{name: "Int64", value: engine.Integer(64)},
+		{name: "String", value: engine.Atom("string")},
+		{name: "Slice", value: engine.List(engine.Atom("a"), engine.Atom("b"), engine.Atom("c"))},
+		{name: "Foo", value: engine.Atom("foo")},
+		{name: "Bar", value: engine.Atom("bar")},
+		{name: "Baz", value: engine.Atom("baz")},
 	} {
-		env = env.Bind(k, v)
+		v := engine.NewVariable()
+		env = env.Bind(v, b.value)
+		vars = append(vars, engine.ParsedVariable{Name: b.name, Variable: v, Count: 1})
 	}
 
 	sols := Solutions{
-		env: env,
-		vars: []engine.Variable{
-			"Float32", "Float64",
-			"Int", "Int8", "Int16", "Int32", "Int64",
-			"String",
-			"Slice",
-			"Foo", "Bar", "Baz",
-		},
+		env:  env,
+		vars: vars,
 	}
 
 	t.Run("struct", func(t *testing.T) {
@@ -108,6 +112,18 @@ func TestSolutions_Scan(t *testing.T) {
 				}
 				assert.Error(t, sols.Scan(&s))
 			})
+
+			t.Run("overflow", func(t *testing.T) {
+				v := engine.NewVariable()
+				sols := Solutions{
+					env:  env.Bind(v, engine.Integer(300)),
+					vars: []engine.ParsedVariable{{Name: "Int8", Variable: v, Count: 1}},
+				}
+				var s struct {
+					Int8 int8
+				}
+				assert.Equal(t, engine.RepresentationError("int8"), sols.Scan(&s))
+			})
 		})
 	})
 
@@ -146,6 +162,20 @@ func TestSolutions_Scan(t *testing.T) {
 	})
 }
 
+func TestSolutions_WriteAnswer(t *testing.T) {
+	i := New(nil, nil)
+	sols, err := i.Query(`X = Y, Y = f(_), Z = 'a b'.`)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, sols.Close())
+	}()
+
+	assert.True(t, sols.Next())
+	var sb strings.Builder
+	assert.NoError(t, sols.WriteAnswer(&sb, i.AnswerWriteOptions()...))
+	assert.Equal(t, "X = Y,\nY = f(_A),\nZ = 'a b'", sb.String())
+}
+
 func TestSolutions_Err(t *testing.T) {
 	err := errors.New("ng")
 	sols := Solutions{err: err}
@@ -154,7 +184,11 @@ func TestSolutions_Err(t *testing.T) {
 
 func TestSolutions_Vars(t *testing.T) {
 	sols := Solutions{
-		vars: []engine.Variable{"A", "B", "_1", "C"},
+		vars: []engine.ParsedVariable{
+			{Name: "A", Variable: engine.NewVariable(), Count: 1},
+			{Name: "B", Variable: engine.NewVariable(), Count: 1},
+			{Name: "C", Variable: engine.NewVariable(), Count: 1},
+		},
 	}
 
 	assert.Equal(t, []string{"A", "B", "C"}, sols.Vars())
@@ -0,0 +1,5 @@
+% Clauses for coverage tests.
+foo(X) :- bar(X).
+
+bar(a).
+bar(b).
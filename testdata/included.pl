@@ -0,0 +1,2 @@
+bar(a).
+:- prolog_load_context(source, S), prolog_load_context(file, F), prolog_load_context(term_position, '$stream_position'(_, L, _, _)), assertz(context(S, F, L)).
@@ -0,0 +1,6 @@
+:- initialization(assertz(initialized(after_load))).
+:- initialization(assertz(initialized(now)), now).
+:- include(included).
+:- prolog_load_context(source, S), prolog_load_context(file, F), assertz(context(S, F)).
+
+foo(a).
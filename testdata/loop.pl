@@ -0,0 +1 @@
+:- repeat, fail.